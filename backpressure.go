@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"runtime/metrics"
+	"sync"
+	"time"
+)
+
+const (
+	// Intervalo mínimo entre os avisos de contrapressão
+	backpressureLogInterval = 30 * time.Second
+	// Intervalo entre as medições de memória com a leitura pausada
+	memoryCheckInterval = time.Second
+)
+
+// Limite de documentos em processamento no pipeline (MAX_IN_FLIGHT): um envio
+// que ultrapassaria o limite aguarda até que documentos anteriores sejam
+// gravados ou falhem. Um lote maior que o limite passa quando não há nada em
+// processamento, para que o pipeline nunca fique parado. nil não limita.
+type inFlightLimiter struct {
+	limit int
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	n       int
+	waits   int
+	waited  time.Duration
+	lastLog time.Time
+}
+
+func newInFlightLimiter(limit int) *inFlightLimiter {
+	if limit <= 0 {
+		return nil
+	}
+	l := &inFlightLimiter{limit: limit}
+	l.cond = sync.NewCond(&l.mu)
+	return l
+}
+
+// Reserva n documentos, aguardando espaço se necessário
+func (l *inFlightLimiter) acquire(n int) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.n > 0 && l.n+n > l.limit {
+		start := time.Now()
+		l.waits++
+		backpressureWaits.WithLabelValues("in_flight").Inc()
+		if start.Sub(l.lastLog) >= backpressureLogInterval {
+			l.lastLog = start
+			logEvent("backpressure", fmt.Sprintf("%d documentos em processamento (limite %d); leitura pausada até a gravação avançar", l.n, l.limit),
+				"in_flight", l.n, "limit", l.limit)
+		}
+		for l.n > 0 && l.n+n > l.limit {
+			l.cond.Wait()
+		}
+		l.waited += time.Since(start)
+	}
+	l.n += n
+	documentsInFlight.Set(float64(l.n))
+}
+
+// Libera n documentos finalizados
+func (l *inFlightLimiter) release(n int) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.n -= n
+	documentsInFlight.Set(float64(l.n))
+	l.cond.Broadcast()
+}
+
+// Quantidade de esperas e o tempo total aguardado
+func (l *inFlightLimiter) stats() (waits int, waited time.Duration) {
+	if l == nil {
+		return 0, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.waits, l.waited
+}
+
+// Pausa a leitura enquanto a memória do processo passa de MEMORY_LIMIT_MB,
+// dando tempo para que os documentos em processamento sejam gravados. Sem
+// documentos em processamento a espera não libera memória, então a leitura
+// continua com um aviso. nil não limita. Usado apenas pela goroutine da leitura.
+type memoryWatchdog struct {
+	limit  uint64 // bytes
+	pauses int
+	paused time.Duration
+}
+
+func newMemoryWatchdog(limitMB int) *memoryWatchdog {
+	if limitMB <= 0 {
+		return nil
+	}
+	return &memoryWatchdog{limit: uint64(limitMB) << 20}
+}
+
+// Aguarda até a memória ficar abaixo do limite; pending informa os documentos
+// ainda em processamento. Retorna o erro de ctx se a espera foi interrompida.
+func (w *memoryWatchdog) wait(ctx context.Context, pending func() int) error {
+	if w == nil {
+		return nil
+	}
+	usage := memoryUsage()
+	if usage <= w.limit {
+		return nil
+	}
+
+	start := time.Now()
+	w.pauses++
+	backpressureWaits.WithLabelValues("memory").Inc()
+	logWarnf("Memória do processo em %d MiB, acima de MEMORY_LIMIT_MB (%d MiB); leitura pausada até a gravação avançar", usage>>20, w.limit>>20)
+	defer func() { w.paused += time.Since(start) }()
+
+	for usage > w.limit {
+		if pending() == 0 {
+			runtime.GC()
+			if usage = memoryUsage(); usage > w.limit {
+				logWarnf("Memória ainda em %d MiB sem documentos em processamento; continuando a leitura", usage>>20)
+				return nil
+			}
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(memoryCheckInterval):
+		}
+		usage = memoryUsage()
+	}
+
+	logDebugf("Leitura retomada após %s com a memória em %d MiB", time.Since(start).Round(time.Millisecond), usage>>20)
+	return nil
+}
+
+// Memória obtida do sistema pelo runtime, sem a já devolvida: uma
+// aproximação do RSS do processo que não depende do sistema operacional
+func memoryUsage() uint64 {
+	samples := []metrics.Sample{
+		{Name: "/memory/classes/total:bytes"},
+		{Name: "/memory/classes/heap/released:bytes"},
+	}
+	metrics.Read(samples)
+	return samples[0].Value.Uint64() - samples[1].Value.Uint64()
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Circuit breaker por taxa de erros: guarda o resultado das últimas size
+// operações (buscas, embeddings e upserts) e, quando a janela está cheia e a
+// fração de falhas passa de threshold, abre por cooldown. Enquanto aberto,
+// wait bloqueia as novas operações; ao fim da pausa a janela recomeça vazia,
+// e a migração continua em vez de desistir do backend.
+type circuitBreaker struct {
+	size      int
+	threshold float64 // fração de falhas, entre 0 e 1
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	results   []bool // true para falha, em anel
+	next      int
+	count     int
+	failures  int
+	openUntil time.Time
+	trips     int
+}
+
+// Breaker com janela de size operações; size 0 desativa e retorna nil, e os
+// métodos de um breaker nil não fazem nada
+func newCircuitBreaker(size int, thresholdPercent float64, cooldown time.Duration) *circuitBreaker {
+	if size <= 0 {
+		return nil
+	}
+	return &circuitBreaker{
+		size:      size,
+		threshold: thresholdPercent / 100,
+		cooldown:  cooldown,
+		results:   make([]bool, size),
+	}
+}
+
+// Registra o resultado de uma operação e abre o circuito se a taxa de falhas
+// da janela ultrapassou o limite
+func (b *circuitBreaker) record(err error) {
+	if b == nil || errors.Is(err, context.Canceled) {
+		return
+	}
+	failed := err != nil
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	// Resultados de operações iniciadas antes da abertura não contam
+	if time.Now().Before(b.openUntil) {
+		return
+	}
+
+	if b.count == b.size {
+		if b.results[b.next] {
+			b.failures--
+		}
+	} else {
+		b.count++
+	}
+	b.results[b.next] = failed
+	b.next = (b.next + 1) % b.size
+	if failed {
+		b.failures++
+	}
+
+	rate := float64(b.failures) / float64(b.size)
+	if b.count < b.size || rate <= b.threshold {
+		return
+	}
+
+	b.openUntil = time.Now().Add(b.cooldown)
+	b.trips++
+	b.count, b.failures, b.next = 0, 0, 0
+	breakerOpen.Set(1)
+	breakerTrips.Inc()
+	logWarnf("Circuit breaker aberto: %.0f%% das últimas %d operações falharam; pausando por %s",
+		rate*100, b.size, b.cooldown)
+}
+
+// Aguarda o fim da pausa, se o circuito estiver aberto. Retorna o erro de ctx
+// se a espera for interrompida.
+func (b *circuitBreaker) wait(ctx context.Context) error {
+	if b == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	remaining := time.Until(b.openUntil)
+	b.mu.Unlock()
+	if remaining <= 0 {
+		return nil
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(remaining):
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !time.Now().Before(b.openUntil) && b.openUntil != (time.Time{}) {
+		b.openUntil = time.Time{}
+		breakerOpen.Set(0)
+		logEvent("breaker_closed", fmt.Sprintf("Circuit breaker fechado após %s; retomando", b.cooldown), "trips", b.trips)
+	}
+	return nil
+}
+
+// Vezes em que o circuito abriu
+func (b *circuitBreaker) tripCount() int {
+	if b == nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.trips
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// indexing_threshold padrão do Qdrant, em KB, restaurado quando a coleção
+// estava com a indexação desativada antes da carga
+const defaultIndexingThreshold = 10000
+
+// Carga em massa (-bulk-load): com indexing_threshold 0 o Qdrant não constrói
+// o índice HNSW enquanto os pontos chegam, o que evita reindexar os segmentos
+// a cada lote. O limiar anterior é restaurado ao final da migração e o índice
+// é construído de uma vez, em segundo plano.
+
+// Desativa a indexação da coleção e retorna o indexing_threshold a restaurar.
+// Um limiar já 0, como o deixado por uma carga interrompida, é restaurado
+// para o padrão do Qdrant.
+func (qc *QdrantClient) disableIndexing(ctx context.Context, name string) (uint64, error) {
+	info, err := qc.collectionInfo(ctx, name)
+	if err != nil {
+		return 0, err
+	}
+
+	previous := uint64(defaultIndexingThreshold)
+	if threshold := info.GetConfig().GetOptimizerConfig().IndexingThreshold; threshold != nil {
+		previous = *threshold
+	}
+	if previous == 0 {
+		logWarnf("A coleção '%s' já está com a indexação desativada; ao final, indexing_threshold será restaurado para o padrão (%d)", name, defaultIndexingThreshold)
+		previous = defaultIndexingThreshold
+	}
+
+	if err := qc.setIndexingThreshold(ctx, name, 0); err != nil {
+		return 0, err
+	}
+	logEvent("bulk_load", fmt.Sprintf("Indexação da coleção '%s' desativada durante a carga (indexing_threshold %d será restaurado ao final)", name, previous),
+		"collection", name, "indexing_threshold", previous)
+	return previous, nil
+}
+
+// Restaura o indexing_threshold após a carga; o Qdrant passa a indexar os
+// pontos gravados em segundo plano
+func (qc *QdrantClient) restoreIndexing(ctx context.Context, name string, threshold uint64) error {
+	if err := qc.setIndexingThreshold(ctx, name, threshold); err != nil {
+		return err
+	}
+	logEvent("indexing_restored", fmt.Sprintf("Indexação da coleção '%s' reativada (indexing_threshold %d); o índice será construído em segundo plano", name, threshold),
+		"collection", name, "indexing_threshold", threshold)
+	return nil
+}
+
+func (qc *QdrantClient) setIndexingThreshold(ctx context.Context, name string, threshold uint64) error {
+	err := qc.do(ctx, func(ctx context.Context) error {
+		return qc.client.UpdateCollection(ctx, &qdrant.UpdateCollection{
+			CollectionName: name,
+			OptimizersConfig: &qdrant.OptimizersConfigDiff{
+				IndexingThreshold: qdrant.PtrOf(threshold),
+			},
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("erro ao atualizar indexing_threshold da coleção: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// Progresso de uma exportação, gravado após cada página completamente
+// gravada no Qdrant. Índice, coleção e modo de paginação identificam a
+// migração, para que um arquivo de outra exportação não seja reaproveitado.
+//
+// Na sincronização incremental, SyncFrom é o limite inferior da execução em
+// andamento e LastSync o maior timestamp entre os documentos gravados; ao fim
+// de uma execução sem falhas LastSync passa a ser o SyncFrom da próxima.
+//
+// Na exportação inversa (Direction qdrant-to-es), ScrollOffset é o ID do
+// ponto que abre a próxima página do scroll da coleção: um número ou um UUID.
+// Checkpoints sem Direction são de exportações para o Qdrant.
+type Checkpoint struct {
+	Direction   string        `json:"direction,omitempty"`
+	Index       string        `json:"index"`
+	Collection  string        `json:"collection"`
+	Mode        string        `json:"pagination_mode"`
+	From        int           `json:"from"`
+	SearchAfter []interface{} `json:"search_after,omitempty"`
+	Processed   int           `json:"processed"`
+	SyncField   string        `json:"sync_field,omitempty"`
+	SyncFrom    string        `json:"sync_from,omitempty"`
+	LastSync    string        `json:"last_sync,omitempty"`
+	UpdatedAt   time.Time     `json:"updated_at"`
+
+	// Exportação inversa; o ponto de ID 0 é um offset válido e é gravado
+	ScrollOffset interface{} `json:"scroll_offset,omitempty"`
+}
+
+// Carrega o checkpoint de path; retorna nil se o arquivo não existe e erro se
+// ele pertence a outra migração
+func loadCheckpoint(path string, cfg *Config) (*Checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler checkpoint: %v", err)
+	}
+
+	// UseNumber preserva valores de ordenação inteiros grandes do cursor
+	var ckpt Checkpoint
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&ckpt); err != nil {
+		return nil, fmt.Errorf("checkpoint inválido em %s: %v", path, err)
+	}
+
+	direction := ckpt.Direction
+	if direction == "" {
+		direction = "es-to-qdrant"
+	}
+	if direction != cfg.Direction {
+		return nil, fmt.Errorf("checkpoint %s foi gravado na exportação %s, não %s",
+			path, direction, cfg.Direction)
+	}
+	if ckpt.Index != cfg.indexName() || ckpt.Collection != cfg.CollectionName {
+		return nil, fmt.Errorf("checkpoint %s pertence à migração %s → %s, não a %s → %s",
+			path, ckpt.Index, ckpt.Collection, cfg.indexName(), cfg.CollectionName)
+	}
+	// O scroll da coleção não depende da paginação nem da sincronização
+	if direction == "qdrant-to-es" {
+		if _, err := ckpt.scrollOffset(); err != nil {
+			return nil, fmt.Errorf("checkpoint %s inválido: %v", path, err)
+		}
+		return &ckpt, nil
+	}
+	if ckpt.Mode != cfg.PaginationMode {
+		return nil, fmt.Errorf("checkpoint %s foi gravado no modo de paginação %s, não %s",
+			path, ckpt.Mode, cfg.PaginationMode)
+	}
+	if ckpt.SyncField != cfg.SyncField {
+		return nil, fmt.Errorf("checkpoint %s foi gravado com SYNC_FIELD=%q, não %q",
+			path, ckpt.SyncField, cfg.SyncField)
+	}
+
+	return &ckpt, nil
+}
+
+// Grava o checkpoint em um arquivo temporário e o renomeia, para que uma
+// interrupção durante a escrita não corrompa o progresso anterior
+func saveCheckpoint(path string, ckpt *Checkpoint) error {
+	data, err := json.MarshalIndent(ckpt, "", "  ")
+	if err != nil {
+		return fmt.Errorf("erro ao serializar checkpoint: %v", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("erro ao gravar checkpoint: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("erro ao gravar checkpoint: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("erro ao gravar checkpoint: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("erro ao gravar checkpoint: %v", err)
+	}
+
+	return nil
+}
+
+// Estado do checkpoint compartilhado pelas páginas de uma execução
+type checkpointState struct {
+	cfg      *Config
+	syncFrom string    // limite inferior da sincronização incremental em andamento
+	lastSync time.Time // maior timestamp entre as páginas já gravadas
+}
+
+func newCheckpointState(cfg *Config, ckpt *Checkpoint) *checkpointState {
+	st := &checkpointState{cfg: cfg}
+	if ckpt != nil {
+		st.syncFrom = ckpt.SyncFrom
+		st.lastSync, _ = time.Parse(time.RFC3339Nano, ckpt.LastSync)
+	}
+	return st
+}
+
+// Retorna a função que grava o checkpoint quando a página terminada em from
+// (e todas as anteriores) estiver gravada; pageMax é o maior timestamp da
+// página na sincronização incremental. nil sem -checkpoint ou em dry-run.
+func (st *checkpointState) commitFunc(from int, after []interface{}, processed int, pageMax time.Time) func() {
+	if st.cfg.Checkpoint == "" || st.cfg.DryRun {
+		return nil
+	}
+	return func() {
+		if pageMax.After(st.lastSync) {
+			st.lastSync = pageMax
+		}
+		st.save(from, after, processed, st.syncFrom)
+	}
+}
+
+// Chamado ao fim de uma exportação sem falhas. Sem sincronização incremental
+// o checkpoint é removido; com ela, a posição é zerada e o maior timestamp
+// gravado vira o limite inferior da próxima execução.
+func (st *checkpointState) finish() {
+	if st.cfg.Checkpoint == "" || st.cfg.DryRun {
+		return
+	}
+
+	if st.cfg.SyncField == "" {
+		removeCheckpoint(st.cfg.Checkpoint)
+		return
+	}
+
+	next := st.syncFrom
+	if !st.lastSync.IsZero() {
+		next = formatSyncTime(st.lastSync)
+	}
+	st.save(0, nil, 0, next)
+	log.Printf("Próxima sincronização a partir de %s (com janela de %s)", next, st.cfg.SyncOverlap)
+}
+
+func (st *checkpointState) save(from int, after []interface{}, processed int, syncFrom string) {
+	ckpt := &Checkpoint{
+		Direction:   st.cfg.Direction,
+		Index:       st.cfg.indexName(),
+		Collection:  st.cfg.CollectionName,
+		Mode:        st.cfg.PaginationMode,
+		From:        from,
+		SearchAfter: after,
+		Processed:   processed,
+		SyncField:   st.cfg.SyncField,
+		SyncFrom:    syncFrom,
+		UpdatedAt:   time.Now(),
+	}
+	if !st.lastSync.IsZero() {
+		ckpt.LastSync = formatSyncTime(st.lastSync)
+	}
+	if err := saveCheckpoint(st.cfg.Checkpoint, ckpt); err != nil {
+		logErrorf("Erro ao salvar checkpoint: %v", err)
+	}
+}
+
+// Remove o checkpoint de uma exportação concluída
+func removeCheckpoint(path string) {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		logErrorf("Erro ao remover checkpoint: %v", err)
+	}
+}
+
+// Grava o progresso da exportação inversa: offset é o primeiro ponto da
+// próxima página do scroll e processed o total de pontos gravados
+func saveReverseCheckpoint(cfg *Config, offset *qdrant.PointId, processed int) {
+	var scrollOffset interface{} = offset.GetNum()
+	if uuid := offset.GetUuid(); uuid != "" {
+		scrollOffset = uuid
+	}
+	ckpt := &Checkpoint{
+		Direction:    cfg.Direction,
+		Index:        cfg.indexName(),
+		Collection:   cfg.CollectionName,
+		ScrollOffset: scrollOffset,
+		Processed:    processed,
+		UpdatedAt:    time.Now(),
+	}
+	if err := saveCheckpoint(cfg.Checkpoint, ckpt); err != nil {
+		logErrorf("Erro ao salvar checkpoint: %v", err)
+	}
+}
+
+// Offset do scroll salvo na exportação inversa; nil começa do início
+func (ckpt *Checkpoint) scrollOffset() (*qdrant.PointId, error) {
+	switch v := ckpt.ScrollOffset.(type) {
+	case nil:
+		return nil, nil
+	case json.Number:
+		n, err := strconv.ParseUint(v.String(), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("scroll_offset %s não é um ID numérico válido", v)
+		}
+		return qdrant.NewIDNum(n), nil
+	case string:
+		if strings.TrimSpace(v) == "" {
+			return nil, fmt.Errorf("scroll_offset vazio")
+		}
+		return qdrant.NewID(v), nil
+	default:
+		return nil, fmt.Errorf("scroll_offset deve ser um número ou um UUID, recebido %v", v)
+	}
+}
+
+// Interpreta o valor do campo de sincronização: epoch em milissegundos
+// (número ou string numérica) ou data no formato ISO 8601
+func parseSyncTime(v interface{}) (time.Time, bool) {
+	switch v := v.(type) {
+	case int64:
+		return time.UnixMilli(v).UTC(), true
+	case float64:
+		return time.UnixMilli(int64(v)).UTC(), true
+	case string:
+		if ms, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return time.UnixMilli(ms).UTC(), true
+		}
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999", "2006-01-02"} {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t.UTC(), true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+func formatSyncTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Campos do payload dos trechos com LongText "chunk": a posição do trecho e
+// o ID do documento de origem, para reagrupar os trechos na busca
+const (
+	chunkIndexField = "chunk_index"
+	parentIDField   = "parent_id"
+)
+
+// Aplica MaxChars aos textos dos documentos. Com LongText "truncate" o texto é
+// cortado no limite; com "chunk" cada documento longo é substituído por um
+// documento por trecho, com ID derivado de "{id}-{n}" e chunk_index no
+// payload. Os limites são em caracteres, não em bytes.
+func limitTextLength(docs []DocumentData, cfg *Config) []DocumentData {
+	if cfg.MaxChars == 0 {
+		return docs
+	}
+
+	out := docs[:0:0]
+	for _, doc := range docs {
+		if len([]rune(doc.Texto)) <= cfg.MaxChars {
+			out = append(out, doc)
+			continue
+		}
+		if cfg.LongText != "chunk" {
+			doc.Texto = string([]rune(doc.Texto)[:cfg.MaxChars])
+			doc.TextArray = nil
+			out = append(out, doc)
+			continue
+		}
+		out = append(out, chunkDocument(doc, cfg.MaxChars)...)
+	}
+	return out
+}
+
+// Divide o documento em trechos de até max caracteres, preferindo quebrar em
+// um espaço na segunda metade do trecho para não cortar palavras
+func chunkDocument(doc DocumentData, max int) []DocumentData {
+	var chunks []DocumentData
+	text := []rune(doc.Texto)
+	for len(text) > 0 {
+		end := min(max, len(text))
+		if end < len(text) {
+			for j := end; j > max/2; j-- {
+				if unicode.IsSpace(text[j]) {
+					end = j
+					break
+				}
+			}
+		}
+
+		piece := strings.TrimSpace(string(text[:end]))
+		text = text[end:]
+		if piece == "" {
+			continue
+		}
+
+		i := len(chunks)
+		chunk := doc
+		chunk.ID = 0
+		chunk.UUID = uuidV5(fmt.Sprintf("%s-%d", doc.idString(), i))
+		chunk.Texto = piece
+		chunk.TextArray = nil
+		chunk.Payload = make(map[string]interface{}, len(doc.Payload)+2)
+		for k, v := range doc.Payload {
+			chunk.Payload[k] = v
+		}
+		chunk.Payload[chunkIndexField] = i
+		chunk.Payload[parentIDField] = doc.idString()
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
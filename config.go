@@ -0,0 +1,2219 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// Configuração da exportação, carregada de variáveis de ambiente e
+// sobrescrita pelas flags de linha de comando
+type Config struct {
+	// Sentido da exportação: es-to-qdrant ou qdrant-to-es
+	Direction string
+	// Exportação qdrant-to-es: grava também o vetor denso, em ESVectorField
+	ExportVectors bool
+	ESVectorField string
+
+	// Elasticsearch
+	ESURL         string
+	ESIndex       string // índices separados por vírgula ou alias; substitui o índice de ESURL
+	ESScrollURL   string
+	ESAuthMode    string // "basic", "apikey" ou "bearer"
+	ESUsername    string
+	ESPassword    string
+	ESAPIKey      string // "id:api_key" ou já em base64
+	ESBearerToken string
+	// Arquivos com as credenciais acima, como os segredos montados pelo
+	// Docker ou Kubernetes; substituem a variável correspondente
+	ESPasswordFile    string
+	ESAPIKeyFile      string
+	ESBearerTokenFile string
+	ESCACert          string            // certificado da CA em PEM; vazio usa as CAs do sistema
+	ESInsecure        bool              // desativa a verificação do certificado TLS
+	ESClientCert      string            // certificado do cliente em PEM, para TLS mútuo
+	ESClientKey       string            // chave privada do certificado do cliente em PEM
+	ESHeaders         map[string]string // cabeçalhos adicionais de todas as requisições
+	// Pool de conexões do cliente HTTP do Elasticsearch
+	ESMaxIdleConns        int
+	ESMaxIdleConnsPerHost int
+	ESIdleConnTimeout     time.Duration
+	// Desativa o Accept-Encoding: gzip enviado nas requisições ao Elasticsearch
+	ESDisableCompression bool
+	PageSize             int
+	ScrollTTL            string
+	ScrollSlices         int    // fatias do scroll lidas em paralelo; 1 não fatia
+	PaginationMode       string // "scroll" ou "search_after"
+	UsePIT               bool   // search_after sobre um point-in-time, com snapshot consistente
+	CountFirst           bool   // total da consulta pelo _count, com buscas sem track_total_hits
+	SortField            string
+	Query                string   // objeto JSON da consulta; vazio usa match_all
+	SourceFields         []string // campos do _source copiados para o payload; aceitam curingas (*)
+	SourceExcludes       []string // campos do _source descartados, com curingas
+	IDField              string   // campo usado como ID do ponto; "_id" usa o ID do hit
+	IDFields             []string // chave composta: o ID é um UUID v5 dos valores; vazio usa IDField
+	IDPrefix             string   // namespace dos IDs: o ponto recebe o UUID v5 de prefixo+ID
+	IDOffset             uint64   // namespace dos IDs numéricos: somado a cada ID
+	OnMissingID          string   // skip, hash ou fail: documentos sem ID válido em IDField
+	TextField            string   // campo com o texto do embedding; aceita caminhos como "content.body"
+	EmbedFields          []string // campos combinados na entrada do embedding, no lugar de TextField
+	EmbedTemplate        string   // template da combinação, ex: "{title}\n{body}"
+	IndexField           string   // campo do payload com o índice de origem; vazio não grava
+	// Campos calculados pelo Elasticsearch e lidos de hit.fields: objetos JSON
+	// de runtime_mappings e script_fields incluídos na busca
+	RuntimeMappings string
+	ScriptFields    string
+
+	// Limite de tentativas para erros transitórios nos dois backends
+	MaxRetries int
+	// Workers gerando embeddings em paralelo
+	Workers int
+	// Contrapressão: máximo de documentos em processamento no pipeline e
+	// memória do processo, em MiB, acima da qual a leitura pausa; 0 desativa
+	MaxInFlight   int
+	MemoryLimitMB int
+	// Pula a verificação dos backends antes da migração
+	SkipPreflight bool
+	// Requisições por segundo a cada backend (buscas no Elasticsearch e
+	// upserts no Qdrant); 0 não limita
+	RateLimit float64
+	// Limites da pausa adaptativa entre as páginas lidas, usada sem RateLimit
+	ThrottleMin time.Duration
+	ThrottleMax time.Duration
+
+	// Circuit breaker: com mais de BreakerThreshold% de falhas nas últimas
+	// BreakerWindow operações, pausa a migração por BreakerCooldown; 0 desativa
+	BreakerWindow    int
+	BreakerThreshold float64
+	BreakerCooldown  time.Duration
+
+	// Limites de falhas: a exportação é abortada quando mais de
+	// MaxBatchFailureRate% dos documentos de um lote falham, ou mais de
+	// MaxFailureRate% dos documentos finalizados; 0 desativa
+	MaxBatchFailureRate float64
+	MaxFailureRate      float64
+
+	// Dry-run: lê e processa os documentos sem gravar no Qdrant
+	DryRun      bool
+	DryRunEmbed bool // gera os embeddings mesmo em dry-run
+
+	// Atualiza apenas o payload dos pontos existentes, sem gerar embeddings;
+	// com PayloadOverwrite o payload é substituído em vez de mesclado
+	PayloadOnly      bool
+	PayloadOverwrite bool
+
+	// Exibe uma barra de progresso no lugar dos logs por lote
+	Progress bool
+	// Formato dos logs: text ou json
+	LogFormat string
+	LogLevel  string // debug, info, warn ou error
+	// Exibe apenas avisos, erros e o resumo final, para execuções agendadas
+	Quiet bool
+	// Ocorrências registradas no log por categoria de erro
+	ErrorLogLimit int
+	// Endereço do servidor de métricas Prometheus (ex: :9090); vazio desativa
+	MetricsAddr string
+	// Arquivo do relatório JSON final; "-" usa a saída padrão, vazio desativa
+	Report string
+	// Erros tolerados antes de encerrar com código de saída 1; -1 não limita
+	MaxErrors int
+
+	// Arquivo de checkpoint para retomar exportações interrompidas
+	Checkpoint string
+
+	// Remove do Qdrant, ao final, os pontos sem documento correspondente
+	Prune bool
+
+	// Limite de caracteres do texto do embedding; 0 não limita. LongText define
+	// o que fazer com textos maiores: truncate corta, chunk divide em pontos
+	MaxChars int
+	LongText string
+	// TextField com um array: itens unidos por TextSeparator; TextArrayMixed
+	// define os itens que não são strings: stringify formata, skip ignora
+	TextSeparator  string
+	TextArrayMixed string
+
+	// Predicados de docPredicates que os documentos devem atender para serem
+	// enviados ao embedder
+	Filters []string
+
+	// Descarta documentos cuja entrada do embedding já foi enviada nesta
+	// execução, comparando hashes guardados em um LRU de DedupCacheSize
+	Dedup          bool
+	DedupHash      string // sha256, sha1, md5 ou fnv
+	DedupCacheSize int
+
+	// Grava content_hash no payload e ignora os documentos cujo ponto já tem
+	// o mesmo hash, sem gerar embedding nem reenviar o ponto
+	SkipUnchanged bool
+
+	// Verificação: documentos sorteados e conferidos no Qdrant no lugar da
+	// exportação; 0 exporta normalmente
+	Verify int
+	// Diferença: compara IDs e content_hash do Elasticsearch e do Qdrant e
+	// informa as contagens, sem gravar nada
+	Diff bool
+	// Amostra: documentos da primeira página exibidos como pontos (ID e
+	// payload) no lugar da exportação; 0 exporta normalmente
+	SamplePayloads int
+
+	// Documentos enviados ao embedder antes de encerrar a leitura, para testar
+	// uma configuração com uma amostra; 0 não limita
+	Limit int
+
+	// Sincronização incremental: campo de data usado para buscar apenas os
+	// documentos alterados desde a última execução, e a janela de
+	// sobreposição com a execução anterior
+	SyncField   string
+	SyncOverlap time.Duration
+
+	// Retomada pela coleção: busca apenas os documentos com IDField acima do
+	// maior ID numérico já gravado no Qdrant
+	ResumeFromQdrant bool
+
+	// Intervalo de IDField, inclusivo, dos documentos migrados; nil não limita
+	MinID *uint64
+	MaxID *uint64
+
+	// Qdrant
+	CollectionName string
+	// Apaga e recria a coleção existente antes da migração; sem Force, pede
+	// a confirmação no terminal
+	Recreate bool
+	Force    bool
+	// Desativa a indexação (indexing_threshold 0) durante a migração e a
+	// restaura ao final
+	BulkLoad bool
+	// Não cria nem consulta a coleção, provisionada fora da migração (por
+	// exemplo, por infraestrutura como código)
+	SkipCreate bool
+	// Coleção por documento a partir de campos do _source, como
+	// "docs_{tenant_id}"; vazio grava tudo em CollectionName
+	CollectionTemplate string
+	VectorSize         int
+	OnDimMismatch      string // fail ou skip: vetores com dimensão diferente de VectorSize
+	Distance           string // cosine, dot, euclid ou manhattan
+	VectorDatatype     string // float32, float16 ou uint8
+	QdrantURL          string // como grpcs://host:6334; substitui QdrantHost, QdrantPort e QdrantTLS
+	QdrantHost         string
+	QdrantPort         int
+	QdrantTLS          bool          // conexão gRPC com TLS, exigida pelo Qdrant Cloud
+	QdrantAPIKey       string        // enviada no cabeçalho api-key de cada requisição
+	QdrantTimeout      time.Duration // limite de cada requisição ao Qdrant
+	UpsertBatchSize    int
+	Wait               bool // aguarda a indexação de cada lote de upsert
+	// Grava o lote de upsert parcial cujo documento mais antigo espera esse
+	// tempo; 0 grava apenas lotes completos e o último
+	FlushInterval time.Duration
+	// Reenvia em partes os lotes rejeitados para isolar os pontos inválidos
+	IsolateFailures bool
+	// Arquivo JSON lines que recebe os pontos no lugar do Qdrant; vazio grava
+	// no Qdrant
+	Output string
+
+	// Shards e réplicas da coleção em clusters Qdrant; 0 mantém o padrão
+	ShardNumber       int
+	ReplicationFactor int
+
+	// Campo do documento com a chave de shard, com a coleção criada com
+	// sharding custom; vazio mantém o sharding automático. Documentos sem o
+	// campo vão para ShardKeyDefault
+	ShardKeyField   string
+	ShardKeyDefault string
+
+	// Payload e vetores da coleção criada armazenados em disco (mmap) em vez
+	// de RAM
+	OnDiskPayload bool
+	OnDiskVectors bool
+
+	// Índice HNSW e quantização da coleção; zero/vazio mantém o padrão do Qdrant
+	HnswM                 int
+	HnswEfConstruct       int
+	Quantization          string // "", "scalar" ou "product"
+	QuantizationQuantile  float64
+	QuantizationAlwaysRAM bool
+	PQCompression         string // x4, x8, x16, x32 ou x64
+
+	// Vetor esparso de frequência de termos, gravado junto ao denso
+	SparseVectors    bool
+	SparseVectorName string
+	SparseWeighting  string // tf, log ou binary
+	SparseMinTermLen int
+	SparseIDF        bool // aplica o modificador IDF do Qdrant
+
+	// Vetores densos nomeados da coleção, cada um com dimensão e distância
+	// próprias; vazio grava um único vetor sem nome
+	NamedVectors []namedVector
+
+	// Índices de payload criados na coleção, para filtros eficientes
+	PayloadIndexes []payloadIndex
+	// Campos geo_point do _source, gravados como {lat, lon}; com GeoIndex,
+	// cada um recebe um índice geo
+	GeoFields []string
+	GeoIndex  bool
+	// Validade dos pontos: grava expires_at (gravação + PointTTL) no payload,
+	// com índice datetime se PointTTLIndex; 0 não grava
+	PointTTL      time.Duration
+	PointTTLIndex bool
+	// Grava no payload text_hash e embedding_model, para identificar os pontos
+	// com embeddings de um modelo antigo
+	EmbeddingMetadata bool
+	// Chave do payload de cada campo do _source com nome diferente no Qdrant;
+	// "texto" renomeia a chave do texto do embedding
+	PayloadRename map[string]string
+
+	// Embedder: openai ou http (servidor próprio, como text-embeddings-inference)
+	Embedder       string
+	OpenAIAPIKey   string
+	OpenAIModel    string
+	EmbedURL       string
+	EmbedTimeout   time.Duration
+	EmbedHeaders   map[string]string // cabeçalhos das requisições ao EmbedURL
+	EmbedBatchSize int
+	Normalize      bool   // normalização L2 dos embeddings antes do upsert
+	EmbedCache     string // arquivo do cache de embeddings
+	NoCache        bool
+	// Campo dense_vector do _source com o embedding já calculado, usado no
+	// lugar do embedder; vazio gera os embeddings
+	EmbeddingField string
+	// Novas tentativas das requisições de embeddings, independentes de
+	// MAX_RETRIES: tentativas e backoff exponencial entre os dois intervalos
+	EmbedMaxRetries    int
+	EmbedRetryDelay    time.Duration
+	EmbedRetryMaxDelay time.Duration
+}
+
+// Carrega a configuração das variáveis de ambiente e aplica as flags em args
+// por cima delas. Com -help, imprime as opções e retorna flag.ErrHelp.
+func LoadConfig(args []string) (*Config, error) {
+	cfg := &Config{
+		Direction:          getEnv("DIRECTION", "es-to-qdrant"),
+		ESVectorField:      getEnv("ES_VECTOR_FIELD", "embedding"),
+		ESURL:              getEnv("ES_URL", "https://elastic:9200/index/_search"),
+		ESScrollURL:        os.Getenv("ES_SCROLL_URL"),
+		ESIndex:            os.Getenv("ES_INDEX"),
+		ESAuthMode:         os.Getenv("ES_AUTH_MODE"),
+		ESUsername:         getEnv("ES_USERNAME", "usuario_elastic"),
+		ESPassword:         os.Getenv("ES_PASSWORD"),
+		ESPasswordFile:     os.Getenv("ES_PASSWORD_FILE"),
+		ESAPIKeyFile:       os.Getenv("ES_API_KEY_FILE"),
+		ESBearerTokenFile:  os.Getenv("ES_BEARER_TOKEN_FILE"),
+		ESAPIKey:           os.Getenv("ES_API_KEY"),
+		ESBearerToken:      os.Getenv("ES_BEARER_TOKEN"),
+		ESCACert:           os.Getenv("ES_CA_CERT"),
+		ESClientCert:       os.Getenv("ES_CLIENT_CERT"),
+		ESClientKey:        os.Getenv("ES_CLIENT_KEY"),
+		ScrollTTL:          getEnv("SCROLL_TTL", "1m"),
+		PaginationMode:     getEnv("PAGINATION_MODE", "scroll"),
+		SortField:          getEnv("SORT_FIELD", "id"),
+		Query:              os.Getenv("ES_QUERY"),
+		RuntimeMappings:    os.Getenv("ES_RUNTIME_MAPPINGS"),
+		ScriptFields:       os.Getenv("ES_SCRIPT_FIELDS"),
+		SourceFields:       splitList(getEnv("SOURCE_FIELDS", "id,texto")),
+		SourceExcludes:     splitList(os.Getenv("SOURCE_EXCLUDES")),
+		IDField:            getEnv("ID_FIELD", "id"),
+		IDFields:           splitList(os.Getenv("ID_FIELDS")),
+		OnMissingID:        getEnv("ON_MISSING_ID", "skip"),
+		IDPrefix:           os.Getenv("ID_PREFIX"),
+		TextField:          getEnv("TEXT_FIELD", "texto"),
+		EmbedFields:        splitList(os.Getenv("EMBED_FIELDS")),
+		Filters:            splitList(os.Getenv("FILTERS")),
+		LongText:           getEnv("LONG_TEXT", "truncate"),
+		TextSeparator:      getEnv("TEXT_SEPARATOR", "\n"),
+		TextArrayMixed:     getEnv("TEXT_ARRAY_MIXED", "stringify"),
+		EmbedTemplate:      os.Getenv("EMBED_TEMPLATE"),
+		IndexField:         getEnv("INDEX_FIELD", "source_index"),
+		Checkpoint:         os.Getenv("CHECKPOINT"),
+		Output:             os.Getenv("OUTPUT"),
+		LogFormat:          getEnv("LOG_FORMAT", "text"),
+		LogLevel:           getEnv("LOG_LEVEL", "info"),
+		MetricsAddr:        os.Getenv("METRICS_ADDR"),
+		Report:             os.Getenv("REPORT"),
+		SyncField:          os.Getenv("SYNC_FIELD"),
+		CollectionName:     getEnv("COLLECTION_NAME", "nome_collection_qdrant"),
+		CollectionTemplate: os.Getenv("COLLECTION_TEMPLATE"),
+		ShardKeyField:      os.Getenv("SHARD_KEY_FIELD"),
+		ShardKeyDefault:    getEnv("SHARD_KEY_DEFAULT", "default"),
+		OnDimMismatch:      getEnv("ON_DIM_MISMATCH", "fail"),
+		DedupHash:          getEnv("DEDUP_HASH", "sha256"),
+		Distance:           getEnv("DISTANCE", "cosine"),
+		VectorDatatype:     getEnv("VECTOR_DATATYPE", "float32"),
+		QdrantURL:          os.Getenv("QDRANT_URL"),
+		QdrantHost:         getEnv("QDRANT_HOST", "localhost"),
+		QdrantAPIKey:       os.Getenv("QDRANT_API_KEY"),
+		Quantization:       os.Getenv("QUANTIZATION"),
+		PQCompression:      getEnv("PQ_COMPRESSION", "x16"),
+		SparseVectorName:   getEnv("SPARSE_VECTOR_NAME", "texto-sparse"),
+		SparseWeighting:    getEnv("SPARSE_WEIGHTING", "tf"),
+		Embedder:           getEnv("EMBEDDER", "openai"),
+		OpenAIAPIKey:       getEnv("OPENAI_API_KEY", "chave_openai"),
+		OpenAIModel:        getEnv("OPENAI_MODEL", defaultOpenAIModel),
+		EmbedURL:           os.Getenv("EMBED_URL"),
+		EmbeddingField:     os.Getenv("EMBEDDING_FIELD"),
+		EmbedCache:         getEnv("EMBED_CACHE", "embeddings-cache.jsonl"),
+	}
+
+	var err error
+	if cfg.PageSize, err = getEnvInt("PAGE_SIZE", 1000); err != nil {
+		return nil, err
+	}
+	if cfg.ScrollSlices, err = getEnvInt("SCROLL_SLICES", 1); err != nil {
+		return nil, err
+	}
+	if cfg.VectorSize, err = getEnvInt("VECTOR_SIZE", 1536); err != nil {
+		return nil, err
+	}
+	if cfg.QdrantPort, err = getEnvInt("QDRANT_PORT", 6334); err != nil {
+		return nil, err
+	}
+	if cfg.QdrantTLS, err = getEnvBool("QDRANT_TLS", false); err != nil {
+		return nil, err
+	}
+	if cfg.QdrantTimeout, err = getEnvDuration("QDRANT_TIMEOUT", 30*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.UpsertBatchSize, err = getEnvInt("UPSERT_BATCH_SIZE", 256); err != nil {
+		return nil, err
+	}
+	if cfg.FlushInterval, err = getEnvDuration("FLUSH_INTERVAL", 0); err != nil {
+		return nil, err
+	}
+	if cfg.EmbedBatchSize, err = getEnvInt("EMBED_BATCH_SIZE", 96); err != nil {
+		return nil, err
+	}
+	if cfg.Normalize, err = getEnvBool("NORMALIZE", false); err != nil {
+		return nil, err
+	}
+	if cfg.ShardNumber, err = getEnvInt("SHARD_NUMBER", 0); err != nil {
+		return nil, err
+	}
+	if cfg.ReplicationFactor, err = getEnvInt("REPLICATION_FACTOR", 0); err != nil {
+		return nil, err
+	}
+	if cfg.OnDiskPayload, err = getEnvBool("ON_DISK_PAYLOAD", false); err != nil {
+		return nil, err
+	}
+	if cfg.OnDiskVectors, err = getEnvBool("ON_DISK_VECTORS", false); err != nil {
+		return nil, err
+	}
+	if cfg.HnswM, err = getEnvInt("HNSW_M", 0); err != nil {
+		return nil, err
+	}
+	if cfg.HnswEfConstruct, err = getEnvInt("HNSW_EF_CONSTRUCT", 0); err != nil {
+		return nil, err
+	}
+	if cfg.QuantizationQuantile, err = getEnvFloat("QUANTIZATION_QUANTILE", 0); err != nil {
+		return nil, err
+	}
+	if cfg.QuantizationAlwaysRAM, err = getEnvBool("QUANTIZATION_ALWAYS_RAM", false); err != nil {
+		return nil, err
+	}
+	if cfg.ExportVectors, err = getEnvBool("EXPORT_VECTORS", false); err != nil {
+		return nil, err
+	}
+	if cfg.ESInsecure, err = getEnvBool("ES_INSECURE", false); err != nil {
+		return nil, err
+	}
+	if cfg.ESMaxIdleConns, err = getEnvInt("ES_MAX_IDLE_CONNS", 100); err != nil {
+		return nil, err
+	}
+	if cfg.ESMaxIdleConnsPerHost, err = getEnvInt("ES_MAX_IDLE_CONNS_PER_HOST", 10); err != nil {
+		return nil, err
+	}
+	if cfg.ESIdleConnTimeout, err = getEnvDuration("ES_IDLE_CONN_TIMEOUT", 90*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.ESDisableCompression, err = getEnvBool("ES_DISABLE_COMPRESSION", false); err != nil {
+		return nil, err
+	}
+	if cfg.Progress, err = getEnvBool("PROGRESS", false); err != nil {
+		return nil, err
+	}
+	if cfg.Quiet, err = getEnvBool("QUIET", false); err != nil {
+		return nil, err
+	}
+	if cfg.SparseVectors, err = getEnvBool("SPARSE_VECTORS", false); err != nil {
+		return nil, err
+	}
+	if cfg.SparseMinTermLen, err = getEnvInt("SPARSE_MIN_TERM_LEN", 2); err != nil {
+		return nil, err
+	}
+	if cfg.SparseIDF, err = getEnvBool("SPARSE_IDF", true); err != nil {
+		return nil, err
+	}
+	if cfg.PointTTL, err = getEnvDuration("POINT_TTL", 0); err != nil {
+		return nil, err
+	}
+	if cfg.PointTTLIndex, err = getEnvBool("POINT_TTL_INDEX", false); err != nil {
+		return nil, err
+	}
+	if cfg.EmbeddingMetadata, err = getEnvBool("EMBEDDING_METADATA", false); err != nil {
+		return nil, err
+	}
+	if cfg.NamedVectors, err = parseNamedVectors(os.Getenv("NAMED_VECTORS")); err != nil {
+		return nil, fmt.Errorf("NAMED_VECTORS inválido: %v", err)
+	}
+	if cfg.PayloadIndexes, err = parsePayloadIndexes(os.Getenv("PAYLOAD_INDEXES")); err != nil {
+		return nil, fmt.Errorf("PAYLOAD_INDEXES inválido: %v", err)
+	}
+	if cfg.PayloadRename, err = parsePayloadRename(os.Getenv("PAYLOAD_RENAME")); err != nil {
+		return nil, fmt.Errorf("PAYLOAD_RENAME inválido: %v", err)
+	}
+	cfg.GeoFields = splitList(os.Getenv("GEO_FIELDS"))
+	if cfg.GeoIndex, err = getEnvBool("GEO_INDEX", false); err != nil {
+		return nil, err
+	}
+	if cfg.Wait, err = getEnvBool("UPSERT_WAIT", false); err != nil {
+		return nil, err
+	}
+	if cfg.IsolateFailures, err = getEnvBool("ISOLATE_FAILURES", false); err != nil {
+		return nil, err
+	}
+	if cfg.ErrorLogLimit, err = getEnvInt("ERROR_LOG_LIMIT", 5); err != nil {
+		return nil, err
+	}
+	if cfg.MaxErrors, err = getEnvInt("MAX_ERRORS", -1); err != nil {
+		return nil, err
+	}
+	if cfg.RateLimit, err = getEnvFloat("RATE_LIMIT", 0); err != nil {
+		return nil, err
+	}
+	if cfg.ThrottleMin, err = getEnvDuration("THROTTLE_MIN", 0); err != nil {
+		return nil, err
+	}
+	if cfg.ThrottleMax, err = getEnvDuration("THROTTLE_MAX", time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.BreakerWindow, err = getEnvInt("BREAKER_WINDOW", 0); err != nil {
+		return nil, err
+	}
+	if cfg.BreakerThreshold, err = getEnvFloat("BREAKER_THRESHOLD", 50); err != nil {
+		return nil, err
+	}
+	if cfg.BreakerCooldown, err = getEnvDuration("BREAKER_COOLDOWN", 30*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.MaxBatchFailureRate, err = getEnvFloat("MAX_BATCH_FAILURE_RATE", 0); err != nil {
+		return nil, err
+	}
+	if cfg.MaxFailureRate, err = getEnvFloat("MAX_FAILURE_RATE", 0); err != nil {
+		return nil, err
+	}
+	if cfg.SkipPreflight, err = getEnvBool("SKIP_PREFLIGHT", false); err != nil {
+		return nil, err
+	}
+	if cfg.SyncOverlap, err = getEnvDuration("SYNC_OVERLAP", 5*time.Minute); err != nil {
+		return nil, err
+	}
+	if cfg.ResumeFromQdrant, err = getEnvBool("RESUME_FROM_QDRANT", false); err != nil {
+		return nil, err
+	}
+	if offset, err := getEnvID("ID_OFFSET"); err != nil {
+		return nil, err
+	} else if offset != nil {
+		cfg.IDOffset = *offset
+	}
+	if cfg.MinID, err = getEnvID("MIN_ID"); err != nil {
+		return nil, err
+	}
+	if cfg.MaxID, err = getEnvID("MAX_ID"); err != nil {
+		return nil, err
+	}
+	if cfg.Prune, err = getEnvBool("PRUNE", false); err != nil {
+		return nil, err
+	}
+	if cfg.Dedup, err = getEnvBool("DEDUP", false); err != nil {
+		return nil, err
+	}
+	if cfg.MaxChars, err = getEnvInt("MAX_CHARS", 0); err != nil {
+		return nil, err
+	}
+	if cfg.SkipUnchanged, err = getEnvBool("SKIP_UNCHANGED", false); err != nil {
+		return nil, err
+	}
+	if cfg.DedupCacheSize, err = getEnvInt("DEDUP_CACHE_SIZE", 1000000); err != nil {
+		return nil, err
+	}
+	if cfg.Verify, err = getEnvInt("VERIFY", 0); err != nil {
+		return nil, err
+	}
+	if cfg.SamplePayloads, err = getEnvInt("SAMPLE_PAYLOADS", 0); err != nil {
+		return nil, err
+	}
+	if cfg.Diff, err = getEnvBool("DIFF", false); err != nil {
+		return nil, err
+	}
+	if cfg.Recreate, err = getEnvBool("RECREATE", false); err != nil {
+		return nil, err
+	}
+	if cfg.Force, err = getEnvBool("FORCE", false); err != nil {
+		return nil, err
+	}
+	if cfg.BulkLoad, err = getEnvBool("BULK_LOAD", false); err != nil {
+		return nil, err
+	}
+	if cfg.SkipCreate, err = getEnvBool("SKIP_CREATE", false); err != nil {
+		return nil, err
+	}
+	if cfg.Limit, err = getEnvInt("LIMIT", 0); err != nil {
+		return nil, err
+	}
+	if cfg.NoCache, err = getEnvBool("NO_CACHE", false); err != nil {
+		return nil, err
+	}
+	if cfg.EmbedTimeout, err = getEnvDuration("EMBED_TIMEOUT", 60*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.EmbedMaxRetries, err = getEnvInt("EMBED_MAX_RETRIES", 8); err != nil {
+		return nil, err
+	}
+	if cfg.EmbedRetryDelay, err = getEnvDuration("EMBED_RETRY_DELAY", time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.EmbedRetryMaxDelay, err = getEnvDuration("EMBED_RETRY_MAX_DELAY", time.Minute); err != nil {
+		return nil, err
+	}
+	if cfg.EmbedHeaders, err = parseHeaders(os.Getenv("EMBED_HEADERS")); err != nil {
+		return nil, fmt.Errorf("EMBED_HEADERS inválido: %v", err)
+	}
+	if cfg.ESHeaders, err = parseHeaders(os.Getenv("ES_HEADERS")); err != nil {
+		return nil, fmt.Errorf("ES_HEADERS inválido: %v", err)
+	}
+	if cfg.MaxRetries, err = getEnvInt("MAX_RETRIES", 5); err != nil {
+		return nil, err
+	}
+	if cfg.Workers, err = getEnvInt("WORKERS", runtime.NumCPU()); err != nil {
+		return nil, err
+	}
+	if cfg.MaxInFlight, err = getEnvInt("MAX_IN_FLIGHT", 0); err != nil {
+		return nil, err
+	}
+	if cfg.MemoryLimitMB, err = getEnvInt("MEMORY_LIMIT_MB", 0); err != nil {
+		return nil, err
+	}
+	if cfg.UsePIT, err = getEnvBool("USE_PIT", false); err != nil {
+		return nil, err
+	}
+	if cfg.CountFirst, err = getEnvBool("COUNT_FIRST", false); err != nil {
+		return nil, err
+	}
+	if cfg.DryRun, err = getEnvBool("DRY_RUN", false); err != nil {
+		return nil, err
+	}
+	if cfg.DryRunEmbed, err = getEnvBool("DRY_RUN_EMBED", false); err != nil {
+		return nil, err
+	}
+	if cfg.PayloadOnly, err = getEnvBool("PAYLOAD_ONLY", false); err != nil {
+		return nil, err
+	}
+	if cfg.PayloadOverwrite, err = getEnvBool("PAYLOAD_OVERWRITE", false); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.parseFlags(args); err != nil {
+		return nil, err
+	}
+	if err := cfg.loadSecretFiles(); err != nil {
+		return nil, err
+	}
+
+	if _, err := url.Parse(cfg.ESURL); err != nil {
+		return nil, fmt.Errorf("ES_URL inválida: %v", err)
+	}
+	if cfg.QdrantURL != "" {
+		if err := cfg.applyQdrantURL(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Índices informados separadamente substituem o caminho de ES_URL
+	if cfg.ESIndex != "" {
+		cfg.ESURL = cfg.esBaseURL() + "/" + escapeIndex(strings.Join(splitList(cfg.ESIndex), ",")) + "/_search"
+	}
+
+	// Entrada do embedding com vários campos: sem EMBED_FIELDS, os campos são
+	// os do template; sem template, os campos são unidos por quebra de linha
+	cfg.EmbedTemplate = unescapeTemplate(cfg.EmbedTemplate)
+	if cfg.EmbedTemplate != "" && len(cfg.EmbedFields) == 0 {
+		cfg.EmbedFields = templateFields(cfg.EmbedTemplate)
+	}
+	if len(cfg.EmbedFields) > 0 && cfg.EmbedTemplate == "" {
+		cfg.EmbedTemplate = "{" + strings.Join(cfg.EmbedFields, "}\n{") + "}"
+	}
+
+	// Vetores nomeados sem distância usam DISTANCE, e o do embedding sem
+	// dimensão, VECTOR_SIZE
+	for i := range cfg.NamedVectors {
+		if cfg.NamedVectors[i].Distance == "" {
+			cfg.NamedVectors[i].Distance = cfg.Distance
+		}
+		if cfg.NamedVectors[i].Field == "" && cfg.NamedVectors[i].Size == 0 {
+			cfg.NamedVectors[i].Size = cfg.VectorSize
+		}
+	}
+
+	// Sem URL de scroll explícita, usar o mesmo host do ES_URL
+	if cfg.ESScrollURL == "" {
+		cfg.ESScrollURL = cfg.esBaseURL() + "/_search/scroll"
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// Registra as flags usando os valores já carregados do ambiente como padrão,
+// de forma que a flag só tem efeito quando informada. Credenciais não possuem
+// flag para não aparecerem na lista de processos.
+func (c *Config) parseFlags(args []string) error {
+	fs := flag.NewFlagSet("rag-generator", flag.ContinueOnError)
+
+	fs.StringVar(&c.Direction, "direction", c.Direction, "sentido da exportação: es-to-qdrant ou qdrant-to-es (DIRECTION)")
+	fs.BoolVar(&c.ExportVectors, "with-vectors", c.ExportVectors, "com -direction qdrant-to-es, grava também o vetor denso de cada ponto (EXPORT_VECTORS)")
+	fs.StringVar(&c.ESVectorField, "es-vector-field", c.ESVectorField, "campo do documento no Elasticsearch com o vetor denso (ES_VECTOR_FIELD)")
+	fs.StringVar(&c.ESURL, "es-url", c.ESURL, "URL de busca do Elasticsearch (ES_URL)")
+	fs.StringVar(&c.ESIndex, "index", c.ESIndex, "índices separados por vírgula, padrão com curinga ou alias; substitui o índice de -es-url (ES_INDEX)")
+	fs.StringVar(&c.ESScrollURL, "es-scroll-url", c.ESScrollURL, "URL da API de scroll; derivada de -es-url se vazia (ES_SCROLL_URL)")
+	fs.StringVar(&c.ESAuthMode, "es-auth", c.ESAuthMode, "autenticação no Elasticsearch: basic, apikey ou bearer; vazio deduz pela credencial informada (ES_AUTH_MODE)")
+	fs.StringVar(&c.ESUsername, "es-username", c.ESUsername, "usuário do Elasticsearch (ES_USERNAME)")
+	fs.StringVar(&c.ESPasswordFile, "es-password-file", c.ESPasswordFile, "arquivo com a senha do Elasticsearch, no lugar de ES_PASSWORD (ES_PASSWORD_FILE)")
+	fs.StringVar(&c.ESAPIKeyFile, "es-api-key-file", c.ESAPIKeyFile, "arquivo com a API key do Elasticsearch, no lugar de ES_API_KEY (ES_API_KEY_FILE)")
+	fs.StringVar(&c.ESBearerTokenFile, "es-bearer-token-file", c.ESBearerTokenFile, "arquivo com o token bearer do Elasticsearch, no lugar de ES_BEARER_TOKEN (ES_BEARER_TOKEN_FILE)")
+	fs.StringVar(&c.ESCACert, "es-ca-cert", c.ESCACert, "arquivo PEM com a CA do certificado do Elasticsearch (ES_CA_CERT)")
+	fs.StringVar(&c.ESClientCert, "es-client-cert", c.ESClientCert, "arquivo PEM com o certificado do cliente para TLS mútuo (ES_CLIENT_CERT)")
+	fs.StringVar(&c.ESClientKey, "es-client-key", c.ESClientKey, "arquivo PEM com a chave privada do certificado do cliente (ES_CLIENT_KEY)")
+	fs.BoolVar(&c.ESInsecure, "insecure", c.ESInsecure, "não verifica o certificado TLS do Elasticsearch; use apenas em testes (ES_INSECURE)")
+	fs.IntVar(&c.ESMaxIdleConns, "es-max-idle-conns", c.ESMaxIdleConns, "conexões ociosas mantidas com o Elasticsearch; 0 não limita (ES_MAX_IDLE_CONNS)")
+	fs.IntVar(&c.ESMaxIdleConnsPerHost, "es-max-idle-conns-per-host", c.ESMaxIdleConnsPerHost, "conexões ociosas mantidas por host do Elasticsearch (ES_MAX_IDLE_CONNS_PER_HOST)")
+	fs.DurationVar(&c.ESIdleConnTimeout, "es-idle-conn-timeout", c.ESIdleConnTimeout, "tempo até fechar uma conexão ociosa com o Elasticsearch (ES_IDLE_CONN_TIMEOUT)")
+	fs.BoolVar(&c.ESDisableCompression, "es-disable-compression", c.ESDisableCompression, "não pede respostas com gzip ao Elasticsearch (ES_DISABLE_COMPRESSION)")
+	fs.IntVar(&c.PageSize, "page-size", c.PageSize, "documentos por página de busca (PAGE_SIZE)")
+	fs.StringVar(&c.ScrollTTL, "scroll-ttl", c.ScrollTTL, "tempo de vida do contexto de scroll ou do point-in-time (SCROLL_TTL)")
+	fs.StringVar(&c.PaginationMode, "pagination", c.PaginationMode, "modo de paginação: scroll ou search_after (PAGINATION_MODE)")
+	fs.IntVar(&c.ScrollSlices, "scroll-slices", c.ScrollSlices, "fatias do scroll lidas em paralelo, cada uma com seu contexto de scroll; até uma por shard (SCROLL_SLICES)")
+	fs.BoolVar(&c.UsePIT, "pit", c.UsePIT, "com search_after, lê de um point-in-time, sem efeito de gravações concorrentes (USE_PIT)")
+	fs.BoolVar(&c.CountFirst, "count-first", c.CountFirst, "conta os documentos com _count antes da leitura e busca as páginas sem track_total_hits (COUNT_FIRST)")
+	fs.StringVar(&c.SortField, "sort-field", c.SortField, "campo de ordenação do search_after (SORT_FIELD)")
+	fs.Func("source-fields", fmt.Sprintf("campos do _source separados por vírgula, com curingas como 'content.*' (SOURCE_FIELDS) (default %q)", strings.Join(c.SourceFields, ",")), func(v string) error {
+		c.SourceFields = splitList(v)
+		return nil
+	})
+	fs.Func("source-excludes", "campos do _source descartados, separados por vírgula, com curingas como 'content.internal*'; valem também sobre os campos de -source-fields (SOURCE_EXCLUDES)", func(v string) error {
+		c.SourceExcludes = splitList(v)
+		return nil
+	})
+	fs.StringVar(&c.IDField, "id-field", c.IDField, "campo usado como ID do ponto; \"_id\" usa o ID do documento no Elasticsearch (ID_FIELD)")
+	fs.Func("id-fields", "campos da chave composta, separados por vírgula, cujos valores geram um UUID v5 como ID do ponto, no lugar de ID_FIELD (ID_FIELDS)", func(v string) error {
+		c.IDFields = splitList(v)
+		return nil
+	})
+	fs.StringVar(&c.OnMissingID, "on-missing-id", c.OnMissingID, "documento sem ID válido em ID_FIELD: skip (ignora), hash (UUID derivado do _id) ou fail (aborta) (ON_MISSING_ID)")
+	fs.StringVar(&c.TextField, "text-field", c.TextField, "campo com o texto do embedding; aceita caminhos com pontos, como content.body (TEXT_FIELD)")
+	fs.Func("embed-fields", "campos combinados na entrada do embedding, separados por vírgula, no lugar de TEXT_FIELD (EMBED_FIELDS)", func(v string) error {
+		c.EmbedFields = splitList(v)
+		return nil
+	})
+	fs.StringVar(&c.EmbedTemplate, "embed-template", c.EmbedTemplate, "template da entrada do embedding, ex: '{title}\\n{body}'; padrão une EMBED_FIELDS por quebra de linha (EMBED_TEMPLATE)")
+	fs.StringVar(&c.IndexField, "index-field", c.IndexField, "campo do payload com o índice de origem do documento; vazio não grava (INDEX_FIELD)")
+	fs.StringVar(&c.Query, "query", c.Query, "consulta do Elasticsearch em JSON, ex.: '{\"term\": {\"status\": \"active\"}}'; vazia usa match_all (ES_QUERY)")
+	fs.StringVar(&c.RuntimeMappings, "runtime-mappings", c.RuntimeMappings, "runtime_mappings da busca em JSON; os campos são lidos de hit.fields e podem ser usados em TEXT_FIELD e SOURCE_FIELDS (ES_RUNTIME_MAPPINGS)")
+	fs.StringVar(&c.ScriptFields, "script-fields", c.ScriptFields, "script_fields da busca em JSON, lidos de hit.fields como os runtime_mappings (ES_SCRIPT_FIELDS)")
+	fs.IntVar(&c.MaxRetries, "max-retries", c.MaxRetries, "tentativas por requisição em erros transitórios (MAX_RETRIES)")
+	fs.Float64Var(&c.RateLimit, "rate", c.RateLimit, "requisições por segundo a cada backend (buscas e upserts); 0 não limita (RATE_LIMIT)")
+	fs.DurationVar(&c.ThrottleMin, "throttle-min", c.ThrottleMin, "pausa mínima entre páginas, com a latência do Qdrant normal; sem efeito com -rate (THROTTLE_MIN)")
+	fs.DurationVar(&c.ThrottleMax, "throttle-max", c.ThrottleMax, "pausa máxima entre páginas, com o Qdrant sobrecarregado; sem efeito com -rate (THROTTLE_MAX)")
+	fs.IntVar(&c.BreakerWindow, "breaker-window", c.BreakerWindow, "operações na janela do circuit breaker; 0 desativa (BREAKER_WINDOW)")
+	fs.Float64Var(&c.BreakerThreshold, "breaker-threshold", c.BreakerThreshold, "percentual de falhas na janela que abre o circuit breaker (BREAKER_THRESHOLD)")
+	fs.DurationVar(&c.BreakerCooldown, "breaker-cooldown", c.BreakerCooldown, "pausa da migração com o circuit breaker aberto (BREAKER_COOLDOWN)")
+	fs.Float64Var(&c.MaxBatchFailureRate, "max-batch-failure-rate", c.MaxBatchFailureRate, "aborta a exportação quando mais desse percentual dos documentos de um lote falha; 0 desativa (MAX_BATCH_FAILURE_RATE)")
+	fs.Float64Var(&c.MaxFailureRate, "max-failure-rate", c.MaxFailureRate, fmt.Sprintf("aborta a exportação quando mais desse percentual dos documentos finalizados falha, a partir de %d documentos; 0 desativa (MAX_FAILURE_RATE)", minFailureRateDocs))
+	fs.IntVar(&c.Workers, "workers", c.Workers, "workers gerando embeddings em paralelo (WORKERS)")
+	fs.IntVar(&c.MaxInFlight, "max-in-flight", c.MaxInFlight, "máximo de documentos em processamento; a leitura pausa ao atingi-lo, 0 não limita (MAX_IN_FLIGHT)")
+	fs.IntVar(&c.MemoryLimitMB, "memory-limit-mb", c.MemoryLimitMB, "memória do processo, em MiB, acima da qual a leitura pausa; 0 desativa (MEMORY_LIMIT_MB)")
+	fs.BoolVar(&c.SkipPreflight, "skip-preflight", c.SkipPreflight, "não verifica Elasticsearch, Qdrant e embedder antes de iniciar (SKIP_PREFLIGHT)")
+	fs.BoolVar(&c.DryRun, "dry-run", c.DryRun, "lê e processa os documentos sem gravar no Qdrant (DRY_RUN)")
+	fs.BoolVar(&c.DryRunEmbed, "dry-run-embed", c.DryRunEmbed, "gera os embeddings também em dry-run (DRY_RUN_EMBED)")
+	fs.BoolVar(&c.PayloadOnly, "payload-only", c.PayloadOnly, "atualiza apenas o payload dos pontos existentes, sem gerar embeddings (PAYLOAD_ONLY)")
+	fs.BoolVar(&c.PayloadOverwrite, "payload-overwrite", c.PayloadOverwrite, "com -payload-only, substitui o payload inteiro em vez de mesclar (PAYLOAD_OVERWRITE)")
+	fs.BoolVar(&c.Progress, "progress", c.Progress, "exibe uma barra de progresso com ETA no lugar dos logs por lote (PROGRESS)")
+	fs.StringVar(&c.LogFormat, "log-format", c.LogFormat, "formato dos logs: text ou json (LOG_FORMAT)")
+	fs.StringVar(&c.LogLevel, "log-level", c.LogLevel, "nível mínimo dos logs: debug, info, warn ou error (LOG_LEVEL)")
+	fs.BoolVar(&c.Quiet, "quiet", c.Quiet, "exibe apenas avisos, erros e o resumo final, para execuções agendadas (QUIET)")
+	fs.IntVar(&c.ErrorLogLimit, "error-log-limit", c.ErrorLogLimit, "erros registrados no log por categoria; os demais aparecem só no resumo final (ERROR_LOG_LIMIT)")
+	fs.StringVar(&c.Report, "report", c.Report, "grava ao final um relatório JSON da exportação neste arquivo; \"-\" usa a saída padrão (REPORT)")
+	fs.IntVar(&c.MaxErrors, "max-errors", c.MaxErrors, "encerra com código de saída 1 se a exportação terminar com mais erros que isso; -1 não limita (MAX_ERRORS)")
+	fs.StringVar(&c.MetricsAddr, "metrics-addr", c.MetricsAddr, "endereço do servidor de métricas Prometheus em /metrics, ex: :9090 (METRICS_ADDR)")
+	fs.StringVar(&c.Checkpoint, "checkpoint", c.Checkpoint, "arquivo JSON com o progresso, para retomar a exportação do ponto em que parou (CHECKPOINT)")
+	fs.StringVar(&c.SyncField, "sync-field", c.SyncField, "campo de data para sincronização incremental; requer -checkpoint (SYNC_FIELD)")
+	fs.DurationVar(&c.SyncOverlap, "sync-overlap", c.SyncOverlap, "janela de sobreposição com a sincronização anterior (SYNC_OVERLAP)")
+	fs.BoolVar(&c.ResumeFromQdrant, "resume-from-qdrant", c.ResumeFromQdrant, "retoma a partir do maior ID numérico já gravado na coleção; pressupõe IDs crescentes (RESUME_FROM_QDRANT)")
+	fs.StringVar(&c.IDPrefix, "id-prefix", c.IDPrefix, "prefixo dos IDs, para unir vários índices numa coleção: o ponto recebe o UUID v5 de prefixo+ID, ex: index1- (ID_PREFIX)")
+	fs.Func("id-offset", "valor somado aos IDs numéricos, para unir vários índices numa coleção sem colisões (ID_OFFSET)", func(v string) error {
+		offset, err := parseID(v)
+		if err == nil {
+			c.IDOffset = *offset
+		}
+		return err
+	})
+	fs.Func("min-id", "migra apenas os documentos com ID_FIELD maior ou igual a este valor (MIN_ID)", func(v string) error {
+		id, err := parseID(v)
+		c.MinID = id
+		return err
+	})
+	fs.Func("max-id", "migra apenas os documentos com ID_FIELD menor ou igual a este valor (MAX_ID)", func(v string) error {
+		id, err := parseID(v)
+		c.MaxID = id
+		return err
+	})
+	fs.BoolVar(&c.Prune, "prune", c.Prune, "ao final, remove do Qdrant os pontos cujos documentos não existem mais no Elasticsearch (PRUNE)")
+	fs.IntVar(&c.MaxChars, "max-chars", c.MaxChars, "limite de caracteres do texto do embedding (cerca de 4 por token); 0 não limita (MAX_CHARS)")
+	fs.StringVar(&c.TextSeparator, "text-separator", c.TextSeparator, "separador dos itens de TEXT_FIELD quando o campo é um array (TEXT_SEPARATOR)")
+	fs.StringVar(&c.TextArrayMixed, "text-array-mixed", c.TextArrayMixed, "itens que não são strings em um TEXT_FIELD array: stringify (formata) ou skip (ignora) (TEXT_ARRAY_MIXED)")
+	fs.StringVar(&c.LongText, "long-text", c.LongText, "textos acima de -max-chars: truncate (corta) ou chunk (divide em pontos {id}-0, {id}-1...) (LONG_TEXT)")
+	fs.Func("filter", "descarta os documentos que não atendem aos predicados, separados por vírgula: "+docPredicateNames+" (FILTERS)", func(v string) error {
+		c.Filters = splitList(v)
+		return nil
+	})
+	fs.BoolVar(&c.Dedup, "dedup", c.Dedup, "ignora documentos cujo texto do embedding já foi enviado nesta execução (DEDUP)")
+	fs.StringVar(&c.DedupHash, "dedup-hash", c.DedupHash, "hash dos textos no -dedup: sha256, sha1, md5 ou fnv (DEDUP_HASH)")
+	fs.IntVar(&c.DedupCacheSize, "dedup-cache-size", c.DedupCacheSize, "hashes mantidos em memória pelo -dedup; os menos recentes são descartados (DEDUP_CACHE_SIZE)")
+	fs.BoolVar(&c.SkipUnchanged, "skip-unchanged", c.SkipUnchanged, "ignora documentos cujo content_hash no Qdrant é igual ao atual (SKIP_UNCHANGED)")
+	fs.IntVar(&c.Verify, "verify", c.Verify, "em vez de exportar, sorteia N documentos e confere se os pontos existem no Qdrant com o mesmo texto (VERIFY)")
+	fs.BoolVar(&c.Diff, "diff", c.Diff, "em vez de exportar, conta os documentos a inserir, remover e atualizar na coleção, sem gravar nada (DIFF)")
+	fs.IntVar(&c.SamplePayloads, "sample-payloads", c.SamplePayloads, "em vez de exportar, exibe os IDs e payloads dos N primeiros documentos, sem vetores, e encerra (SAMPLE_PAYLOADS)")
+	fs.IntVar(&c.Limit, "limit", c.Limit, "encerra a leitura após enviar N documentos ao embedder; 0 não limita (LIMIT)")
+	fs.StringVar(&c.CollectionName, "collection", c.CollectionName, "nome da coleção no Qdrant (COLLECTION_NAME)")
+	fs.BoolVar(&c.Recreate, "recreate", c.Recreate, "apaga a coleção existente e a recria com a configuração atual; destrutivo, pede confirmação (RECREATE)")
+	fs.BoolVar(&c.Force, "force", c.Force, "com -recreate, apaga a coleção sem pedir confirmação (FORCE)")
+	fs.BoolVar(&c.BulkLoad, "bulk-load", c.BulkLoad, "desativa a indexação da coleção durante a migração e a reativa ao final, acelerando cargas grandes (BULK_LOAD)")
+	fs.BoolVar(&c.SkipCreate, "skip-create", c.SkipCreate, "não cria nem consulta a coleção, que precisa já existir, criada fora da migração; os índices de payload também não são criados (SKIP_CREATE)")
+	fs.StringVar(&c.CollectionTemplate, "collection-template", c.CollectionTemplate, "coleção de cada documento a partir de campos do _source, ex: 'docs_{tenant_id}'; sem os campos, usa COLLECTION_NAME (COLLECTION_TEMPLATE)")
+	fs.IntVar(&c.VectorSize, "vector-size", c.VectorSize, "dimensão dos embeddings (VECTOR_SIZE)")
+	fs.StringVar(&c.OnDimMismatch, "on-dim-mismatch", c.OnDimMismatch, "embedding com dimensão diferente de VECTOR_SIZE: fail (aborta) ou skip (ignora o documento) (ON_DIM_MISMATCH)")
+	fs.StringVar(&c.Distance, "distance", c.Distance, "métrica de distância: cosine, dot, euclid ou manhattan (DISTANCE)")
+	fs.StringVar(&c.VectorDatatype, "vector-datatype", c.VectorDatatype, "tipo dos componentes dos vetores na coleção: float32, float16 ou uint8 (VECTOR_DATATYPE)")
+	fs.StringVar(&c.QdrantURL, "qdrant-url", c.QdrantURL, "endereço gRPC do Qdrant, como grpc://host:6334 ou grpcs://host:6334 com TLS; substitui -qdrant-host, -qdrant-port e -qdrant-tls (QDRANT_URL)")
+	fs.StringVar(&c.QdrantHost, "qdrant-host", c.QdrantHost, "host do Qdrant (QDRANT_HOST)")
+	fs.IntVar(&c.QdrantPort, "qdrant-port", c.QdrantPort, "porta gRPC do Qdrant (QDRANT_PORT)")
+	fs.DurationVar(&c.QdrantTimeout, "qdrant-timeout", c.QdrantTimeout, "tempo máximo de cada requisição ao Qdrant; ao expirar, a requisição é repetida (QDRANT_TIMEOUT)")
+	fs.BoolVar(&c.QdrantTLS, "qdrant-tls", c.QdrantTLS, "conecta ao Qdrant com TLS, como no Qdrant Cloud (QDRANT_TLS)")
+	fs.IntVar(&c.ShardNumber, "shards", c.ShardNumber, "shards da coleção ao criá-la; 0 usa o padrão do Qdrant (SHARD_NUMBER)")
+	fs.IntVar(&c.ReplicationFactor, "replication-factor", c.ReplicationFactor, "réplicas de cada shard ao criar a coleção; 0 usa o padrão do Qdrant (REPLICATION_FACTOR)")
+	fs.StringVar(&c.ShardKeyField, "shard-key-field", c.ShardKeyField, "campo do documento com a chave de shard; cria a coleção com sharding custom e grava cada lote nos shards da chave (SHARD_KEY_FIELD)")
+	fs.StringVar(&c.ShardKeyDefault, "shard-key-default", c.ShardKeyDefault, "chave de shard dos documentos sem SHARD_KEY_FIELD (SHARD_KEY_DEFAULT)")
+	fs.BoolVar(&c.OnDiskPayload, "on-disk-payload", c.OnDiskPayload, "armazena o payload da coleção criada em disco em vez de RAM (ON_DISK_PAYLOAD)")
+	fs.BoolVar(&c.OnDiskVectors, "on-disk-vectors", c.OnDiskVectors, "armazena os vetores da coleção criada em disco (mmap) em vez de RAM (ON_DISK_VECTORS)")
+	fs.IntVar(&c.HnswM, "hnsw-m", c.HnswM, "arestas por nó do grafo HNSW; 0 usa o padrão do Qdrant (HNSW_M)")
+	fs.IntVar(&c.HnswEfConstruct, "hnsw-ef-construct", c.HnswEfConstruct, "ef_construct do HNSW; 0 usa o padrão do Qdrant (HNSW_EF_CONSTRUCT)")
+	fs.StringVar(&c.Quantization, "quantization", c.Quantization, "quantização dos vetores: scalar ou product; vazio desativa (QUANTIZATION)")
+	fs.Float64Var(&c.QuantizationQuantile, "quantization-quantile", c.QuantizationQuantile, "quantil da quantização scalar, entre 0.5 e 1; 0 usa o padrão (QUANTIZATION_QUANTILE)")
+	fs.BoolVar(&c.QuantizationAlwaysRAM, "quantization-always-ram", c.QuantizationAlwaysRAM, "mantém os vetores quantizados sempre em RAM (QUANTIZATION_ALWAYS_RAM)")
+	fs.StringVar(&c.PQCompression, "pq-compression", c.PQCompression, "compressão da quantização product: x4, x8, x16, x32 ou x64 (PQ_COMPRESSION)")
+	fs.BoolVar(&c.SparseVectors, "sparse", c.SparseVectors, "grava também um vetor esparso de frequência de termos, para busca híbrida (SPARSE_VECTORS)")
+	fs.StringVar(&c.SparseVectorName, "sparse-vector-name", c.SparseVectorName, "nome do vetor esparso na coleção (SPARSE_VECTOR_NAME)")
+	fs.StringVar(&c.SparseWeighting, "sparse-weighting", c.SparseWeighting, "peso dos termos: tf, log ou binary (SPARSE_WEIGHTING)")
+	fs.IntVar(&c.SparseMinTermLen, "sparse-min-term-len", c.SparseMinTermLen, "tamanho mínimo dos termos do vetor esparso (SPARSE_MIN_TERM_LEN)")
+	fs.BoolVar(&c.SparseIDF, "sparse-idf", c.SparseIDF, "aplica o IDF do Qdrant ao vetor esparso (SPARSE_IDF)")
+	fs.Func("named-vectors", `vetores nomeados da coleção em JSON, ex: '[{"name":"corpo"},{"name":"titulo","field":"titulo_vec","size":384}]'; o vetor sem "field" recebe o embedding (NAMED_VECTORS)`, func(v string) error {
+		var err error
+		c.NamedVectors, err = parseNamedVectors(v)
+		return err
+	})
+	fs.Func("payload-indexes", "índices de payload no formato campo:tipo separados por vírgula; tipos keyword, integer, float, bool, geo ou datetime (PAYLOAD_INDEXES)", func(v string) error {
+		var err error
+		c.PayloadIndexes, err = parsePayloadIndexes(v)
+		return err
+	})
+	fs.Func("geo-fields", "campos geo_point do _source, separados por vírgula, gravados no payload como {lat, lon} para os filtros geográficos do Qdrant (GEO_FIELDS)", func(v string) error {
+		c.GeoFields = splitList(v)
+		return nil
+	})
+	fs.BoolVar(&c.GeoIndex, "geo-index", c.GeoIndex, "cria um índice geo em cada campo de -geo-fields (GEO_INDEX)")
+	fs.DurationVar(&c.PointTTL, "point-ttl", c.PointTTL, "grava no payload expires_at com a data da gravação mais esta duração, ex: 720h; 0 não grava (POINT_TTL)")
+	fs.BoolVar(&c.PointTTLIndex, "point-ttl-index", c.PointTTLIndex, "cria um índice datetime em expires_at (POINT_TTL_INDEX)")
+	fs.BoolVar(&c.EmbeddingMetadata, "embedding-metadata", c.EmbeddingMetadata, "grava no payload text_hash (SHA-256 do texto do embedding) e embedding_model (EMBEDDING_METADATA)")
+	fs.Func("payload-rename", "chaves do payload diferentes dos campos do _source, no formato campo:chave separados por vírgula, ex: texto:content (PAYLOAD_RENAME)", func(v string) error {
+		var err error
+		c.PayloadRename, err = parsePayloadRename(v)
+		return err
+	})
+	fs.IntVar(&c.UpsertBatchSize, "upsert-batch", c.UpsertBatchSize, "pontos por requisição de upsert, independente de -embed-batch (UPSERT_BATCH_SIZE)")
+	fs.IntVar(&c.UpsertBatchSize, "batch-size", c.UpsertBatchSize, "mesmo que -upsert-batch, mantido por compatibilidade")
+	fs.DurationVar(&c.FlushInterval, "flush-interval", c.FlushInterval, "grava o lote de upsert parcial que espera esse tempo sem completar; 0 desativa (FLUSH_INTERVAL)")
+	fs.StringVar(&c.Output, "output", c.Output, "grava os pontos (id, vetor e payload) neste arquivo JSON lines em vez de enviá-los ao Qdrant (OUTPUT)")
+	fs.BoolVar(&c.Wait, "wait", c.Wait, "aguarda a indexação de cada lote no Qdrant antes de enviar o próximo (UPSERT_WAIT)")
+	fs.BoolVar(&c.IsolateFailures, "isolate-failures", c.IsolateFailures, "reenvia em partes os lotes rejeitados pelo Qdrant para identificar e pular os pontos inválidos (ISOLATE_FAILURES)")
+	fs.StringVar(&c.Embedder, "embedder", c.Embedder, "embedder: openai, http ou fake (vetores determinísticos para testes, sem chamar nenhum serviço) (EMBEDDER)")
+	fs.StringVar(&c.OpenAIModel, "openai-model", c.OpenAIModel, "modelo de embeddings da OpenAI (OPENAI_MODEL)")
+	fs.StringVar(&c.EmbedURL, "embed-url", c.EmbedURL, "URL do servidor de embeddings com -embedder http (EMBED_URL)")
+	fs.StringVar(&c.EmbeddingField, "embedding-field", c.EmbeddingField, "campo dense_vector do _source com o embedding já calculado, usado no lugar do embedder (EMBEDDING_FIELD)")
+	fs.DurationVar(&c.EmbedTimeout, "embed-timeout", c.EmbedTimeout, "timeout das requisições ao servidor de embeddings (EMBED_TIMEOUT)")
+	fs.IntVar(&c.EmbedBatchSize, "embed-batch", c.EmbedBatchSize, "textos por requisição de embeddings (EMBED_BATCH_SIZE)")
+	fs.IntVar(&c.EmbedMaxRetries, "embed-max-retries", c.EmbedMaxRetries, "tentativas por requisição de embeddings em erros transitórios (EMBED_MAX_RETRIES)")
+	fs.DurationVar(&c.EmbedRetryDelay, "embed-retry-delay", c.EmbedRetryDelay, "espera antes da primeira nova tentativa de embeddings, dobrada a cada falha (EMBED_RETRY_DELAY)")
+	fs.DurationVar(&c.EmbedRetryMaxDelay, "embed-retry-max-delay", c.EmbedRetryMaxDelay, "espera máxima entre as tentativas de embeddings (EMBED_RETRY_MAX_DELAY)")
+	fs.BoolVar(&c.Normalize, "normalize", c.Normalize, "normaliza os embeddings (norma L2 igual a 1) antes do upsert (NORMALIZE)")
+	fs.StringVar(&c.EmbedCache, "cache", c.EmbedCache, "arquivo do cache de embeddings (EMBED_CACHE)")
+	fs.BoolVar(&c.NoCache, "no-cache", c.NoCache, "desativa o cache de embeddings (NO_CACHE)")
+
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Uso: %s [opções]\n\n", fs.Name())
+		fmt.Fprintln(fs.Output(), "As flags têm precedência sobre as variáveis de ambiente indicadas entre parênteses.")
+		fmt.Fprintln(fs.Output(), "Opções:")
+		fs.PrintDefaults()
+	}
+
+	return fs.Parse(args)
+}
+
+func (c *Config) Validate() error {
+	if c.PageSize <= 0 {
+		return fmt.Errorf("PAGE_SIZE deve ser maior que zero")
+	}
+	if c.VectorSize <= 0 {
+		return fmt.Errorf("VECTOR_SIZE deve ser maior que zero")
+	}
+	switch c.OnMissingID {
+	case "skip", "hash", "fail":
+	default:
+		return fmt.Errorf("ON_MISSING_ID inválido: %q (use skip, hash ou fail)", c.OnMissingID)
+	}
+	if c.OnDimMismatch != "fail" && c.OnDimMismatch != "skip" {
+		return fmt.Errorf("ON_DIM_MISMATCH inválido: %q (use fail ou skip)", c.OnDimMismatch)
+	}
+	if c.UpsertBatchSize <= 0 {
+		return fmt.Errorf("UPSERT_BATCH_SIZE deve ser maior que zero")
+	}
+	if c.FlushInterval < 0 {
+		return fmt.Errorf("FLUSH_INTERVAL não pode ser negativo")
+	}
+	switch c.Embedder {
+	case "openai", "fake":
+	case "http":
+		if c.EmbedURL == "" {
+			return fmt.Errorf("EMBEDDER=http requer EMBED_URL")
+		}
+		if _, err := url.ParseRequestURI(c.EmbedURL); err != nil {
+			return fmt.Errorf("EMBED_URL inválida: %v", err)
+		}
+	default:
+		return fmt.Errorf("EMBEDDER inválido: %q (use openai, http ou fake)", c.Embedder)
+	}
+	if err := c.validateEmbeddingField(); err != nil {
+		return err
+	}
+	if c.QdrantPort <= 0 || c.QdrantPort > 65535 {
+		return fmt.Errorf("QDRANT_PORT deve estar entre 1 e 65535")
+	}
+	if c.QdrantTimeout <= 0 {
+		return fmt.Errorf("QDRANT_TIMEOUT deve ser maior que zero")
+	}
+	if c.EmbedTimeout <= 0 {
+		return fmt.Errorf("EMBED_TIMEOUT deve ser maior que zero")
+	}
+	if c.EmbedBatchSize <= 0 {
+		return fmt.Errorf("EMBED_BATCH_SIZE deve ser maior que zero")
+	}
+	if c.EmbedMaxRetries <= 0 {
+		return fmt.Errorf("EMBED_MAX_RETRIES deve ser maior que zero")
+	}
+	if c.EmbedRetryDelay <= 0 || c.EmbedRetryMaxDelay < c.EmbedRetryDelay {
+		return fmt.Errorf("EMBED_RETRY_DELAY deve ser maior que zero e no máximo EMBED_RETRY_MAX_DELAY")
+	}
+	if c.MaxRetries <= 0 {
+		return fmt.Errorf("MAX_RETRIES deve ser maior que zero")
+	}
+	if c.Workers <= 0 {
+		return fmt.Errorf("WORKERS deve ser maior que zero")
+	}
+	if c.RateLimit < 0 {
+		return fmt.Errorf("RATE_LIMIT não pode ser negativo")
+	}
+	if c.MaxInFlight < 0 || c.MemoryLimitMB < 0 {
+		return fmt.Errorf("MAX_IN_FLIGHT e MEMORY_LIMIT_MB não podem ser negativos")
+	}
+	// O coletor retém até UpsertBatchSize documentos antes de gravar; com
+	// menos espaço que isso mais um lote de embedding, o pipeline pararia
+	if c.MaxInFlight > 0 && c.MaxInFlight < c.UpsertBatchSize+c.EmbedBatchSize {
+		return fmt.Errorf("MAX_IN_FLIGHT deve ser pelo menos UPSERT_BATCH_SIZE + EMBED_BATCH_SIZE (%d)", c.UpsertBatchSize+c.EmbedBatchSize)
+	}
+	if c.ThrottleMin < 0 || c.ThrottleMax < c.ThrottleMin {
+		return fmt.Errorf("THROTTLE_MIN não pode ser negativo nem maior que THROTTLE_MAX")
+	}
+	if c.BreakerWindow < 0 {
+		return fmt.Errorf("BREAKER_WINDOW não pode ser negativo")
+	}
+	if c.BreakerWindow > 0 && (c.BreakerThreshold <= 0 || c.BreakerThreshold >= 100) {
+		return fmt.Errorf("BREAKER_THRESHOLD deve estar entre 0 e 100 (exclusivo)")
+	}
+	if c.BreakerWindow > 0 && c.BreakerCooldown <= 0 {
+		return fmt.Errorf("BREAKER_COOLDOWN deve ser maior que zero")
+	}
+	if c.MaxBatchFailureRate < 0 || c.MaxBatchFailureRate >= 100 {
+		return fmt.Errorf("MAX_BATCH_FAILURE_RATE deve estar entre 0 (desativado) e 100 (exclusivo)")
+	}
+	if c.MaxFailureRate < 0 || c.MaxFailureRate >= 100 {
+		return fmt.Errorf("MAX_FAILURE_RATE deve estar entre 0 (desativado) e 100 (exclusivo)")
+	}
+	if err := c.validateESAuth(); err != nil {
+		return err
+	}
+	if c.ESMaxIdleConns < 0 {
+		return fmt.Errorf("ES_MAX_IDLE_CONNS não pode ser negativo")
+	}
+	if c.ESMaxIdleConnsPerHost <= 0 {
+		return fmt.Errorf("ES_MAX_IDLE_CONNS_PER_HOST deve ser maior que zero")
+	}
+	if c.ESIdleConnTimeout < 0 {
+		return fmt.Errorf("ES_IDLE_CONN_TIMEOUT não pode ser negativo")
+	}
+	for name := range c.ESHeaders {
+		switch http.CanonicalHeaderKey(name) {
+		case "Authorization", "Content-Type", "Accept-Encoding":
+			return fmt.Errorf("ES_HEADERS não pode definir %s, controlado pelo próprio cliente (use ES_AUTH_MODE para a autenticação)", name)
+		}
+	}
+	if c.ESInsecure && c.ESCACert != "" {
+		return fmt.Errorf("ES_INSECURE e ES_CA_CERT são excludentes")
+	}
+	if (c.ESClientCert == "") != (c.ESClientKey == "") {
+		return fmt.Errorf("ES_CLIENT_CERT e ES_CLIENT_KEY devem ser informados juntos")
+	}
+	if c.SyncField != "" && c.Checkpoint == "" {
+		return fmt.Errorf("SYNC_FIELD requer CHECKPOINT para registrar a última sincronização")
+	}
+	if c.SyncOverlap < 0 {
+		return fmt.Errorf("SYNC_OVERLAP não pode ser negativo")
+	}
+	if err := c.validateDirection(); err != nil {
+		return err
+	}
+	if c.Prune && c.SyncField != "" {
+		return fmt.Errorf("PRUNE não pode ser usado com SYNC_FIELD: a sincronização incremental não lê todos os documentos")
+	}
+	if err := c.validateResumeFromQdrant(); err != nil {
+		return err
+	}
+	if err := c.validateIDRange(); err != nil {
+		return err
+	}
+	if err := c.validateIDFields(); err != nil {
+		return err
+	}
+	if c.MaxChars < 0 {
+		return fmt.Errorf("MAX_CHARS não pode ser negativo")
+	}
+	if c.LongText != "truncate" && c.LongText != "chunk" {
+		return fmt.Errorf("LONG_TEXT inválido: %q (use truncate ou chunk)", c.LongText)
+	}
+	if c.TextArrayMixed != "stringify" && c.TextArrayMixed != "skip" {
+		return fmt.Errorf("TEXT_ARRAY_MIXED inválido: %q (use stringify ou skip)", c.TextArrayMixed)
+	}
+	if c.MaxChars > 0 && c.LongText == "chunk" && c.Verify > 0 {
+		return fmt.Errorf("VERIFY não pode ser usado com LONG_TEXT=chunk: os pontos não correspondem mais um a um aos documentos")
+	}
+	for _, name := range c.Filters {
+		if _, ok := docPredicates[name]; !ok {
+			return fmt.Errorf("FILTERS contém o predicado desconhecido %q (use %s)", name, docPredicateNames)
+		}
+	}
+	if c.Dedup {
+		if _, ok := dedupHashes[c.DedupHash]; !ok {
+			return fmt.Errorf("DEDUP_HASH inválido: %q (use sha256, sha1, md5 ou fnv)", c.DedupHash)
+		}
+		if c.DedupCacheSize <= 0 {
+			return fmt.Errorf("DEDUP_CACHE_SIZE deve ser maior que zero")
+		}
+	}
+	if c.Verify < 0 || c.Verify > maxVerifySample {
+		return fmt.Errorf("VERIFY deve estar entre 0 e %d", maxVerifySample)
+	}
+	if c.Limit < 0 {
+		return fmt.Errorf("LIMIT não pode ser negativo")
+	}
+	if c.Limit > 0 && c.Checkpoint != "" {
+		return fmt.Errorf("LIMIT não pode ser usado com CHECKPOINT: a exportação parcial seria registrada como concluída")
+	}
+	if c.Limit > 0 && c.Prune {
+		return fmt.Errorf("LIMIT não pode ser usado com PRUNE: os pontos dos documentos não lidos seriam removidos")
+	}
+	if c.Verify > 0 && c.Direction != "es-to-qdrant" {
+		return fmt.Errorf("VERIFY não é suportado com DIRECTION=%s", c.Direction)
+	}
+	if err := c.validateSamplePayloads(); err != nil {
+		return err
+	}
+	if err := c.validateOutput(); err != nil {
+		return err
+	}
+	if err := c.validateDiff(); err != nil {
+		return err
+	}
+	if err := c.validateRecreate(); err != nil {
+		return err
+	}
+	if err := c.validateBulkLoad(); err != nil {
+		return err
+	}
+	if err := c.validateSkipCreate(); err != nil {
+		return err
+	}
+	if err := c.validateShardKey(); err != nil {
+		return err
+	}
+	if err := c.validateNamedVectors(); err != nil {
+		return err
+	}
+	if c.ErrorLogLimit < 0 {
+		return fmt.Errorf("ERROR_LOG_LIMIT não pode ser negativo")
+	}
+	if c.MaxErrors < -1 {
+		return fmt.Errorf("MAX_ERRORS deve ser -1 (sem limite) ou maior ou igual a zero")
+	}
+	if c.LogFormat != "text" && c.LogFormat != "json" {
+		return fmt.Errorf("LOG_FORMAT inválido: %q (use text ou json)", c.LogFormat)
+	}
+	if _, ok := logLevels[c.LogLevel]; !ok {
+		return fmt.Errorf("LOG_LEVEL inválido: %q (use debug, info, warn ou error)", c.LogLevel)
+	}
+	if c.Quiet && c.Progress {
+		return fmt.Errorf("QUIET não pode ser usado com PROGRESS, que exibe a barra de progresso em stderr")
+	}
+	if c.PaginationMode != "scroll" && c.PaginationMode != "search_after" {
+		return fmt.Errorf("PAGINATION_MODE inválido: %q (use scroll ou search_after)", c.PaginationMode)
+	}
+	if c.UsePIT && c.PaginationMode != "search_after" {
+		return fmt.Errorf("USE_PIT requer PAGINATION_MODE=search_after")
+	}
+	if err := c.validateScrollSlices(); err != nil {
+		return err
+	}
+	if _, ok := distances[c.Distance]; !ok {
+		return fmt.Errorf("DISTANCE inválida: %q (use cosine, dot, euclid ou manhattan)", c.Distance)
+	}
+	if _, ok := vectorDatatypes[c.VectorDatatype]; !ok {
+		return fmt.Errorf("VECTOR_DATATYPE inválido: %q (use float32, float16 ou uint8)", c.VectorDatatype)
+	}
+	if c.VectorDatatype == "uint8" && c.Distance != "euclid" && c.Distance != "manhattan" {
+		return fmt.Errorf("VECTOR_DATATYPE=uint8 exige DISTANCE euclid ou manhattan: a conversão para 0-255 não preserva a distância %s", c.Distance)
+	}
+	if c.ShardNumber < 0 || c.ReplicationFactor < 0 {
+		return fmt.Errorf("SHARD_NUMBER e REPLICATION_FACTOR não podem ser negativos")
+	}
+	if c.HnswM < 0 || c.HnswEfConstruct < 0 {
+		return fmt.Errorf("HNSW_M e HNSW_EF_CONSTRUCT não podem ser negativos")
+	}
+	switch c.Quantization {
+	case "", "scalar":
+	case "product":
+		if _, ok := qdrant.CompressionRatio_value[c.PQCompression]; !ok {
+			return fmt.Errorf("PQ_COMPRESSION inválido: %q (use x4, x8, x16, x32 ou x64)", c.PQCompression)
+		}
+	default:
+		return fmt.Errorf("QUANTIZATION inválido: %q (use scalar ou product)", c.Quantization)
+	}
+	if c.QuantizationQuantile != 0 && (c.QuantizationQuantile < 0.5 || c.QuantizationQuantile > 1) {
+		return fmt.Errorf("QUANTIZATION_QUANTILE deve estar entre 0.5 e 1")
+	}
+	if c.SparseVectors {
+		switch c.SparseWeighting {
+		case "tf", "log", "binary":
+		default:
+			return fmt.Errorf("SPARSE_WEIGHTING inválido: %q (use tf, log ou binary)", c.SparseWeighting)
+		}
+		if c.SparseVectorName == "" {
+			return fmt.Errorf("SPARSE_VECTOR_NAME não pode ser vazio")
+		}
+	}
+	if c.IDField == "" {
+		return fmt.Errorf("ID_FIELD não pode ser vazio")
+	}
+	if c.TextField == "" {
+		return fmt.Errorf("TEXT_FIELD não pode ser vazio")
+	}
+	for _, field := range templateFields(c.EmbedTemplate) {
+		if !slices.Contains(c.EmbedFields, field) {
+			return fmt.Errorf("EMBED_TEMPLATE usa o campo %q, que não está em EMBED_FIELDS", field)
+		}
+	}
+	if err := c.validateCollectionTemplate(); err != nil {
+		return err
+	}
+	if err := c.validateSourceExcludes(); err != nil {
+		return err
+	}
+	if err := c.validatePayloadRename(); err != nil {
+		return err
+	}
+	if err := c.validateIDNamespace(); err != nil {
+		return err
+	}
+	if err := c.validatePointTTL(); err != nil {
+		return err
+	}
+	if err := c.validateGeoFields(); err != nil {
+		return err
+	}
+	if err := c.validatePayloadOnly(); err != nil {
+		return err
+	}
+	if c.Query != "" {
+		var query map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(c.Query), &query); err != nil {
+			return fmt.Errorf("ES_QUERY deve ser um objeto JSON válido: %v", err)
+		}
+	}
+	if _, err := jsonObjectKeys(c.RuntimeMappings); err != nil {
+		return fmt.Errorf("ES_RUNTIME_MAPPINGS deve ser um objeto JSON válido: %v", err)
+	}
+	if _, err := jsonObjectKeys(c.ScriptFields); err != nil {
+		return fmt.Errorf("ES_SCRIPT_FIELDS deve ser um objeto JSON válido: %v", err)
+	}
+
+	return nil
+}
+
+// Define ESAuthMode quando não informado e verifica se apenas a credencial do
+// modo escolhido foi configurada. O modo basic é usado quando nem ES_API_KEY
+// nem ES_BEARER_TOKEN são definidos.
+func (c *Config) validateESAuth() error {
+	if c.ESAuthMode == "" {
+		switch {
+		case c.ESAPIKey != "" && c.ESBearerToken != "":
+			return fmt.Errorf("ES_API_KEY e ES_BEARER_TOKEN definidos ao mesmo tempo; informe apenas uma credencial ou defina ES_AUTH_MODE")
+		case c.ESAPIKey != "":
+			c.ESAuthMode = "apikey"
+		case c.ESBearerToken != "":
+			c.ESAuthMode = "bearer"
+		default:
+			c.ESAuthMode = "basic"
+		}
+	}
+
+	switch c.ESAuthMode {
+	case "basic":
+		if c.ESAPIKey != "" || c.ESBearerToken != "" {
+			return fmt.Errorf("ES_AUTH_MODE=basic não usa ES_API_KEY nem ES_BEARER_TOKEN; remova-os ou altere o modo")
+		}
+		if c.ESUsername == "" || c.ESPassword == "" {
+			return fmt.Errorf("ES_AUTH_MODE=basic requer ES_USERNAME e ES_PASSWORD (ou ES_PASSWORD_FILE)")
+		}
+	case "apikey":
+		if c.ESAPIKey == "" {
+			return fmt.Errorf("ES_AUTH_MODE=apikey requer ES_API_KEY (ou ES_API_KEY_FILE)")
+		}
+		if c.ESBearerToken != "" {
+			return fmt.Errorf("ES_AUTH_MODE=apikey não usa ES_BEARER_TOKEN; informe apenas uma credencial")
+		}
+	case "bearer":
+		if c.ESBearerToken == "" {
+			return fmt.Errorf("ES_AUTH_MODE=bearer requer ES_BEARER_TOKEN (ou ES_BEARER_TOKEN_FILE)")
+		}
+		if c.ESAPIKey != "" {
+			return fmt.Errorf("ES_AUTH_MODE=bearer não usa ES_API_KEY; informe apenas uma credencial")
+		}
+	default:
+		return fmt.Errorf("ES_AUTH_MODE inválido: %q (use basic, apikey ou bearer)", c.ESAuthMode)
+	}
+
+	return nil
+}
+
+// Lê as credenciais informadas por arquivo (ES_PASSWORD_FILE e semelhantes),
+// para que não apareçam na lista de processos nem no ambiente. Cada
+// credencial aceita a variável ou o arquivo, não os dois.
+func (c *Config) loadSecretFiles() error {
+	secrets := []struct {
+		name  string
+		path  string
+		value *string
+	}{
+		{"ES_PASSWORD", c.ESPasswordFile, &c.ESPassword},
+		{"ES_API_KEY", c.ESAPIKeyFile, &c.ESAPIKey},
+		{"ES_BEARER_TOKEN", c.ESBearerTokenFile, &c.ESBearerToken},
+	}
+
+	for _, s := range secrets {
+		if s.path == "" {
+			continue
+		}
+		if *s.value != "" {
+			return fmt.Errorf("%s e %s_FILE definidos ao mesmo tempo; informe apenas um", s.name, s.name)
+		}
+		secret, err := readSecretFile(s.path)
+		if err != nil {
+			return fmt.Errorf("%s_FILE inválido: %v", s.name, err)
+		}
+		*s.value = secret
+	}
+	return nil
+}
+
+// Conteúdo do arquivo de credencial, sem as quebras de linha finais que os
+// editores e o echo costumam adicionar
+func readSecretFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("erro ao ler arquivo de credencial: %v", err)
+	}
+	secret := strings.TrimRight(string(data), "\r\n")
+	if secret == "" {
+		return "", fmt.Errorf("arquivo de credencial %s está vazio", path)
+	}
+	return secret, nil
+}
+
+// Valida as opções da exportação inversa, que grava em um único índice e
+// não usa os recursos que dependem da leitura do Elasticsearch
+func (c *Config) validateDirection() error {
+	switch c.Direction {
+	case "es-to-qdrant":
+		return nil
+	case "qdrant-to-es":
+	default:
+		return fmt.Errorf("DIRECTION inválido: %q (use es-to-qdrant ou qdrant-to-es)", c.Direction)
+	}
+
+	index := c.indexName()
+	if index == "" || strings.ContainsAny(index, ",*:") {
+		return fmt.Errorf("DIRECTION=qdrant-to-es requer um único índice de destino em ES_URL ou ES_INDEX, recebido %q", index)
+	}
+	if c.ExportVectors && c.ESVectorField == "" {
+		return fmt.Errorf("EXPORT_VECTORS requer ES_VECTOR_FIELD")
+	}
+	switch {
+	case c.SyncField != "":
+		return fmt.Errorf("SYNC_FIELD não é suportado com DIRECTION=qdrant-to-es")
+	case c.Prune:
+		return fmt.Errorf("PRUNE não é suportado com DIRECTION=qdrant-to-es")
+	case c.SkipUnchanged:
+		return fmt.Errorf("SKIP_UNCHANGED não é suportado com DIRECTION=qdrant-to-es")
+	}
+	return nil
+}
+
+// A chave composta substitui IDField, por isso não combina com as opções que
+// filtram ou ordenam pelo ID numérico
+func (c *Config) validateIDFields() error {
+	if len(c.IDFields) == 0 {
+		return nil
+	}
+	for i, field := range c.IDFields {
+		if slices.Contains(c.IDFields[:i], field) {
+			return fmt.Errorf("ID_FIELDS repete o campo %q", field)
+		}
+	}
+	switch {
+	case c.ResumeFromQdrant:
+		return fmt.Errorf("ID_FIELDS não pode ser usado com RESUME_FROM_QDRANT: os IDs gerados não são numéricos")
+	case c.MinID != nil || c.MaxID != nil:
+		return fmt.Errorf("ID_FIELDS não pode ser usado com MIN_ID e MAX_ID: os IDs gerados não são numéricos")
+	case slices.Contains(c.IDFields, c.EmbeddingField):
+		return fmt.Errorf("EMBEDDING_FIELD não pode fazer parte de ID_FIELDS")
+	}
+	return nil
+}
+
+// O namespace muda todos os IDs gravados, então vale para uma única forma de
+// transformação e exige que os IDs da coleção sejam os do documento
+func (c *Config) validateIDNamespace() error {
+	switch {
+	case c.IDPrefix == "" && c.IDOffset == 0:
+		return nil
+	case c.IDPrefix != "" && c.IDOffset > 0:
+		return fmt.Errorf("ID_PREFIX e ID_OFFSET são excludentes")
+	case c.Direction != "es-to-qdrant":
+		return fmt.Errorf("ID_PREFIX e ID_OFFSET valem apenas para a exportação para o Qdrant")
+	case c.ResumeFromQdrant:
+		return fmt.Errorf("ID_PREFIX e ID_OFFSET não podem ser usados com RESUME_FROM_QDRANT: os IDs da coleção não são os do Elasticsearch")
+	}
+	for _, index := range c.PayloadIndexes {
+		if index.Field == idNamespaceField {
+			return nil
+		}
+	}
+	// Filtro do -prune e do -diff pelos pontos do namespace
+	c.PayloadIndexes = append(c.PayloadIndexes, payloadIndex{Field: idNamespaceField, Type: "keyword"})
+	return nil
+}
+
+// Namespace gravado no payload dos pontos, "prefix:<ID_PREFIX>" ou
+// "offset:<ID_OFFSET>"; vazio sem transformação dos IDs
+func (c *Config) idNamespace() string {
+	switch {
+	case c.IDPrefix != "":
+		return "prefix:" + c.IDPrefix
+	case c.IDOffset > 0:
+		return "offset:" + strconv.FormatUint(c.IDOffset, 10)
+	}
+	return ""
+}
+
+// Campo ou campos de origem do ID do ponto, para logs
+func (c *Config) idDescription() string {
+	if len(c.IDFields) > 0 {
+		return strings.Join(c.IDFields, "+")
+	}
+	return c.IDField
+}
+
+// Campo usado como ID que não é copiado para o payload: IDField, exceto com
+// a chave composta ou o namespace dos IDs, em que o ID do ponto não é o do
+// documento e o campo segue SOURCE_FIELDS
+func (c *Config) idPayloadField() string {
+	if len(c.IDFields) > 0 || c.idNamespace() != "" {
+		return ""
+	}
+	return c.IDField
+}
+
+// O intervalo de IDs é um filtro range em IDField, que precisa ser numérico
+func (c *Config) validateIDRange() error {
+	if c.MinID == nil && c.MaxID == nil {
+		return nil
+	}
+	switch {
+	case c.IDField == "_id":
+		return fmt.Errorf("MIN_ID e MAX_ID requerem ID_FIELD numérico: o _id do Elasticsearch não aceita filtros de intervalo")
+	case c.MinID != nil && c.MaxID != nil && *c.MinID > *c.MaxID:
+		return fmt.Errorf("MIN_ID (%d) não pode ser maior que MAX_ID (%d)", *c.MinID, *c.MaxID)
+	case c.Direction != "es-to-qdrant":
+		return fmt.Errorf("MIN_ID e MAX_ID valem apenas para a exportação para o Qdrant")
+	case c.Prune:
+		return fmt.Errorf("MIN_ID e MAX_ID não podem ser usados com PRUNE: os pontos fora do intervalo seriam removidos")
+	case c.Diff:
+		return fmt.Errorf("MIN_ID e MAX_ID não podem ser usados com DIFF: os pontos fora do intervalo contariam como removidos")
+	}
+	return nil
+}
+
+// Descrição do intervalo de IDs, para os logs
+func (c *Config) idRangeDescription() string {
+	switch {
+	case c.MinID != nil && c.MaxID != nil:
+		return fmt.Sprintf("%s entre %d e %d", c.IDField, *c.MinID, *c.MaxID)
+	case c.MinID != nil:
+		return fmt.Sprintf("%s a partir de %d", c.IDField, *c.MinID)
+	default:
+		return fmt.Sprintf("%s até %d", c.IDField, *c.MaxID)
+	}
+}
+
+// A retomada pela coleção depende de IDs numéricos lidos em ordem crescente,
+// gravados um a um na coleção de COLLECTION_NAME
+func (c *Config) validateResumeFromQdrant() error {
+	if !c.ResumeFromQdrant {
+		return nil
+	}
+	switch {
+	case c.IDField == "_id":
+		return fmt.Errorf("RESUME_FROM_QDRANT requer ID_FIELD numérico: o _id do Elasticsearch não aceita filtros de intervalo")
+	case c.PaginationMode != "search_after" || c.SortField != c.IDField:
+		return fmt.Errorf("RESUME_FROM_QDRANT requer PAGINATION_MODE=search_after e SORT_FIELD=%s, para ler os documentos em ordem crescente de ID", c.IDField)
+	case c.Checkpoint != "":
+		return fmt.Errorf("RESUME_FROM_QDRANT e CHECKPOINT são excludentes")
+	case c.SyncField != "":
+		return fmt.Errorf("RESUME_FROM_QDRANT não pode ser usado com SYNC_FIELD")
+	case c.Prune:
+		return fmt.Errorf("RESUME_FROM_QDRANT não pode ser usado com PRUNE: a retomada não lê todos os documentos")
+	case c.Output != "":
+		return fmt.Errorf("RESUME_FROM_QDRANT não pode ser usado com OUTPUT")
+	case c.CollectionTemplate != "":
+		return fmt.Errorf("RESUME_FROM_QDRANT não pode ser usado com COLLECTION_TEMPLATE")
+	case c.MaxChars > 0 && c.LongText == "chunk":
+		return fmt.Errorf("RESUME_FROM_QDRANT não pode ser usado com LONG_TEXT=chunk: os pontos dos trechos não têm IDs numéricos")
+	case c.Direction != "es-to-qdrant" || c.Verify > 0:
+		return fmt.Errorf("RESUME_FROM_QDRANT vale apenas para a exportação para o Qdrant")
+	}
+	return nil
+}
+
+// A diferença lê todos os documentos da consulta e uma única coleção
+func (c *Config) validateDiff() error {
+	switch {
+	case !c.Diff:
+		return nil
+	case c.Direction != "es-to-qdrant" || c.Verify > 0:
+		return fmt.Errorf("DIFF não pode ser usado com DIRECTION=%s nem com VERIFY", c.Direction)
+	case c.Output != "":
+		return fmt.Errorf("DIFF não pode ser usado com OUTPUT")
+	case c.CollectionTemplate != "":
+		return fmt.Errorf("DIFF não pode ser usado com COLLECTION_TEMPLATE: os pontos ficam em várias coleções")
+	case c.SyncField != "" || c.ResumeFromQdrant || c.Limit > 0:
+		return fmt.Errorf("DIFF não pode ser usado com SYNC_FIELD, RESUME_FROM_QDRANT nem LIMIT: os documentos não lidos contariam como removidos")
+	case c.Prune || c.PayloadOnly:
+		return fmt.Errorf("DIFF não grava na coleção; remova PRUNE e PAYLOAD_ONLY")
+	}
+	return nil
+}
+
+// A amostra lê uma única página do Elasticsearch e substitui os demais modos
+func (c *Config) validateSamplePayloads() error {
+	switch {
+	case c.SamplePayloads == 0:
+		return nil
+	case c.SamplePayloads < 0 || c.SamplePayloads > maxVerifySample:
+		return fmt.Errorf("SAMPLE_PAYLOADS deve estar entre 0 e %d", maxVerifySample)
+	case c.Direction != "es-to-qdrant" || c.Verify > 0 || c.Diff:
+		return fmt.Errorf("SAMPLE_PAYLOADS não pode ser usado com DIRECTION=%s, VERIFY nem DIFF", c.Direction)
+	}
+	return nil
+}
+
+// A recriação apaga todos os pontos, então exige uma exportação completa para
+// uma única coleção
+func (c *Config) validateRecreate() error {
+	switch {
+	case !c.Recreate:
+		return nil
+	case c.Direction != "es-to-qdrant" || c.Verify > 0 || c.Diff:
+		return fmt.Errorf("RECREATE vale apenas para a exportação para o Qdrant, sem VERIFY nem DIFF")
+	case c.Output != "" || c.PayloadOnly:
+		return fmt.Errorf("RECREATE não pode ser usado com OUTPUT nem PAYLOAD_ONLY")
+	case c.CollectionTemplate != "":
+		return fmt.Errorf("RECREATE não pode ser usado com COLLECTION_TEMPLATE: as coleções são criadas no primeiro upsert")
+	case c.Checkpoint != "" || c.SyncField != "" || c.ResumeFromQdrant:
+		return fmt.Errorf("RECREATE não pode ser usado com CHECKPOINT, SYNC_FIELD nem RESUME_FROM_QDRANT: a coleção recriada precisa de todos os documentos")
+	}
+	return nil
+}
+
+// As chaves de shard só existem nas coleções gravadas no Qdrant
+func (c *Config) validateShardKey() error {
+	switch {
+	case c.ShardKeyField == "":
+		return nil
+	case c.Direction != "es-to-qdrant":
+		return fmt.Errorf("SHARD_KEY_FIELD vale apenas para a exportação para o Qdrant")
+	case c.Output != "":
+		return fmt.Errorf("SHARD_KEY_FIELD não pode ser usado com OUTPUT: os pontos não são gravados no Qdrant")
+	case strings.TrimSpace(c.ShardKeyDefault) == "":
+		return fmt.Errorf("SHARD_KEY_DEFAULT não pode ser vazio com SHARD_KEY_FIELD")
+	}
+	return nil
+}
+
+// Nomes únicos, dimensões e distâncias válidas e exatamente um vetor para o
+// embedding, com a dimensão de VECTOR_SIZE conferida nos documentos
+func (c *Config) validateNamedVectors() error {
+	if len(c.NamedVectors) == 0 {
+		return nil
+	}
+	if c.Direction != "es-to-qdrant" {
+		return fmt.Errorf("NAMED_VECTORS vale apenas para a exportação para o Qdrant")
+	}
+	names := make(map[string]bool, len(c.NamedVectors))
+	embedding := 0
+	for _, vector := range c.NamedVectors {
+		switch {
+		case strings.TrimSpace(vector.Name) == "":
+			return fmt.Errorf("NAMED_VECTORS: todo vetor precisa de um nome")
+		case names[vector.Name]:
+			return fmt.Errorf("NAMED_VECTORS: vetor '%s' declarado mais de uma vez", vector.Name)
+		case c.SparseVectors && vector.Name == c.SparseVectorName:
+			return fmt.Errorf("NAMED_VECTORS: o vetor '%s' tem o nome do vetor esparso (SPARSE_VECTOR_NAME)", vector.Name)
+		case vector.Size <= 0:
+			return fmt.Errorf("NAMED_VECTORS: o vetor '%s' precisa de uma dimensão maior que zero", vector.Name)
+		case vector.Field != "" && vector.EmbedField != "":
+			return fmt.Errorf("NAMED_VECTORS: o vetor '%s' não pode ter \"field\" e \"embed_field\"", vector.Name)
+		case vector.EmbedField != "" && c.EmbeddingField != "":
+			return fmt.Errorf("NAMED_VECTORS: o vetor '%s' é gerado pelo embedder (embed_field), que EMBEDDING_FIELD substitui", vector.Name)
+		case vector.Field == "" && vector.Size != c.VectorSize:
+			return fmt.Errorf("NAMED_VECTORS: o vetor '%s' recebe um embedding gerado e deve ter a dimensão de VECTOR_SIZE (%d)", vector.Name, c.VectorSize)
+		}
+		if _, ok := distances[vector.Distance]; !ok {
+			return fmt.Errorf("NAMED_VECTORS: distância %q do vetor '%s' inválida (use cosine, dot, euclid ou manhattan)", vector.Distance, vector.Name)
+		}
+		if c.VectorDatatype == "uint8" && vector.Distance != "euclid" && vector.Distance != "manhattan" {
+			return fmt.Errorf("VECTOR_DATATYPE=uint8 exige DISTANCE euclid ou manhattan, e o vetor '%s' usa %s", vector.Name, vector.Distance)
+		}
+		names[vector.Name] = true
+		if vector.receivesEmbedding() {
+			embedding++
+		}
+	}
+	if embedding != 1 {
+		return fmt.Errorf("NAMED_VECTORS deve ter exatamente um vetor sem \"field\" nem \"embed_field\", que recebe o embedding do documento; encontrados %d", embedding)
+	}
+	return nil
+}
+
+// A carga em massa altera a configuração de uma única coleção gravada
+func (c *Config) validateBulkLoad() error {
+	switch {
+	case !c.BulkLoad:
+		return nil
+	case c.Direction != "es-to-qdrant" || c.Verify > 0 || c.Diff:
+		return fmt.Errorf("BULK_LOAD vale apenas para a exportação para o Qdrant, sem VERIFY nem DIFF")
+	case c.Output != "" || c.PayloadOnly:
+		return fmt.Errorf("BULK_LOAD não pode ser usado com OUTPUT nem PAYLOAD_ONLY: nenhum ponto é indexado")
+	case c.CollectionTemplate != "":
+		return fmt.Errorf("BULK_LOAD não pode ser usado com COLLECTION_TEMPLATE: as coleções são criadas no primeiro upsert")
+	}
+	return nil
+}
+
+// Maior quantidade de fatias aceita pelo Elasticsearch com o padrão de
+// index.max_slices_per_scroll
+const maxScrollSlices = 1024
+
+// As fatias do scroll são lidas em paralelo, sem uma ordem entre os
+// documentos de fatias diferentes
+func (c *Config) validateScrollSlices() error {
+	switch {
+	case c.ScrollSlices < 1 || c.ScrollSlices > maxScrollSlices:
+		return fmt.Errorf("SCROLL_SLICES deve estar entre 1 e %d (index.max_slices_per_scroll)", maxScrollSlices)
+	case c.ScrollSlices == 1:
+		return nil
+	case c.PaginationMode != "scroll":
+		return fmt.Errorf("SCROLL_SLICES requer PAGINATION_MODE=scroll")
+	case c.Direction != "es-to-qdrant" || c.Verify > 0 || c.Diff || c.SamplePayloads > 0:
+		return fmt.Errorf("SCROLL_SLICES vale apenas para a exportação para o Qdrant, sem VERIFY, DIFF nem SAMPLE_PAYLOADS")
+	case c.Checkpoint != "":
+		return fmt.Errorf("SCROLL_SLICES não pode ser usado com CHECKPOINT: as fatias são lidas em paralelo, sem uma ordem para retomar")
+	}
+	return nil
+}
+
+// Sem a criação, a coleção precisa existir antes da gravação no Qdrant
+func (c *Config) validateSkipCreate() error {
+	switch {
+	case !c.SkipCreate:
+		return nil
+	case c.Direction != "es-to-qdrant" || c.Verify > 0 || c.Diff:
+		return fmt.Errorf("SKIP_CREATE vale apenas para a exportação para o Qdrant, sem VERIFY nem DIFF")
+	case c.Output != "":
+		return fmt.Errorf("SKIP_CREATE não pode ser usado com OUTPUT: os pontos não são gravados no Qdrant")
+	case c.PayloadOnly:
+		return fmt.Errorf("SKIP_CREATE não pode ser usado com PAYLOAD_ONLY, que já exige uma coleção existente")
+	case c.Recreate:
+		return fmt.Errorf("SKIP_CREATE não pode ser usado com RECREATE: a coleção apagada não seria criada de novo")
+	}
+	return nil
+}
+
+// O roteamento por tenant vale apenas para a gravação: os modos que leem uma
+// única coleção não sabem em quais coleções os pontos foram gravados
+func (c *Config) validateCollectionTemplate() error {
+	if c.CollectionTemplate == "" {
+		return nil
+	}
+	switch {
+	case len(templateFields(c.CollectionTemplate)) == 0:
+		return fmt.Errorf("COLLECTION_TEMPLATE deve conter ao menos um campo, ex: docs_{tenant_id}")
+	case c.Direction != "es-to-qdrant":
+		return fmt.Errorf("COLLECTION_TEMPLATE não é suportado com DIRECTION=%s", c.Direction)
+	case c.Verify > 0:
+		return fmt.Errorf("COLLECTION_TEMPLATE não pode ser usado com VERIFY")
+	case c.Prune:
+		return fmt.Errorf("COLLECTION_TEMPLATE não pode ser usado com PRUNE")
+	case c.Output != "":
+		return fmt.Errorf("COLLECTION_TEMPLATE não pode ser usado com OUTPUT")
+	}
+	return nil
+}
+
+// Com OUTPUT os pontos vão para o arquivo, sem conexão com o Qdrant, então
+// os modos que leem ou alteram a coleção não são suportados
+func (c *Config) validateOutput() error {
+	switch {
+	case c.Output == "":
+		return nil
+	case c.Direction != "es-to-qdrant":
+		return fmt.Errorf("OUTPUT não é suportado com DIRECTION=%s", c.Direction)
+	case c.Verify > 0:
+		return fmt.Errorf("OUTPUT não pode ser usado com VERIFY")
+	case c.Prune:
+		return fmt.Errorf("OUTPUT não pode ser usado com PRUNE")
+	case c.SkipUnchanged:
+		return fmt.Errorf("OUTPUT não pode ser usado com SKIP_UNCHANGED: os hashes gravados são consultados no Qdrant")
+	case c.DryRun:
+		return fmt.Errorf("OUTPUT não pode ser usado com DRY_RUN: a saída em arquivo já não grava no Qdrant")
+	}
+	return nil
+}
+
+// -payload-only reaproveita os vetores gravados, então não combina com as
+// opções que dependem de gerar embeddings ou de criar coleções
+func (c *Config) validatePayloadOnly() error {
+	switch {
+	case !c.PayloadOnly:
+		if c.PayloadOverwrite {
+			return fmt.Errorf("PAYLOAD_OVERWRITE exige PAYLOAD_ONLY")
+		}
+		return nil
+	case c.Direction != "es-to-qdrant" || c.Verify > 0:
+		return fmt.Errorf("PAYLOAD_ONLY só se aplica à migração do Elasticsearch para o Qdrant")
+	case c.Output != "":
+		return fmt.Errorf("PAYLOAD_ONLY não pode ser usado com OUTPUT: não há pontos existentes a atualizar")
+	case c.CollectionTemplate != "":
+		return fmt.Errorf("PAYLOAD_ONLY não pode ser usado com COLLECTION_TEMPLATE: as coleções não são criadas")
+	case c.DryRunEmbed:
+		return fmt.Errorf("PAYLOAD_ONLY não gera embeddings; remova DRY_RUN_EMBED")
+	case c.Normalize:
+		return fmt.Errorf("PAYLOAD_ONLY não pode ser usado com NORMALIZE: os vetores não são alterados")
+	case c.EmbeddingMetadata:
+		return fmt.Errorf("PAYLOAD_ONLY não pode ser usado com EMBEDDING_METADATA: o text_hash deixaria de corresponder ao vetor gravado")
+	}
+	return nil
+}
+
+// Indica se os embeddings serão gerados: não em dry-run sem DryRunEmbed, com
+// PayloadOnly nem com os vetores lidos de EmbeddingField
+func (c *Config) generatesEmbeddings() bool {
+	return !c.PayloadOnly && c.EmbeddingField == "" && (!c.DryRun || c.DryRunEmbed)
+}
+
+// Indica se os documentos chegam ao upsert com vetor, gerado pelo embedder ou
+// lido de EmbeddingField
+func (c *Config) hasVectors() bool {
+	return c.generatesEmbeddings() || c.EmbeddingField != ""
+}
+
+// Métricas de distância aceitas em DISTANCE
+var distances = map[string]qdrant.Distance{
+	"cosine":    qdrant.Distance_Cosine,
+	"dot":       qdrant.Distance_Dot,
+	"euclid":    qdrant.Distance_Euclid,
+	"manhattan": qdrant.Distance_Manhattan,
+}
+
+func (c *Config) distance() qdrant.Distance {
+	return distances[c.Distance]
+}
+
+// Tipos aceitos em PAYLOAD_INDEXES
+var payloadIndexTypes = map[string]qdrant.FieldType{
+	"keyword":  qdrant.FieldType_FieldTypeKeyword,
+	"integer":  qdrant.FieldType_FieldTypeInteger,
+	"float":    qdrant.FieldType_FieldTypeFloat,
+	"bool":     qdrant.FieldType_FieldTypeBool,
+	"geo":      qdrant.FieldType_FieldTypeGeo,
+	"datetime": qdrant.FieldType_FieldTypeDatetime,
+}
+
+// Índice de payload: campo (aceita caminhos com pontos) e tipo
+type payloadIndex struct {
+	Field string
+	Type  string
+}
+
+// Interpreta a lista "campo:tipo,campo:tipo"
+func parsePayloadIndexes(v string) ([]payloadIndex, error) {
+	var indexes []payloadIndex
+	for _, item := range splitList(v) {
+		field, typ, ok := strings.Cut(item, ":")
+		field, typ = strings.TrimSpace(field), strings.TrimSpace(typ)
+		if !ok || field == "" {
+			return nil, fmt.Errorf("%q não está no formato campo:tipo", item)
+		}
+		if _, ok := payloadIndexTypes[typ]; !ok {
+			return nil, fmt.Errorf("tipo %q do campo %q inválido (use keyword, integer, float, bool, geo ou datetime)", typ, field)
+		}
+		indexes = append(indexes, payloadIndex{Field: field, Type: typ})
+	}
+	return indexes, nil
+}
+
+// Interpreta o mapeamento campo:chave de PAYLOAD_RENAME
+func parsePayloadRename(v string) (map[string]string, error) {
+	rename := make(map[string]string)
+	for _, item := range splitList(v) {
+		field, key, ok := strings.Cut(item, ":")
+		field, key = strings.TrimSpace(field), strings.TrimSpace(key)
+		if !ok || field == "" || key == "" {
+			return nil, fmt.Errorf("%q não está no formato campo:chave", item)
+		}
+		if _, ok := rename[field]; ok {
+			return nil, fmt.Errorf("campo %q renomeado mais de uma vez", field)
+		}
+		rename[field] = key
+	}
+	return rename, nil
+}
+
+// Chave do payload do campo: a de PayloadRename ou o próprio nome
+func (c *Config) payloadKey(field string) string {
+	if key, ok := c.PayloadRename[field]; ok {
+		return key
+	}
+	return field
+}
+
+// Confere se cada campo renomeado é gravado no payload e se as chaves finais
+// não se repetem, o que faria um campo sobrescrever o outro
+func (c *Config) validatePayloadRename() error {
+	if len(c.PayloadRename) == 0 {
+		return nil
+	}
+
+	fields := []string{textPayloadKey}
+	for _, field := range c.payloadFields() {
+		if field != c.idPayloadField() && field != c.TextField {
+			fields = append(fields, field)
+		}
+	}
+	for field := range c.PayloadRename {
+		if !slices.Contains(fields, field) && !c.matchesSourcePattern(field) {
+			return fmt.Errorf("PAYLOAD_RENAME renomeia %q, que não é gravado no payload; verifique SOURCE_FIELDS", field)
+		}
+	}
+
+	// Chaves gravadas fora dos campos do _source
+	keys := make(map[string]string)
+	if c.IndexField != "" {
+		keys[c.IndexField] = "INDEX_FIELD"
+	}
+	if c.SkipUnchanged {
+		keys[contentHashField] = "SKIP_UNCHANGED"
+	}
+	if c.MaxChars > 0 && c.LongText == "chunk" {
+		keys[chunkIndexField] = "LONG_TEXT"
+		keys[parentIDField] = "LONG_TEXT"
+	}
+	if c.PointTTL > 0 {
+		keys[expiresAtField] = "POINT_TTL"
+	}
+	if c.idNamespace() != "" {
+		keys[idNamespaceField] = "ID_PREFIX/ID_OFFSET"
+	}
+	if c.EmbeddingMetadata {
+		keys[textHashField] = "EMBEDDING_METADATA"
+		keys[embeddingModelField] = "EMBEDDING_METADATA"
+	}
+	for _, field := range fields {
+		key := c.payloadKey(field)
+		if other, ok := keys[key]; ok {
+			return fmt.Errorf("PAYLOAD_RENAME gera a chave %q do payload para %q e %q", key, other, field)
+		}
+		keys[key] = field
+	}
+	return nil
+}
+
+// Os campos geo_point vão para o payload, e não para o ID, o texto ou o
+// vetor. Com GEO_INDEX, inclui em PayloadIndexes o índice geo de cada campo
+// ainda não pedido em PAYLOAD_INDEXES.
+func (c *Config) validateGeoFields() error {
+	switch {
+	case len(c.GeoFields) == 0 && c.GeoIndex:
+		return fmt.Errorf("GEO_INDEX requer GEO_FIELDS")
+	case len(c.GeoFields) == 0:
+		return nil
+	case c.Direction != "es-to-qdrant":
+		return fmt.Errorf("GEO_FIELDS vale apenas para a exportação para o Qdrant")
+	}
+	for _, field := range c.GeoFields {
+		switch {
+		case isFieldPattern(field):
+			return fmt.Errorf("GEO_FIELDS não aceita curingas: %q", field)
+		case field == c.IDField || field == c.TextField || field == c.EmbeddingField:
+			return fmt.Errorf("o campo geo_point %q não pode ser ID_FIELD, TEXT_FIELD nem EMBEDDING_FIELD", field)
+		}
+	}
+	if !c.GeoIndex {
+		return nil
+	}
+	for _, field := range c.GeoFields {
+		key := c.payloadKey(field)
+		if !slices.ContainsFunc(c.PayloadIndexes, func(index payloadIndex) bool { return index.Field == key }) {
+			c.PayloadIndexes = append(c.PayloadIndexes, payloadIndex{Field: key, Type: "geo"})
+		}
+	}
+	return nil
+}
+
+// Valida POINT_TTL e inclui o índice de expires_at em PayloadIndexes com
+// POINT_TTL_INDEX, se ainda não foi pedido em PAYLOAD_INDEXES
+func (c *Config) validatePointTTL() error {
+	if c.PointTTL < 0 {
+		return fmt.Errorf("POINT_TTL não pode ser negativo")
+	}
+	if c.PointTTLIndex && c.PointTTL == 0 {
+		return fmt.Errorf("POINT_TTL_INDEX requer POINT_TTL")
+	}
+	if c.PointTTL > 0 && len(c.PayloadRename) == 0 {
+		for _, field := range c.payloadFields() {
+			if field == expiresAtField {
+				return fmt.Errorf("POINT_TTL grava %q no payload, que já recebe o campo de mesmo nome do _source; use PAYLOAD_RENAME para renomeá-lo", field)
+			}
+		}
+	}
+	if !c.PointTTLIndex {
+		return nil
+	}
+	for _, index := range c.PayloadIndexes {
+		if index.Field == expiresAtField {
+			return nil
+		}
+	}
+	c.PayloadIndexes = append(c.PayloadIndexes, payloadIndex{Field: expiresAtField, Type: "datetime"})
+	return nil
+}
+
+// Esquema e host de ES_URL, sem o caminho
+func (c *Config) esBaseURL() string {
+	u, err := url.Parse(c.ESURL)
+	if err != nil {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// Porta gRPC do Qdrant quando QDRANT_URL não informa a porta
+const defaultQdrantPort = 6334
+
+// Preenche host, porta e TLS do Qdrant a partir de QDRANT_URL. Os esquemas
+// grpc e http conectam sem TLS, e grpcs e https com TLS; a conexão é sempre
+// gRPC, então a porta deve ser a do gRPC (6334 por padrão), e não a 6333 da
+// API REST.
+func (c *Config) applyQdrantURL() error {
+	u, err := url.Parse(c.QdrantURL)
+	if err != nil {
+		return fmt.Errorf("QDRANT_URL inválida: %v", err)
+	}
+	switch u.Scheme {
+	case "grpc", "http":
+		c.QdrantTLS = false
+	case "grpcs", "https":
+		c.QdrantTLS = true
+	default:
+		return fmt.Errorf("QDRANT_URL com esquema inválido: %q (use grpc, grpcs, http ou https)", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return fmt.Errorf("QDRANT_URL sem host: %q", c.QdrantURL)
+	}
+	if strings.Trim(u.Path, "/") != "" || u.RawQuery != "" || u.User != nil {
+		return fmt.Errorf("QDRANT_URL deve ter apenas esquema, host e porta: %q", c.QdrantURL)
+	}
+
+	port := defaultQdrantPort
+	if p := u.Port(); p != "" {
+		if port, err = strconv.Atoi(p); err != nil || port <= 0 || port > 65535 {
+			return fmt.Errorf("QDRANT_URL com porta inválida: %q (use de 1 a 65535)", p)
+		}
+	}
+	c.QdrantHost = u.Hostname()
+	c.QdrantPort = port
+	return nil
+}
+
+// URL da API suffix (como "/_count") nos índices de ES_URL
+func (c *Config) indexURL(suffix string) string {
+	return c.esBaseURL() + "/" + escapeIndex(c.indexName()) + suffix
+}
+
+// Codifica a expressão de índices para o caminho da URL. Cada item da lista
+// separada por vírgulas é codificado separadamente, de modo que as vírgulas,
+// os curingas e o ":" dos clusters remotos (cluster:indice) continuam
+// literais, enquanto caracteres como os da date math (<logs-{now/d}>) e
+// espaços são escapados.
+func escapeIndex(index string) string {
+	items := strings.Split(index, ",")
+	for i, item := range items {
+		items[i] = strings.ReplaceAll(url.PathEscape(item), "%2A", "*")
+	}
+	return strings.Join(items, ",")
+}
+
+// Nome do índice (ou alias) no caminho de ES_URL, antes de /_search, já
+// decodificado
+func (c *Config) indexName() string {
+	u, err := url.Parse(c.ESURL)
+	if err != nil {
+		return ""
+	}
+	path := strings.TrimSuffix(strings.Trim(u.Path, "/"), "_search")
+	return strings.Trim(path, "/")
+}
+
+// Campos pedidos no _source; o campo de ID, os campos do embedding, o campo
+// de sincronização e os do template da coleção são sempre incluídos por serem
+// usados como ID do ponto, entrada do embedding, marca da sincronização
+// incremental e destino do documento
+func (c *Config) sourceIncludes() []string {
+	fields := append([]string(nil), c.SourceFields...)
+	for _, required := range c.requiredSourceFields() {
+		if !slices.Contains(fields, required) {
+			fields = append(fields, required)
+		}
+	}
+	return fields
+}
+
+// Filtro _source da busca: a lista de sourceIncludes ou, com SourceExcludes,
+// o objeto com includes e excludes
+func (c *Config) sourceFilter() interface{} {
+	if len(c.SourceExcludes) == 0 {
+		return c.sourceIncludes()
+	}
+	return map[string][]string{"includes": c.sourceIncludes(), "excludes": c.SourceExcludes}
+}
+
+// Campos do _source que a exportação usa além de SourceFields
+func (c *Config) requiredSourceFields() []string {
+	required := []string{c.TextField}
+	if len(c.EmbedFields) > 0 {
+		required = append([]string(nil), c.EmbedFields...)
+	}
+	if len(c.IDFields) > 0 {
+		for _, field := range c.IDFields {
+			if field != "_id" {
+				required = append(required, field)
+			}
+		}
+	} else if c.IDField != "_id" {
+		required = append(required, c.IDField)
+	}
+	if c.SyncField != "" {
+		required = append(required, c.SyncField)
+	}
+	if c.EmbeddingField != "" {
+		required = append(required, c.EmbeddingField)
+	}
+	required = append(required, templateFields(c.CollectionTemplate)...)
+	if c.ShardKeyField != "" {
+		required = append(required, c.ShardKeyField)
+	}
+	for _, vector := range c.NamedVectors {
+		if vector.Field != "" {
+			required = append(required, vector.Field)
+		}
+		if vector.EmbedField != "" {
+			required = append(required, vector.EmbedField)
+		}
+	}
+	required = append(required, c.GeoFields...)
+	return required
+}
+
+// Os campos descartados não podem remover os que a exportação usa, como o
+// ID e o texto: o Elasticsearch aplica os excludes depois dos includes
+func (c *Config) validateSourceExcludes() error {
+	for _, field := range c.requiredSourceFields() {
+		if pattern, ok := c.excludedField(field); ok {
+			return fmt.Errorf("SOURCE_EXCLUDES descarta %q com o padrão %q, mas o campo é usado na exportação", field, pattern)
+		}
+	}
+	return nil
+}
+
+// Indica se o caminho é gravado por um dos padrões com curinga de
+// SourceFields
+func (c *Config) matchesSourcePattern(path string) bool {
+	for _, field := range c.SourceFields {
+		if isFieldPattern(field) && matchFieldPattern(field, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// Padrão de SourceExcludes que descarta o caminho ou um dos objetos que o
+// contêm, como no Elasticsearch
+func (c *Config) excludedField(path string) (string, bool) {
+	for _, pattern := range c.SourceExcludes {
+		prefix := path
+		for {
+			if matchFieldPattern(pattern, prefix) {
+				return pattern, true
+			}
+			i := strings.LastIndex(prefix, ".")
+			if i < 0 {
+				break
+			}
+			prefix = prefix[:i]
+		}
+	}
+	return "", false
+}
+
+// Nomes dos campos de runtime_mappings, pedidos no parâmetro fields da busca;
+// os de script_fields são retornados em hit.fields sem precisar pedir
+func (c *Config) runtimeFields() []string {
+	fields, _ := jsonObjectKeys(c.RuntimeMappings)
+	return fields
+}
+
+// Chaves de um objeto JSON, em ordem alfabética; vazio não tem chaves
+func jsonObjectKeys(v string) ([]string, error) {
+	if v == "" {
+		return nil, nil
+	}
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(v), &obj); err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys, nil
+}
+
+// Campos copiados para o payload: SourceFields e, separadamente, cada campo
+// combinado na entrada do embedding e cada campo geo_point
+func (c *Config) payloadFields() []string {
+	fields := append([]string(nil), c.SourceFields...)
+	for _, field := range append(append([]string(nil), c.EmbedFields...), c.GeoFields...) {
+		if !slices.Contains(fields, field) {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// Os vetores lidos do Elasticsearch substituem o embedder e valem para o
+// documento inteiro
+func (c *Config) validateEmbeddingField() error {
+	switch {
+	case c.EmbeddingField == "":
+		return nil
+	case c.Embedder != "openai":
+		return fmt.Errorf("EMBEDDING_FIELD e EMBEDDER=%s são excludentes: os vetores são lidos do Elasticsearch", c.Embedder)
+	case c.EmbeddingField == c.IDField || c.EmbeddingField == c.TextField:
+		return fmt.Errorf("EMBEDDING_FIELD deve ser diferente de ID_FIELD e TEXT_FIELD")
+	case c.MaxChars > 0 && c.LongText == "chunk":
+		return fmt.Errorf("EMBEDDING_FIELD não pode ser usado com LONG_TEXT=chunk: o vetor do documento não corresponde aos trechos")
+	case c.PayloadOnly:
+		return fmt.Errorf("EMBEDDING_FIELD não pode ser usado com PAYLOAD_ONLY: os vetores não são gravados")
+	}
+	return nil
+}
+
+// Identificação do modelo de embeddings, usada na chave do cache e no
+// payload: o modelo da OpenAI, a URL do servidor próprio, "fake" ou, com
+// EmbeddingField, o campo de origem dos vetores
+func (c *Config) embeddingModel() string {
+	if c.EmbeddingField != "" {
+		return "elasticsearch:" + c.EmbeddingField
+	}
+	switch c.Embedder {
+	case "http":
+		return c.EmbedURL
+	case "fake":
+		return "fake"
+	}
+	return c.OpenAIModel
+}
+
+// Interpreta cabeçalhos no formato "Nome: valor", separados por ";"
+func parseHeaders(v string) (map[string]string, error) {
+	headers := make(map[string]string)
+	for _, item := range strings.Split(v, ";") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(item, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("cabeçalho %q não está no formato \"Nome: valor\"", strings.TrimSpace(item))
+		}
+		headers[name] = strings.TrimSpace(value)
+	}
+	return headers, nil
+}
+
+// Divide uma lista separada por vírgulas, ignorando itens vazios
+func splitList(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func getEnv(key, fallback string) string {
+	if v, ok := os.LookupEnv(key); ok && v != "" {
+		return v
+	}
+	return fallback
+}
+
+func getEnvBool(key string, fallback bool) (bool, error) {
+	v, ok := os.LookupEnv(key)
+	if !ok || v == "" {
+		return fallback, nil
+	}
+
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("valor inválido para %s: %q", key, v)
+	}
+	return b, nil
+}
+
+func getEnvFloat(key string, fallback float64) (float64, error) {
+	v, ok := os.LookupEnv(key)
+	if !ok || v == "" {
+		return fallback, nil
+	}
+
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, fmt.Errorf("valor inválido para %s: %q", key, v)
+	}
+	return f, nil
+}
+
+func getEnvDuration(key string, fallback time.Duration) (time.Duration, error) {
+	v, ok := os.LookupEnv(key)
+	if !ok || v == "" {
+		return fallback, nil
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("valor inválido para %s: %q", key, v)
+	}
+	return d, nil
+}
+
+// Limite opcional de ID; nil se a variável não está definida
+func getEnvID(key string) (*uint64, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return nil, nil
+	}
+	id, err := parseID(v)
+	if err != nil {
+		return nil, fmt.Errorf("valor inválido para %s: %q", key, v)
+	}
+	return id, nil
+}
+
+func parseID(v string) (*uint64, error) {
+	id, err := strconv.ParseUint(strings.TrimSpace(v), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("ID inválido: %q (use um inteiro não negativo)", v)
+	}
+	return &id, nil
+}
+
+func getEnvInt(key string, fallback int) (int, error) {
+	v, ok := os.LookupEnv(key)
+	if !ok || v == "" {
+		return fallback, nil
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("valor inválido para %s: %q", key, v)
+	}
+	return n, nil
+}
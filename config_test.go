@@ -0,0 +1,110 @@
+package main
+
+import "testing"
+
+func TestIndexURL(t *testing.T) {
+	tests := []struct {
+		name      string
+		index     string
+		wantURL   string
+		wantIndex string
+	}{
+		{
+			name:      "índice simples",
+			index:     "documentos",
+			wantURL:   "https://es:9200/documentos/_search",
+			wantIndex: "documentos",
+		},
+		{
+			name:      "cluster remoto e lista",
+			index:     "remoto:documentos, local-*,outro:logs-2024*",
+			wantURL:   "https://es:9200/remoto:documentos,local-*,outro:logs-2024*/_search",
+			wantIndex: "remoto:documentos,local-*,outro:logs-2024*",
+		},
+		{
+			name:      "date math",
+			index:     "<logs-{now/d}>",
+			wantURL:   "https://es:9200/%3Clogs-%7Bnow%2Fd%7D%3E/_search",
+			wantIndex: "<logs-{now/d}>",
+		},
+		{
+			name:      "caracteres especiais",
+			index:     "remoto:meu índice#1?",
+			wantURL:   "https://es:9200/remoto:meu%20%C3%ADndice%231%3F/_search",
+			wantIndex: "remoto:meu índice#1?",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("ES_URL", "https://es:9200/padrao/_search")
+			t.Setenv("ES_INDEX", tt.index)
+			t.Setenv("ES_USERNAME", "elastic")
+			t.Setenv("ES_PASSWORD", "senha")
+			t.Setenv("OPENAI_API_KEY", "sk-teste")
+
+			cfg, err := LoadConfig(nil)
+			if err != nil {
+				t.Fatalf("LoadConfig: %v", err)
+			}
+			if cfg.ESURL != tt.wantURL {
+				t.Errorf("ES_URL = %q, esperado %q", cfg.ESURL, tt.wantURL)
+			}
+			if got := cfg.indexName(); got != tt.wantIndex {
+				t.Errorf("indexName = %q, esperado %q", got, tt.wantIndex)
+			}
+			wantCount := tt.wantURL[:len(tt.wantURL)-len("/_search")] + "/_count"
+			if got := cfg.indexURL("/_count"); got != wantCount {
+				t.Errorf("indexURL = %q, esperado %q", got, wantCount)
+			}
+		})
+	}
+}
+
+func TestQdrantURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		wantHost string
+		wantPort int
+		wantTLS  bool
+		wantErr  bool
+	}{
+		{name: "grpc", url: "grpc://qdrant:6334", wantHost: "qdrant", wantPort: 6334},
+		{name: "http", url: "http://10.0.0.5:7000", wantHost: "10.0.0.5", wantPort: 7000},
+		{name: "grpcs sem porta", url: "grpcs://xyz.cloud.qdrant.io", wantHost: "xyz.cloud.qdrant.io", wantPort: 6334, wantTLS: true},
+		{name: "https com barra", url: "https://xyz.cloud.qdrant.io:6334/", wantHost: "xyz.cloud.qdrant.io", wantPort: 6334, wantTLS: true},
+		{name: "IPv6", url: "grpc://[::1]:6334", wantHost: "::1", wantPort: 6334},
+		{name: "esquema inválido", url: "tcp://qdrant:6334", wantErr: true},
+		{name: "sem esquema", url: "qdrant:6334", wantErr: true},
+		{name: "porta fora do intervalo", url: "grpc://qdrant:70000", wantErr: true},
+		{name: "porta zero", url: "grpc://qdrant:0", wantErr: true},
+		{name: "porta não numérica", url: "grpc://qdrant:abc", wantErr: true},
+		{name: "com caminho", url: "http://qdrant:6334/colecao", wantErr: true},
+		{name: "sem host", url: "grpc://:6334", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("ES_USERNAME", "elastic")
+			t.Setenv("ES_PASSWORD", "senha")
+			t.Setenv("QDRANT_HOST", "outro")
+			t.Setenv("QDRANT_TLS", "true")
+
+			cfg, err := LoadConfig([]string{"-qdrant-url", tt.url})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("LoadConfig aceitou %q", tt.url)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadConfig: %v", err)
+			}
+			if cfg.QdrantHost != tt.wantHost || cfg.QdrantPort != tt.wantPort || cfg.QdrantTLS != tt.wantTLS {
+				t.Errorf("host %q, porta %d, TLS %v; esperado %q, %d, %v",
+					cfg.QdrantHost, cfg.QdrantPort, cfg.QdrantTLS, tt.wantHost, tt.wantPort, tt.wantTLS)
+			}
+		})
+	}
+}
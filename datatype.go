@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// Tipo dos componentes dos vetores na coleção (-vector-datatype). float16
+// reduz à metade a memória dos vetores com perda de precisão desprezível para
+// embeddings; uint8 reduz a um quarto, mas o Qdrant espera inteiros de 0 a 255,
+// então os componentes, normalmente entre -1 e 1, são convertidos por
+// (x+1)*127.5, arredondados e limitados ao intervalo. A conversão afim
+// preserva a ordem das distâncias euclid e manhattan, mas não a de cosine e
+// dot. As consultas à coleção precisam aplicar a mesma conversão ao vetor.
+
+// Tipos aceitos em VECTOR_DATATYPE, com os bytes por componente
+var vectorDatatypes = map[string]struct {
+	datatype qdrant.Datatype
+	bytes    int
+}{
+	"float32": {qdrant.Datatype_Float32, 4},
+	"float16": {qdrant.Datatype_Float16, 2},
+	"uint8":   {qdrant.Datatype_Uint8, 1},
+}
+
+// Datatype do VectorParams; nil em float32 mantém o padrão do Qdrant
+func (c *Config) vectorDatatype() *qdrant.Datatype {
+	if c.VectorDatatype == "float32" {
+		return nil
+	}
+	return qdrant.PtrOf(vectorDatatypes[c.VectorDatatype].datatype)
+}
+
+// Converte os componentes de [-1, 1] para inteiros de 0 a 255, em um novo
+// slice
+func quantizeUint8(v []float32) []float32 {
+	out := make([]float32, len(v))
+	for i, x := range v {
+		out[i] = float32(math.Round(max(0, min(255, (float64(x)+1)*127.5))))
+	}
+	return out
+}
+
+// Estimativa da memória dos vetores densos por milhão de pontos, comparada
+// com float32, para os logs da criação da coleção
+func (c *Config) datatypeDescription() string {
+	bytes := vectorDatatypes[c.VectorDatatype].bytes
+	mb := float64(c.VectorSize) * float64(bytes) * 1e6 / (1 << 20)
+	if bytes == 4 {
+		return fmt.Sprintf("vetores em float32: ~%.0f MB por milhão de pontos", mb)
+	}
+	return fmt.Sprintf("vetores em %s: ~%.0f MB por milhão de pontos, %d%% menos que em float32",
+		c.VectorDatatype, mb, 100-bytes*100/4)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"container/list"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"hash"
+	"hash/fnv"
+	"sync"
+)
+
+// Algoritmos aceitos em DEDUP_HASH
+var dedupHashes = map[string]func() hash.Hash{
+	"sha256": sha256.New,
+	"sha1":   sha1.New,
+	"md5":    md5.New,
+	"fnv":    func() hash.Hash { return fnv.New64a() },
+}
+
+// Descarta, dentro de uma execução, os documentos cuja entrada do embedding
+// já foi vista. Os hashes ficam em um LRU limitado a capacity entradas, então
+// em bases muito grandes duplicatas distantes podem não ser detectadas.
+type dedupFilter struct {
+	newHash  func() hash.Hash
+	capacity int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	recent  *list.List // hashes, do mais para o menos recente
+	skipped int
+}
+
+func newDedupFilter(algorithm string, capacity int) *dedupFilter {
+	return &dedupFilter{
+		newHash:  dedupHashes[algorithm],
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		recent:   list.New(),
+	}
+}
+
+// Retorna os documentos de docs com texto ainda não visto, registrando seus
+// hashes. Documentos sem texto não são comparados.
+func (d *dedupFilter) filter(docs []DocumentData) []DocumentData {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	unique := docs[:0:0]
+	for _, doc := range docs {
+		if doc.Texto == "" {
+			unique = append(unique, doc)
+			continue
+		}
+
+		h := d.newHash()
+		h.Write([]byte(doc.Texto))
+		key := string(h.Sum(nil))
+
+		if elem, ok := d.entries[key]; ok {
+			d.recent.MoveToFront(elem)
+			d.skipped++
+			duplicatesSkipped.Inc()
+			continue
+		}
+
+		d.entries[key] = d.recent.PushFront(key)
+		if d.recent.Len() > d.capacity {
+			oldest := d.recent.Back()
+			d.recent.Remove(oldest)
+			delete(d.entries, oldest.Value.(string))
+		}
+		unique = append(unique, doc)
+	}
+	return unique
+}
+
+// Documentos descartados como duplicados
+func (d *dedupFilter) skippedCount() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.skipped
+}
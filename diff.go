@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// Diferença (-diff): compara os IDs e os content_hash da coleção com os
+// documentos da consulta configurada, sem gravar em nenhum dos backends, e
+// informa o que uma nova migração faria: documentos apenas no Elasticsearch
+// (a inserir), pontos apenas no Qdrant (a remover com -prune) e pontos com
+// hash diferente do calculado para o documento (a atualizar). Pontos gravados
+// sem -skip-unchanged não têm content_hash e são contados à parte. Retorna
+// false se a comparação não pôde ser concluída.
+func runDiff(ctx context.Context, cfg *Config, es *ElasticsearchClient, qc *QdrantClient) bool {
+	inicio := time.Now()
+
+	if !cfg.SkipPreflight {
+		if err := reversePreflight(ctx, cfg, es, qc); err != nil {
+			fatalf("Falha na verificação inicial: %v", err)
+		}
+	}
+
+	if cfg.UsePIT {
+		if err := es.openPIT(ctx); err != nil {
+			fatalf("Erro ao abrir point-in-time: %v", err)
+		}
+		defer func() {
+			if err := es.closePIT(context.WithoutCancel(ctx)); err != nil {
+				logErrorf("Erro ao fechar point-in-time: %v", err)
+			}
+		}()
+	}
+
+	log.Printf("Lendo os content_hash da coleção '%s'...", cfg.CollectionName)
+	stored, err := qc.storedHashes(ctx)
+	if err != nil {
+		logErrorf("Erro ao ler a coleção: %v", err)
+		return false
+	}
+	log.Printf("%d pontos na coleção; comparando com os documentos de '%s'...", len(stored), cfg.indexName())
+
+	var filter *docFilter
+	if len(cfg.Filters) > 0 {
+		filter = newDocFilter(cfg.Filters)
+	}
+	model := cfg.embeddingModel()
+	seen := make(map[string]struct{}, len(stored))
+	var onlyES, changed, unhashed []string
+	lidos := 0
+
+	err = es.forEachPage(ctx, func(hits []Hit) {
+		lidos += len(hits)
+		docs := make([]DocumentData, 0, len(hits))
+		for _, hit := range hits {
+			// Documentos sem ID válido só são gravados com ON_MISSING_ID=hash
+			if doc := extractDocumentData(hit, cfg); doc.InvalidID == nil || cfg.OnMissingID == "hash" {
+				docs = append(docs, doc)
+			}
+		}
+		if filter != nil {
+			docs = filter.filter(docs)
+		}
+		for _, doc := range limitTextLength(docs, cfg) {
+			key := docKey(doc)
+			if _, dup := seen[key]; dup {
+				continue
+			}
+			seen[key] = struct{}{}
+
+			hash, ok := stored[key]
+			switch {
+			case !ok:
+				onlyES = append(onlyES, key)
+			case hash == "":
+				unhashed = append(unhashed, key)
+			case hash != contentHash(doc, model):
+				changed = append(changed, key)
+			}
+		}
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			logSummaryEvent("interrupted", fmt.Sprintf("Comparação interrompida após %d documentos lidos", lidos),
+				"read", lidos, "duration_ms", durationMs(inicio))
+		} else {
+			logErrorf("Erro ao buscar documentos: %v", err)
+		}
+		return false
+	}
+
+	var onlyQdrant []string
+	for key := range stored {
+		if _, ok := seen[key]; !ok {
+			onlyQdrant = append(onlyQdrant, key)
+		}
+	}
+
+	logDiffSample("Apenas no Elasticsearch", onlyES, cfg.ErrorLogLimit)
+	logDiffSample("Apenas no Qdrant", onlyQdrant, cfg.ErrorLogLimit)
+	logDiffSample("Com conteúdo alterado", changed, cfg.ErrorLogLimit)
+	if len(unhashed) > 0 {
+		logWarnf("%d pontos sem content_hash não puderam ser comparados; grave-os com -skip-unchanged para incluí-los", len(unhashed))
+	}
+
+	logSummaryEvent("diff", fmt.Sprintf("Diferença concluída: %d apenas no Elasticsearch (a inserir), %d apenas no Qdrant (a remover), %d com conteúdo alterado (a atualizar)",
+		len(onlyES), len(onlyQdrant), len(changed)),
+		"read", lidos, "points", len(stored), "only_es", len(onlyES), "only_qdrant", len(onlyQdrant),
+		"changed", len(changed), "unhashed", len(unhashed), "duration_ms", durationMs(inicio))
+	return true
+}
+
+// Exibe até limit IDs de uma categoria da diferença
+func logDiffSample(label string, keys []string, limit int) {
+	if len(keys) == 0 || limit == 0 {
+		return
+	}
+	sample := keys[:min(limit, len(keys))]
+	suffix := ""
+	if len(keys) > len(sample) {
+		suffix = fmt.Sprintf(" e mais %d", len(keys)-len(sample))
+	}
+	log.Printf("%s: %s%s", label, strings.Join(sample, ", "), suffix)
+}
+
+// content_hash de todos os pontos da coleção (ou do namespace dos IDs) pela
+// chave de pointKey; vazio para os pontos gravados sem ele
+func (qc *QdrantClient) storedHashes(ctx context.Context) (map[string]string, error) {
+	hashes := make(map[string]string)
+	var offset *qdrant.PointId
+
+	for {
+		var points []*qdrant.RetrievedPoint
+		err := qc.do(ctx, func(ctx context.Context) error {
+			var err error
+			points, offset, err = qc.client.ScrollAndOffset(ctx, &qdrant.ScrollPoints{
+				CollectionName: qc.cfg.CollectionName,
+				Offset:         offset,
+				Limit:          qdrant.PtrOf(uint32(pruneScrollLimit)),
+				Filter:         qc.namespaceFilter(),
+				WithPayload:    qdrant.NewWithPayloadInclude(contentHashField),
+				WithVectors:    qdrant.NewWithVectors(false),
+			})
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("erro ao percorrer pontos da coleção: %v", err)
+		}
+
+		for _, point := range points {
+			hashes[pointKey(point.GetId())] = point.GetPayload()[contentHashField].GetStringValue()
+		}
+		if offset == nil {
+			return hashes, nil
+		}
+	}
+}
+
+// Percorre todas as páginas da consulta, por scroll ou search_after conforme
+// PaginationMode, chamando fn com os hits de cada uma. O contexto de scroll é
+// liberado ao final.
+func (ec *ElasticsearchClient) forEachPage(ctx context.Context, fn func(hits []Hit)) error {
+	var scrollID string
+	var after []interface{}
+	defer func() {
+		if scrollID != "" {
+			if err := ec.clearScroll(context.WithoutCancel(ctx), scrollID); err != nil {
+				logErrorf("Erro ao liberar contexto de scroll: %v", err)
+			}
+		}
+	}()
+
+	for {
+		var result *SearchResponse
+		var err error
+		stuck := false
+		if ec.cfg.PaginationMode == "search_after" {
+			var next []interface{}
+			result, next, err = ec.searchDocumentsAfter(ctx, []string{ec.cfg.SortField}, after)
+			if err == nil {
+				stuck = len(result.Hits.Hits) > 0 && (next == nil || reflect.DeepEqual(next, after))
+				after = next
+			}
+		} else {
+			result, err = ec.searchDocumentsScroll(ctx, scrollID)
+			if err == nil && result.ScrollID != "" {
+				scrollID = result.ScrollID
+			}
+		}
+		if err != nil {
+			return err
+		}
+		if len(result.Hits.Hits) == 0 {
+			return nil
+		}
+		fn(result.Hits.Hits)
+		if stuck {
+			return fmt.Errorf("o cursor search_after não avançou; verifique se SORT_FIELD existe em todos os documentos")
+		}
+	}
+}
@@ -0,0 +1,808 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Estruturas para resposta do Elasticsearch
+type Hit struct {
+	Index  string                   `json:"_index"`
+	ID     string                   `json:"_id"`
+	Source map[string]interface{}   `json:"_source"`
+	Fields map[string][]interface{} `json:"fields,omitempty"`
+	Sort   []interface{}            `json:"sort,omitempty"`
+}
+
+// Campos do documento para a extração: o _source e, por cima dele, os campos
+// calculados de hit.fields (runtime_mappings e script_fields). O Elasticsearch
+// sempre devolve esses valores em arrays; um array de um único valor vira o
+// próprio valor, para que um texto calculado sirva de TEXT_FIELD.
+func (h Hit) fieldsSource() map[string]interface{} {
+	if len(h.Fields) == 0 {
+		return h.Source
+	}
+
+	source := make(map[string]interface{}, len(h.Source)+len(h.Fields))
+	for k, v := range h.Source {
+		source[k] = v
+	}
+	for k, values := range h.Fields {
+		if len(values) == 1 {
+			source[k] = values[0]
+		} else {
+			source[k] = values
+		}
+	}
+	return source
+}
+
+type HitsContainer struct {
+	Total struct {
+		Value int `json:"value"`
+	} `json:"total"`
+	Hits []Hit `json:"hits"`
+}
+
+type SearchResponse struct {
+	ScrollID string        `json:"_scroll_id,omitempty"`
+	PitID    string        `json:"pit_id,omitempty"`
+	Hits     HitsContainer `json:"hits"`
+	Took     int64         `json:"took"` // duração da busca no Elasticsearch, em ms
+
+	// Ida e volta da requisição, do envio ao fim da leitura da resposta;
+	// a diferença para Took é a rede e a transferência do corpo
+	RoundTrip time.Duration `json:"-"`
+}
+
+// Estrutura para dados do documento
+type DocumentData struct {
+	ID      uint64
+	UUID    string // preenchido quando o ID não é numérico
+	Texto   string
+	Payload map[string]interface{}
+	Vector  []float32
+	Missing []string // campos configurados não encontrados no _source
+	// Motivo do ID ausente ou inválido em IDField; nil se o ID é válido
+	InvalidID error
+	// TextField original quando é um array, gravado no payload no lugar de
+	// Texto, que recebe os itens unidos; nil se o texto foi cortado ou dividido
+	TextArray []interface{}
+	// Hash do conteúdo gravado no payload, com SkipUnchanged
+	ContentHash string
+	// Coleção de destino pelo CollectionTemplate; vazio usa CollectionName
+	Collection string
+	// Chave de shard pelo ShardKeyField; vazio sem sharding custom
+	ShardKey string
+	// Vetores de NamedVectors lidos do _source ou gerados de embed_field,
+	// pelo nome; o do embedding fica em Vector
+	NamedVectors map[string][]float32
+	// Textos dos vetores de NamedVectors com embed_field, pelo nome
+	FieldTexts map[string]string
+}
+
+// Cliente personalizado para Elasticsearch
+type ElasticsearchClient struct {
+	httpClient *http.Client
+	cfg        *Config
+	limiter    *rate.Limiter
+
+	// Limite inferior de SyncField na sincronização incremental; vazio
+	// busca todos os documentos
+	since string
+
+	// Maior ID já gravado na coleção, na retomada por -resume-from-qdrant;
+	// nil busca todos os documentos
+	afterID *uint64
+
+	// Point-in-time aberto por openPIT, usado nas buscas search_after
+	pitID string
+}
+
+func NewElasticsearchClient(cfg *Config) (*ElasticsearchClient, error) {
+	tlsConfig, err := esTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parte do transporte padrão (proxy, timeouts de conexão, HTTP/2) com o
+	// pool ajustado: o padrão de 2 conexões ociosas por host obriga a abrir
+	// conexões novas quando há várias requisições em paralelo. A compressão é
+	// pedida e decodificada por send.
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	transport.MaxIdleConns = cfg.ESMaxIdleConns
+	transport.MaxIdleConnsPerHost = cfg.ESMaxIdleConnsPerHost
+	transport.IdleConnTimeout = cfg.ESIdleConnTimeout
+
+	return &ElasticsearchClient{
+		httpClient: &http.Client{
+			Transport: transport,
+			Timeout:   10 * time.Second,
+		},
+		cfg:     cfg,
+		limiter: newRateLimiter(cfg.RateLimit),
+	}, nil
+}
+
+// Executa a requisição pedindo a resposta com gzip, exceto com
+// ESDisableCompression, e com os cabeçalhos adicionais de ESHeaders.
+// Respostas com Content-Encoding: gzip têm o corpo substituído pelo leitor
+// descompactado, e fechar resp.Body fecha o leitor gzip e o corpo original.
+func (ec *ElasticsearchClient) send(req *http.Request) (*http.Response, error) {
+	if !ec.cfg.ESDisableCompression {
+		req.Header.Set("Accept-Encoding", "gzip")
+	}
+	// Os cabeçalhos de ESHeaders não substituem os definidos pelo cliente
+	for name, value := range ec.cfg.ESHeaders {
+		if req.Header.Get(name) == "" {
+			req.Header.Set(name, value)
+		}
+	}
+
+	resp, err := ec.httpClient.Do(req)
+	if err != nil || !strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		return resp, err
+	}
+
+	zr, err := gzip.NewReader(resp.Body)
+	switch {
+	case errors.Is(err, io.EOF):
+		// Corpo vazio, como nas respostas a HEAD
+		resp.Body.Close()
+		resp.Body = http.NoBody
+	case err != nil:
+		resp.Body.Close()
+		return nil, fmt.Errorf("erro ao descompactar resposta: %v", err)
+	default:
+		resp.Body = &gzipBody{Reader: zr, body: resp.Body}
+	}
+	resp.Header.Del("Content-Encoding")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return resp, nil
+}
+
+// Corpo descompactado de uma resposta com gzip
+type gzipBody struct {
+	*gzip.Reader
+	body io.ReadCloser
+}
+
+func (b *gzipBody) Close() error {
+	zerr := b.Reader.Close()
+	if err := b.body.Close(); err != nil {
+		return err
+	}
+	return zerr
+}
+
+// Configuração TLS do cliente: verificação completa por padrão, usando a CA
+// de ESCACert quando informada; a verificação só é desativada com -insecure.
+// Com ESClientCert e ESClientKey, o certificado do cliente é apresentado ao
+// servidor (TLS mútuo).
+func esTLSConfig(cfg *Config) (*tls.Config, error) {
+	tlsConfig := &tls.Config{}
+	if cfg.ESClientCert != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.ESClientCert, cfg.ESClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao carregar certificado do cliente: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if cfg.ESInsecure {
+		logWarnf("Aviso: verificação do certificado TLS do Elasticsearch desativada (-insecure)")
+		tlsConfig.InsecureSkipVerify = true
+		return tlsConfig, nil
+	}
+	if cfg.ESCACert == "" {
+		return tlsConfig, nil
+	}
+
+	pem, err := os.ReadFile(cfg.ESCACert)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler certificado da CA: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("nenhum certificado PEM válido em %s", cfg.ESCACert)
+	}
+	tlsConfig.RootCAs = pool
+
+	return tlsConfig, nil
+}
+
+// Corpo comum às buscas: tamanho da página, campos do _source, campos
+// calculados e a consulta configurada. Com CountFirst o total já vem do
+// _count, e as buscas não precisam contar os documentos a cada página.
+func (ec *ElasticsearchClient) searchBody() map[string]interface{} {
+	body := map[string]interface{}{
+		"size":             ec.cfg.PageSize,
+		"track_total_hits": !ec.cfg.CountFirst,
+		"_source":          ec.cfg.sourceFilter(),
+		"query":            ec.query(),
+	}
+	if ec.cfg.RuntimeMappings != "" {
+		body["runtime_mappings"] = json.RawMessage(ec.cfg.RuntimeMappings)
+		body["fields"] = ec.cfg.runtimeFields()
+	}
+	if ec.cfg.ScriptFields != "" {
+		body["script_fields"] = json.RawMessage(ec.cfg.ScriptFields)
+	}
+	return body
+}
+
+// Consulta configurada (match_all quando nenhuma foi informada), com os
+// filtros de filterQuery
+func (ec *ElasticsearchClient) query() interface{} {
+	query := json.RawMessage(`{"match_all": {}}`)
+	if ec.cfg.Query != "" {
+		query = json.RawMessage(ec.cfg.Query)
+	}
+	return ec.filterQuery(query)
+}
+
+// Total exato de documentos da consulta, pelo _count dos índices de ES_URL,
+// com novas tentativas para erros transitórios
+func (ec *ElasticsearchClient) count(ctx context.Context) (int, error) {
+	body, err := json.Marshal(map[string]interface{}{"query": ec.query()})
+	if err != nil {
+		return 0, fmt.Errorf("erro ao montar requisição de contagem: %v", err)
+	}
+	url := ec.cfg.indexURL("/_count")
+
+	var count int
+	err = withRetry(ctx, ec.cfg.MaxRetries, func() error {
+		if err := ec.limiter.Wait(ctx); err != nil {
+			return err
+		}
+		var err error
+		count, err = ec.countOnce(ctx, url, body)
+		return err
+	})
+	return count, err
+}
+
+func (ec *ElasticsearchClient) countOnce(ctx context.Context, url string, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("erro ao criar requisição: %v", err)
+	}
+
+	ec.setAuth(req)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := ec.send(req)
+	if err != nil {
+		return 0, fmt.Errorf("erro ao executar requisição: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return 0, &HTTPError{
+			StatusCode: resp.StatusCode,
+			Body:       string(body),
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
+	}
+
+	var result struct {
+		Count int `json:"count"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, fmt.Errorf("erro ao decodificar resposta: %v", err)
+	}
+	return result.Count, nil
+}
+
+// Restringe a consulta configurada na sincronização incremental, aos
+// documentos com SyncField a partir de since (recuando SyncOverlap para cobrir
+// diferenças de relógio e documentos indexados com atraso), na retomada pela
+// coleção, aos documentos com IDField acima de afterID, e ao intervalo de
+// IDField de MinID e MaxID
+func (ec *ElasticsearchClient) filterQuery(query json.RawMessage) interface{} {
+	var filters []interface{}
+	if ec.cfg.SyncField != "" && ec.since != "" {
+		filters = append(filters, map[string]interface{}{
+			"range": map[string]interface{}{
+				ec.cfg.SyncField: map[string]interface{}{
+					"gte":    fmt.Sprintf("%s||-%ds", ec.since, int(ec.cfg.SyncOverlap.Seconds())),
+					"format": "strict_date_optional_time",
+				},
+			},
+		})
+	}
+	if ec.afterID != nil {
+		filters = append(filters, map[string]interface{}{
+			"range": map[string]interface{}{
+				ec.cfg.IDField: map[string]interface{}{"gt": *ec.afterID},
+			},
+		})
+	}
+	if ec.cfg.MinID != nil || ec.cfg.MaxID != nil {
+		bounds := map[string]interface{}{}
+		if ec.cfg.MinID != nil {
+			bounds["gte"] = *ec.cfg.MinID
+		}
+		if ec.cfg.MaxID != nil {
+			bounds["lte"] = *ec.cfg.MaxID
+		}
+		filters = append(filters, map[string]interface{}{
+			"range": map[string]interface{}{ec.cfg.IDField: bounds},
+		})
+	}
+	if len(filters) == 0 {
+		return query
+	}
+
+	return map[string]interface{}{
+		"bool": map[string]interface{}{
+			"must":   []interface{}{query},
+			"filter": filters,
+		},
+	}
+}
+
+// Abre um contexto de scroll (scrollID vazio) ou lê o próximo lote de um
+// contexto existente. O scroll ID retornado deve ser usado na próxima chamada,
+// pois o Elasticsearch pode alterá-lo entre as requisições.
+func (ec *ElasticsearchClient) searchDocumentsScroll(ctx context.Context, scrollID string) (*SearchResponse, error) {
+	return ec.scroll(ctx, nil, scrollID)
+}
+
+// Página da fatia slice (de 0 a ScrollSlices-1) do scroll fatiado; cada
+// fatia tem o seu scroll ID
+func (ec *ElasticsearchClient) searchSliceScroll(ctx context.Context, slice int, scrollID string) (*SearchResponse, error) {
+	return ec.scroll(ctx, map[string]int{"id": slice, "max": ec.cfg.ScrollSlices}, scrollID)
+}
+
+// Abre o scroll, com o slice na primeira busca quando informado, ou continua
+// a partir de scrollID
+func (ec *ElasticsearchClient) scroll(ctx context.Context, slice map[string]int, scrollID string) (*SearchResponse, error) {
+	if scrollID == "" {
+		query := ec.searchBody()
+		if slice != nil {
+			query["slice"] = slice
+		}
+		body, err := json.Marshal(query)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao montar requisição de busca: %v", err)
+		}
+
+		return ec.doSearch(ctx, "POST", ec.cfg.ESURL+"?scroll="+ec.cfg.ScrollTTL, string(body))
+	}
+
+	body, err := json.Marshal(map[string]string{
+		"scroll":    ec.cfg.ScrollTTL,
+		"scroll_id": scrollID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("erro ao montar requisição de scroll: %v", err)
+	}
+
+	return ec.doSearch(ctx, "POST", ec.cfg.ESScrollURL, string(body))
+}
+
+// Busca a próxima página ordenada por sort, continuando a partir do cursor
+// after (nil na primeira chamada). Retorna o cursor para a próxima página,
+// obtido dos valores de sort do último hit, permitindo retomar a exportação
+// sem manter contexto no servidor.
+func (ec *ElasticsearchClient) searchDocumentsAfter(ctx context.Context, sort []string, after []interface{}) (*SearchResponse, []interface{}, error) {
+	sortClause := make([]interface{}, 0, len(sort))
+	for _, field := range sort {
+		sortClause = append(sortClause, map[string]string{field: "asc"})
+	}
+
+	query := ec.searchBody()
+	query["sort"] = sortClause
+	if len(after) > 0 {
+		query["search_after"] = after
+	}
+
+	// Com point-in-time o índice vem do PIT, e a busca não leva o índice no
+	// caminho
+	url := ec.cfg.ESURL
+	if ec.pitID != "" {
+		query["pit"] = map[string]string{"id": ec.pitID, "keep_alive": ec.cfg.ScrollTTL}
+		url = ec.cfg.esBaseURL() + "/_search"
+	}
+
+	body, err := json.Marshal(query)
+	if err != nil {
+		return nil, nil, fmt.Errorf("erro ao montar requisição search_after: %v", err)
+	}
+
+	result, err := ec.doSearch(ctx, "POST", url, string(body))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// O Elasticsearch pode retornar um novo ID do PIT a cada busca
+	if result.PitID != "" {
+		ec.pitID = result.PitID
+	}
+
+	next := after
+	if hits := result.Hits.Hits; len(hits) > 0 {
+		next = hits[len(hits)-1].Sort
+	}
+
+	return result, next, nil
+}
+
+// Abre um point-in-time nos índices de ES_URL. As buscas search_after
+// seguintes leem o snapshot do momento da abertura, sem documentos
+// duplicados ou perdidos por gravações durante a exportação.
+func (ec *ElasticsearchClient) openPIT(ctx context.Context) error {
+	url := ec.cfg.indexURL("/_pit?keep_alive=" + ec.cfg.ScrollTTL)
+
+	var pitID string
+	err := withRetry(ctx, ec.cfg.MaxRetries, func() error {
+		if err := ec.limiter.Wait(ctx); err != nil {
+			return err
+		}
+		status, body, err := ec.request(ctx, "POST", url)
+		if err != nil {
+			return err
+		}
+		if status != http.StatusOK {
+			return &HTTPError{StatusCode: status, Body: body}
+		}
+
+		var result struct {
+			ID string `json:"id"`
+		}
+		if err := json.Unmarshal([]byte(body), &result); err != nil {
+			return fmt.Errorf("erro ao decodificar resposta: %v", err)
+		}
+		pitID = result.ID
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("erro ao abrir point-in-time: %w", err)
+	}
+	if pitID == "" {
+		return fmt.Errorf("erro ao abrir point-in-time: resposta sem ID")
+	}
+
+	ec.pitID = pitID
+	return nil
+}
+
+// Fecha o point-in-time aberto por openPIT, liberando os recursos no cluster
+func (ec *ElasticsearchClient) closePIT(ctx context.Context) error {
+	if ec.pitID == "" {
+		return nil
+	}
+
+	body, err := json.Marshal(map[string]string{"id": ec.pitID})
+	if err != nil {
+		return fmt.Errorf("erro ao montar requisição de fechamento do PIT: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "DELETE", ec.cfg.esBaseURL()+"/_pit", strings.NewReader(string(body)))
+	if err != nil {
+		return fmt.Errorf("erro ao criar requisição: %v", err)
+	}
+
+	ec.setAuth(req)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := ec.send(req)
+	if err != nil {
+		return fmt.Errorf("erro ao executar requisição: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("erro HTTP %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	ec.pitID = ""
+	return nil
+}
+
+// Libera o contexto de scroll no Elasticsearch
+func (ec *ElasticsearchClient) clearScroll(ctx context.Context, scrollID string) error {
+	body, err := json.Marshal(map[string]string{"scroll_id": scrollID})
+	if err != nil {
+		return fmt.Errorf("erro ao montar requisição de limpeza do scroll: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "DELETE", ec.cfg.ESScrollURL, strings.NewReader(string(body)))
+	if err != nil {
+		return fmt.Errorf("erro ao criar requisição: %v", err)
+	}
+
+	ec.setAuth(req)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := ec.send(req)
+	if err != nil {
+		return fmt.Errorf("erro ao executar requisição: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("erro HTTP %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	return nil
+}
+
+// Requisição simples sem corpo, retornando o status e o corpo da resposta
+func (ec *ElasticsearchClient) request(ctx context.Context, method, url string) (int, string, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return 0, "", fmt.Errorf("erro ao criar requisição: %v", err)
+	}
+
+	ec.setAuth(req)
+
+	resp, err := ec.send(req)
+	if err != nil {
+		return 0, "", fmt.Errorf("erro ao executar requisição: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body), nil
+}
+
+// Define o cabeçalho Authorization conforme ESAuthMode
+func (ec *ElasticsearchClient) setAuth(req *http.Request) {
+	switch ec.cfg.ESAuthMode {
+	case "apikey":
+		// A chave pode vir no formato "id:api_key" ou já codificada
+		key := ec.cfg.ESAPIKey
+		if strings.Contains(key, ":") {
+			key = base64.StdEncoding.EncodeToString([]byte(key))
+		}
+		req.Header.Set("Authorization", "ApiKey "+key)
+	case "bearer":
+		req.Header.Set("Authorization", "Bearer "+ec.cfg.ESBearerToken)
+	default:
+		req.SetBasicAuth(ec.cfg.ESUsername, ec.cfg.ESPassword)
+	}
+}
+
+// Executa a busca com novas tentativas para erros transitórios; cada
+// tentativa respeita o limite de requisições por segundo
+func (ec *ElasticsearchClient) doSearch(ctx context.Context, method, url, body string) (*SearchResponse, error) {
+	var result *SearchResponse
+	err := withRetry(ctx, ec.cfg.MaxRetries, func() error {
+		if err := ec.limiter.Wait(ctx); err != nil {
+			return err
+		}
+		var err error
+		result, err = ec.searchOnce(ctx, method, url, body)
+		return err
+	})
+	return result, err
+}
+
+func (ec *ElasticsearchClient) searchOnce(ctx context.Context, method, url, body string) (*SearchResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, strings.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("erro ao criar requisição: %v", err)
+	}
+
+	ec.setAuth(req)
+	req.Header.Set("Content-Type", "application/json")
+
+	start := time.Now()
+	resp, err := ec.send(req)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao executar requisição: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, &HTTPError{
+			StatusCode: resp.StatusCode,
+			Body:       string(body),
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
+	}
+
+	// UseNumber preserva inteiros grandes e a distinção entre inteiro e
+	// decimal ao copiar os campos para o payload
+	var result SearchResponse
+	decoder := json.NewDecoder(resp.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&result); err != nil {
+		return nil, fmt.Errorf("erro ao decodificar resposta: %v", err)
+	}
+	result.RoundTrip = time.Since(start)
+
+	return &result, nil
+}
+
+// Extrai o documento do hit: o campo IDField (ou o _id do hit, ou a chave
+// composta de IDFields) vira o ID do ponto, TextField a entrada do
+// embedding, EmbeddingField o vetor já calculado, e os demais campos
+// solicitados são copiados para o payload. Os campos aceitam caminhos com
+// pontos para objetos aninhados; os que não são encontrados ficam em Missing.
+func extractDocumentData(hit Hit, cfg *Config) DocumentData {
+	data := DocumentData{
+		Payload: make(map[string]interface{}, len(cfg.SourceFields)),
+	}
+	source := hit.fieldsSource()
+
+	// Extrair ID; IDs inválidos ou ausentes ficam em InvalidID, com o ID 0
+	// ou, com OnMissingID "hash", um UUID v5 derivado do _id do hit
+	if len(cfg.IDFields) > 0 {
+		data.UUID, data.InvalidID = compositeUUID(hit, source, cfg.IDFields)
+	} else {
+		var rawID interface{} = hit.ID
+		if cfg.IDField != "_id" {
+			v, _ := lookupField(source, cfg.IDField)
+			rawID = normalizeJSON(v)
+		}
+		data.ID, data.UUID, data.InvalidID = parsePointID(rawID)
+	}
+	if data.InvalidID == nil {
+		data.namespaceID(cfg)
+	}
+	if data.InvalidID != nil && cfg.OnMissingID == "hash" {
+		data.UUID = fallbackUUID(hit)
+		if cfg.IDPrefix != "" {
+			data.UUID = uuidV5(cfg.IDPrefix + data.UUID)
+		}
+	}
+
+	// Extrair o texto do embedding: TextField ou a combinação de EmbedFields,
+	// cujos campos ausentes são contados abaixo, junto com os do payload
+	if len(cfg.EmbedFields) > 0 {
+		data.Texto = renderTemplate(cfg.EmbedTemplate, source)
+	} else if v, ok := lookupField(source, cfg.TextField); ok {
+		switch v := normalizeJSON(v).(type) {
+		case string:
+			data.Texto = v
+		case []interface{}:
+			data.Texto = joinTextArray(v, cfg)
+			data.TextArray = v
+		default:
+			data.Missing = append(data.Missing, cfg.TextField)
+		}
+		if data.Texto == "" && data.TextArray != nil {
+			data.Missing = append(data.Missing, cfg.TextField)
+		}
+	} else {
+		data.Missing = append(data.Missing, cfg.TextField)
+	}
+
+	// Embedding já calculado; ausente ou inválido, o documento fica sem vetor
+	// e é tratado como dimensão divergente
+	if cfg.EmbeddingField != "" {
+		v, _ := lookupField(source, cfg.EmbeddingField)
+		if vector, ok := vectorValue(v); ok {
+			data.Vector = vector
+		} else {
+			data.Missing = append(data.Missing, cfg.EmbeddingField)
+		}
+	}
+	if len(cfg.NamedVectors) > 0 {
+		var missing []string
+		data.NamedVectors, missing = namedVectorValues(source, cfg)
+		data.Missing = append(data.Missing, missing...)
+		data.FieldTexts, missing = embedFieldTexts(source, cfg)
+		data.Missing = append(data.Missing, missing...)
+	}
+
+	// Copiar os campos solicitados para o payload; um padrão com curinga
+	// copia cada campo que casa com ele
+	for _, field := range cfg.payloadFields() {
+		if field == cfg.idPayloadField() || field == cfg.TextField || field == cfg.EmbeddingField {
+			continue
+		}
+		if isFieldPattern(field) {
+			if !copyMatchingFields(data.Payload, source, field, cfg) {
+				data.Missing = append(data.Missing, field)
+			}
+			continue
+		}
+		if _, excluded := cfg.excludedField(field); excluded {
+			continue
+		}
+		v, ok := lookupField(source, field)
+		if ok && slices.Contains(cfg.GeoFields, field) {
+			v, ok = geoValue(v)
+		}
+		if ok {
+			setField(data.Payload, cfg.payloadKey(field), normalizeJSON(v))
+		} else {
+			data.Missing = append(data.Missing, field)
+		}
+	}
+
+	// Índice de origem, para filtrar coleções que consolidam vários índices
+	if cfg.IndexField != "" && hit.Index != "" {
+		data.Payload[cfg.IndexField] = hit.Index
+	}
+
+	// Coleção do tenant; sem os campos do template, a coleção padrão
+	if cfg.CollectionTemplate != "" {
+		var missing []string
+		data.Collection, missing = routeCollection(cfg.CollectionTemplate, source)
+		data.Missing = append(data.Missing, missing...)
+	}
+
+	// Chave de shard; sem o campo, a chave padrão
+	if cfg.ShardKeyField != "" {
+		var missing []string
+		data.ShardKey, missing = shardKeyValue(source, cfg)
+		data.Missing = append(data.Missing, missing...)
+	}
+
+	return data
+}
+
+// Converte os json.Number decodificados em int64 ou float64, conforme o
+// valor original, para que o Qdrant armazene inteiros como inteiros
+func normalizeJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			out[k] = normalizeJSON(item)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = normalizeJSON(item)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// Converte um dense_vector do _source, um array de números, em []float32;
+// false se o valor não é um array numérico não vazio
+func vectorValue(v interface{}) ([]float32, bool) {
+	items, ok := v.([]interface{})
+	if !ok || len(items) == 0 {
+		return nil, false
+	}
+	vector := make([]float32, len(items))
+	for i, item := range items {
+		n, ok := item.(json.Number)
+		if !ok {
+			return nil, false
+		}
+		f, err := n.Float64()
+		if err != nil {
+			return nil, false
+		}
+		vector[i] = float32(f)
+	}
+	return vector, true
+}
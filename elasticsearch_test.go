@@ -0,0 +1,385 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestExtractDocumentData(t *testing.T) {
+	tests := []struct {
+		name        string
+		hit         Hit
+		idField     string
+		idFields    []string
+		fields      []string
+		excludes    []string
+		geo         []string
+		rename      map[string]string
+		vectorField string
+		shardField  string
+		idPrefix    string
+		idOffset    uint64
+		wantID      uint64
+		wantUUID    string
+		wantTexto   string
+		wantPayload map[string]interface{}
+		wantMissing []string
+		wantVector  []float32
+		wantShard   string
+	}{
+		{
+			name:        "id numérico",
+			hit:         Hit{Source: map[string]interface{}{"id": json.Number("42"), "texto": "olá"}},
+			wantID:      42,
+			wantTexto:   "olá",
+			wantPayload: map[string]interface{}{},
+		},
+		{
+			name:        "id numérico em string",
+			hit:         Hit{Source: map[string]interface{}{"id": "7", "texto": "olá"}},
+			wantID:      7,
+			wantTexto:   "olá",
+			wantPayload: map[string]interface{}{},
+		},
+		{
+			name:        "id textual vira UUID v5",
+			hit:         Hit{Source: map[string]interface{}{"id": "LEI-8112", "texto": "olá"}},
+			wantUUID:    uuidV5("LEI-8112"),
+			wantTexto:   "olá",
+			wantPayload: map[string]interface{}{},
+		},
+		{
+			name:        "id ausente",
+			hit:         Hit{Source: map[string]interface{}{"texto": "olá"}},
+			wantTexto:   "olá",
+			wantPayload: map[string]interface{}{},
+		},
+		{
+			name:        "_id do hit",
+			hit:         Hit{ID: "99", Source: map[string]interface{}{"texto": "olá"}},
+			idField:     "_id",
+			fields:      []string{"texto"},
+			wantID:      99,
+			wantTexto:   "olá",
+			wantPayload: map[string]interface{}{},
+		},
+		{
+			name:        "texto ausente",
+			hit:         Hit{Source: map[string]interface{}{"id": json.Number("1")}},
+			wantID:      1,
+			wantPayload: map[string]interface{}{},
+			wantMissing: []string{"texto"},
+		},
+		{
+			name:        "texto com tipo inesperado",
+			hit:         Hit{Source: map[string]interface{}{"id": json.Number("1"), "texto": json.Number("3")}},
+			wantID:      1,
+			wantPayload: map[string]interface{}{},
+			wantMissing: []string{"texto"},
+		},
+		{
+			name: "campos extras",
+			hit: Hit{Source: map[string]interface{}{
+				"id":        json.Number("5"),
+				"texto":     "olá",
+				"ano":       json.Number("2024"),
+				"nota":      json.Number("9.5"),
+				"tags":      []interface{}{"a", json.Number("1")},
+				"ignorado":  "não solicitado",
+				"metadados": map[string]interface{}{"orgao": "STF"},
+			}},
+			fields:    []string{"id", "texto", "ano", "nota", "tags", "metadados.orgao", "ausente"},
+			wantID:    5,
+			wantTexto: "olá",
+			wantPayload: map[string]interface{}{
+				"ano":       int64(2024),
+				"nota":      9.5,
+				"tags":      []interface{}{"a", int64(1)},
+				"metadados": map[string]interface{}{"orgao": "STF"},
+			},
+			wantMissing: []string{"ausente"},
+		},
+		{
+			name: "campos calculados em hit.fields",
+			hit: Hit{
+				Source: map[string]interface{}{"id": json.Number("3"), "texto": "original"},
+				Fields: map[string][]interface{}{
+					"texto": {"calculado"},
+					"tags":  {"a", "b"},
+				},
+			},
+			fields:      []string{"id", "texto", "tags"},
+			wantID:      3,
+			wantTexto:   "calculado",
+			wantPayload: map[string]interface{}{"tags": []interface{}{"a", "b"}},
+		},
+		{
+			name: "campos renomeados",
+			hit: Hit{Source: map[string]interface{}{
+				"id":        json.Number("8"),
+				"texto":     "olá",
+				"ano":       json.Number("2024"),
+				"metadados": map[string]interface{}{"orgao": "STF"},
+			}},
+			fields:    []string{"id", "texto", "ano", "metadados.orgao"},
+			rename:    map[string]string{"ano": "year", "metadados.orgao": "court"},
+			wantID:    8,
+			wantTexto: "olá",
+			wantPayload: map[string]interface{}{
+				"year":  int64(2024),
+				"court": "STF",
+			},
+		},
+		{
+			name: "vetor do dense_vector",
+			hit: Hit{Source: map[string]interface{}{
+				"id":        json.Number("4"),
+				"texto":     "olá",
+				"embedding": []interface{}{json.Number("0.5"), json.Number("-1"), json.Number("0")},
+			}},
+			fields:      []string{"id", "texto", "embedding"},
+			vectorField: "embedding",
+			wantID:      4,
+			wantTexto:   "olá",
+			wantPayload: map[string]interface{}{},
+			wantVector:  []float32{0.5, -1, 0},
+		},
+		{
+			name:        "dense_vector inválido",
+			hit:         Hit{Source: map[string]interface{}{"id": json.Number("4"), "texto": "olá", "embedding": "x"}},
+			vectorField: "embedding",
+			wantID:      4,
+			wantTexto:   "olá",
+			wantPayload: map[string]interface{}{},
+			wantMissing: []string{"embedding"},
+		},
+		{
+			name:        "texto em array",
+			hit:         Hit{Source: map[string]interface{}{"id": json.Number("5"), "texto": []interface{}{"a", nil, json.Number("2"), "", "b"}}},
+			wantID:      5,
+			wantTexto:   "a\n2\nb",
+			wantPayload: map[string]interface{}{},
+		},
+		{
+			name:        "texto em array sem strings",
+			hit:         Hit{Source: map[string]interface{}{"id": json.Number("6"), "texto": []interface{}{nil}}},
+			wantID:      6,
+			wantPayload: map[string]interface{}{},
+			wantMissing: []string{"texto"},
+		},
+		{
+			name:        "chave composta",
+			hit:         Hit{ID: "abc", Source: map[string]interface{}{"tenant_id": "acme", "doc_id": json.Number("42"), "texto": "olá"}},
+			idFields:    []string{"tenant_id", "doc_id", "_id"},
+			fields:      []string{"tenant_id", "texto"},
+			wantUUID:    uuidV5(`fields:["acme",42,"abc"]`),
+			wantTexto:   "olá",
+			wantPayload: map[string]interface{}{"tenant_id": "acme"},
+		},
+		{
+			name:        "chave composta com campo ausente",
+			hit:         Hit{Source: map[string]interface{}{"tenant_id": "acme", "texto": "olá"}},
+			idFields:    []string{"tenant_id", "doc_id"},
+			fields:      []string{"texto"},
+			wantTexto:   "olá",
+			wantPayload: map[string]interface{}{},
+		},
+		{
+			name:        "chave de shard",
+			hit:         Hit{Source: map[string]interface{}{"id": json.Number("8"), "texto": "olá", "tenant": json.Number("12")}},
+			shardField:  "tenant",
+			wantID:      8,
+			wantTexto:   "olá",
+			wantPayload: map[string]interface{}{},
+			wantShard:   "12",
+		},
+		{
+			name:        "chave de shard ausente usa a padrão",
+			hit:         Hit{Source: map[string]interface{}{"id": json.Number("9"), "texto": "olá"}},
+			shardField:  "tenant",
+			wantID:      9,
+			wantTexto:   "olá",
+			wantPayload: map[string]interface{}{},
+			wantMissing: []string{"tenant"},
+			wantShard:   "default",
+		},
+		{
+			name:        "id com prefixo",
+			hit:         Hit{Source: map[string]interface{}{"id": json.Number("42"), "texto": "olá"}},
+			idPrefix:    "index1-",
+			wantUUID:    uuidV5("index1-42"),
+			wantTexto:   "olá",
+			wantPayload: map[string]interface{}{"id": int64(42)},
+		},
+		{
+			name:        "id com offset",
+			hit:         Hit{Source: map[string]interface{}{"id": json.Number("42"), "texto": "olá"}},
+			idOffset:    1000,
+			wantID:      1042,
+			wantTexto:   "olá",
+			wantPayload: map[string]interface{}{"id": int64(42)},
+		},
+		{
+			name:        "offset não altera UUIDs",
+			hit:         Hit{Source: map[string]interface{}{"id": "LEI-8112", "texto": "olá"}},
+			idOffset:    1000,
+			wantUUID:    uuidV5("LEI-8112"),
+			wantTexto:   "olá",
+			wantPayload: map[string]interface{}{"id": "LEI-8112"},
+		},
+		{
+			name: "geo_point em objeto, string, array, WKT e GeoJSON",
+			hit: Hit{Source: map[string]interface{}{
+				"id": json.Number("4"), "texto": "olá",
+				"objeto":  map[string]interface{}{"lat": json.Number("-23.5"), "lon": "-46.6"},
+				"string":  "-23.5, -46.6",
+				"array":   []interface{}{json.Number("-46.6"), json.Number("-23.5")},
+				"wkt":     "POINT (-46.6 -23.5)",
+				"geojson": map[string]interface{}{"type": "Point", "coordinates": []interface{}{json.Number("-46.6"), json.Number("-23.5")}},
+			}},
+			geo:       []string{"objeto", "string", "array", "wkt", "geojson"},
+			wantID:    4,
+			wantTexto: "olá",
+			wantPayload: map[string]interface{}{
+				"objeto":  map[string]interface{}{"lat": -23.5, "lon": -46.6},
+				"string":  map[string]interface{}{"lat": -23.5, "lon": -46.6},
+				"array":   map[string]interface{}{"lat": -23.5, "lon": -46.6},
+				"wkt":     map[string]interface{}{"lat": -23.5, "lon": -46.6},
+				"geojson": map[string]interface{}{"lat": -23.5, "lon": -46.6},
+			},
+		},
+		{
+			name: "geo_point com geohash e lista de pontos",
+			hit: Hit{Source: map[string]interface{}{
+				"id": json.Number("5"), "texto": "olá",
+				"hash":   "s00",
+				"pontos": []interface{}{"10,20", []interface{}{json.Number("30"), json.Number("40")}},
+			}},
+			geo:       []string{"hash", "pontos"},
+			rename:    map[string]string{"pontos": "locais"},
+			wantID:    5,
+			wantTexto: "olá",
+			wantPayload: map[string]interface{}{
+				"hash": map[string]interface{}{"lat": 0.703125, "lon": 0.703125},
+				"locais": []interface{}{
+					map[string]interface{}{"lat": 10.0, "lon": 20.0},
+					map[string]interface{}{"lat": 40.0, "lon": 30.0},
+				},
+			},
+		},
+		{
+			name: "geo_point inválido conta como ausente",
+			hit: Hit{Source: map[string]interface{}{
+				"id": json.Number("6"), "texto": "olá",
+				"fora":   "95,10",
+				"texto2": "sem coordenadas!",
+			}},
+			geo:         []string{"fora", "texto2", "ausente"},
+			wantID:      6,
+			wantTexto:   "olá",
+			wantPayload: map[string]interface{}{},
+			wantMissing: []string{"fora", "texto2", "ausente"},
+		},
+		{
+			name:      "curinga copia os campos aninhados",
+			hit:       Hit{Source: wildcardSource()},
+			fields:    []string{"id", "texto", "content.*"},
+			wantID:    3,
+			wantTexto: "olá",
+			wantPayload: map[string]interface{}{"content": map[string]interface{}{
+				"title": "Título",
+				"body":  map[string]interface{}{"pt": "corpo", "en": "body"},
+				"tags":  []interface{}{"a", "b"},
+			}},
+		},
+		{
+			name:      "curinga com excludes",
+			hit:       Hit{Source: wildcardSource()},
+			fields:    []string{"id", "texto", "content.*"},
+			excludes:  []string{"content.body.e*", "content.tags"},
+			wantID:    3,
+			wantTexto: "olá",
+			wantPayload: map[string]interface{}{"content": map[string]interface{}{
+				"title": "Título",
+				"body":  map[string]interface{}{"pt": "corpo"},
+			}},
+		},
+		{
+			name:      "curinga no meio do caminho",
+			hit:       Hit{Source: wildcardSource()},
+			fields:    []string{"id", "texto", "content.*.pt", "outro"},
+			rename:    map[string]string{"content.body.pt": "corpo"},
+			wantID:    3,
+			wantTexto: "olá",
+			wantPayload: map[string]interface{}{
+				"corpo": "corpo",
+				"outro": "fora",
+			},
+		},
+		{
+			name:        "curinga sem campos",
+			hit:         Hit{Source: wildcardSource()},
+			fields:      []string{"id", "texto", "meta.*"},
+			wantID:      3,
+			wantTexto:   "olá",
+			wantPayload: map[string]interface{}{},
+			wantMissing: []string{"meta.*"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+			if tt.idField != "" {
+				cfg.IDField = tt.idField
+			}
+			if tt.fields != nil {
+				cfg.SourceFields = tt.fields
+			}
+			cfg.IDFields = tt.idFields
+			cfg.SourceExcludes = tt.excludes
+			cfg.GeoFields = tt.geo
+			cfg.PayloadRename = tt.rename
+			cfg.EmbeddingField = tt.vectorField
+			cfg.ShardKeyField = tt.shardField
+			cfg.IDPrefix = tt.idPrefix
+			cfg.IDOffset = tt.idOffset
+
+			doc := extractDocumentData(tt.hit, cfg)
+
+			if doc.ID != tt.wantID || doc.UUID != tt.wantUUID {
+				t.Errorf("ID = %d/%q, esperado %d/%q", doc.ID, doc.UUID, tt.wantID, tt.wantUUID)
+			}
+			if doc.Texto != tt.wantTexto {
+				t.Errorf("Texto = %q, esperado %q", doc.Texto, tt.wantTexto)
+			}
+			if !reflect.DeepEqual(doc.Payload, tt.wantPayload) {
+				t.Errorf("Payload = %#v, esperado %#v", doc.Payload, tt.wantPayload)
+			}
+			if !reflect.DeepEqual(doc.Missing, tt.wantMissing) {
+				t.Errorf("Missing = %v, esperado %v", doc.Missing, tt.wantMissing)
+			}
+			if !reflect.DeepEqual(doc.Vector, tt.wantVector) {
+				t.Errorf("Vector = %v, esperado %v", doc.Vector, tt.wantVector)
+			}
+			if doc.ShardKey != tt.wantShard {
+				t.Errorf("ShardKey = %q, esperado %q", doc.ShardKey, tt.wantShard)
+			}
+		})
+	}
+}
+
+// Documento com campos aninhados em content, para os padrões com curinga
+func wildcardSource() map[string]interface{} {
+	return map[string]interface{}{
+		"id":    json.Number("3"),
+		"texto": "olá",
+		"content": map[string]interface{}{
+			"title": "Título",
+			"body":  map[string]interface{}{"pt": "corpo", "en": "body"},
+			"tags":  []interface{}{"a", "b"},
+		},
+		"outro": "fora",
+	}
+}
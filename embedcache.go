@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Cache de embeddings em disco, em JSON lines ({"key": ..., "vector": [...]}),
+// indexado pelo hash do modelo e do texto normalizado. Em memória fica apenas
+// a posição de cada registro no arquivo; o vetor é lido do disco a cada acerto.
+type embeddingCache struct {
+	file  *os.File
+	model string
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+	size    int64
+
+	hits   atomic.Int64
+	misses atomic.Int64
+
+	// Acertos e falhas dos campos de embed_field, pelo campo; também
+	// contados em hits e misses
+	fields map[string]cacheCounts
+}
+
+type cacheCounts struct {
+	hits   int64
+	misses int64
+}
+
+// Percentual de acertos
+func (s cacheCounts) hitRate() float64 {
+	if s.hits+s.misses == 0 {
+		return 0
+	}
+	return float64(s.hits) * 100 / float64(s.hits+s.misses)
+}
+
+type cacheEntry struct {
+	offset int64
+	length int
+}
+
+type cacheRecord struct {
+	Key    string    `json:"key"`
+	Vector []float32 `json:"vector"`
+}
+
+// Abre (ou cria) o cache em path e carrega o índice dos registros existentes.
+// Uma última linha incompleta, de uma execução interrompida, é descartada.
+func openEmbeddingCache(path, model string) (*embeddingCache, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao abrir cache de embeddings: %v", err)
+	}
+
+	c := &embeddingCache{file: file, model: model, entries: make(map[string]cacheEntry), fields: make(map[string]cacheCounts)}
+
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("erro ao ler cache de embeddings: %v", err)
+		}
+
+		var rec cacheRecord
+		if json.Unmarshal(line, &rec) == nil && rec.Key != "" {
+			c.entries[rec.Key] = cacheEntry{offset: c.size, length: len(line)}
+		}
+		c.size += int64(len(line))
+	}
+
+	// Sobrescrever a linha incompleta, se houver
+	if err := file.Truncate(c.size); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("erro ao preparar cache de embeddings: %v", err)
+	}
+
+	log.Printf("Cache de embeddings %s: %d registros", path, len(c.entries))
+	return c, nil
+}
+
+func (c *embeddingCache) Close() error {
+	return c.file.Close()
+}
+
+// Chave do texto: espaços nas pontas e repetidos não alteram o embedding
+// armazenado, e o modelo faz parte da chave para não misturar vetores de
+// modelos diferentes
+func (c *embeddingCache) key(text string) string {
+	normalized := strings.Join(strings.Fields(text), " ")
+	sum := sha256.Sum256([]byte(c.model + "\x00" + normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *embeddingCache) get(key string) ([]float32, bool) {
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+
+	buf := make([]byte, entry.length)
+	if _, err := c.file.ReadAt(buf, entry.offset); err != nil {
+		return nil, false
+	}
+	var rec cacheRecord
+	if err := json.Unmarshal(buf, &rec); err != nil || rec.Key != key {
+		return nil, false
+	}
+	return rec.Vector, true
+}
+
+func (c *embeddingCache) put(key string, vector []float32) error {
+	line, err := json.Marshal(cacheRecord{Key: key, Vector: vector})
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; ok {
+		return nil
+	}
+	if _, err := c.file.WriteAt(line, c.size); err != nil {
+		return err
+	}
+	c.entries[key] = cacheEntry{offset: c.size, length: len(line)}
+	c.size += int64(len(line))
+	return nil
+}
+
+// Acertos e falhas de consulta ao cache
+func (c *embeddingCache) stats() (hits, misses int64) {
+	return c.hits.Load(), c.misses.Load()
+}
+
+func (c *embeddingCache) countField(field string, hits, misses int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counts := c.fields[field]
+	counts.hits += int64(hits)
+	counts.misses += int64(misses)
+	c.fields[field] = counts
+}
+
+// Acertos e falhas de cada campo de embed_field, pelo campo
+func (c *embeddingCache) fieldStats() map[string]cacheCounts {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return maps.Clone(c.fields)
+}
+
+// Embedder que consulta o cache antes de chamar o embedder original, que
+// recebe apenas os textos ainda não armazenados
+type cachedEmbedder struct {
+	embedder Embedder
+	cache    *embeddingCache
+	// Campo de embed_field dos textos, para as estatísticas por campo
+	field string
+}
+
+// Embedder dos textos do campo field, com as mesmas entradas do cache: a
+// chave é a do texto, então textos iguais reaproveitam o vetor em qualquer
+// campo, e os acertos e falhas também são contados no campo
+func (ce *cachedEmbedder) forField(field string) *cachedEmbedder {
+	return &cachedEmbedder{embedder: ce.embedder, cache: ce.cache, field: field}
+}
+
+func (ce *cachedEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	embeddings := make([][]float32, len(texts))
+	keys := make([]string, len(texts))
+
+	var missing []int
+	for i, text := range texts {
+		keys[i] = ce.cache.key(text)
+		if vector, ok := ce.cache.get(keys[i]); ok {
+			embeddings[i] = vector
+			continue
+		}
+		missing = append(missing, i)
+	}
+	ce.cache.hits.Add(int64(len(texts) - len(missing)))
+	ce.cache.misses.Add(int64(len(missing)))
+	if ce.field != "" {
+		ce.cache.countField(ce.field, len(texts)-len(missing), len(missing))
+	}
+
+	if len(missing) == 0 {
+		return embeddings, nil
+	}
+
+	missingTexts := make([]string, len(missing))
+	for j, i := range missing {
+		missingTexts[j] = texts[i]
+	}
+	computed, err := ce.embedder.Embed(ctx, missingTexts)
+	if err != nil {
+		return nil, err
+	}
+	if len(computed) != len(missing) {
+		return nil, fmt.Errorf("esperados %d embeddings, recebidos %d", len(missing), len(computed))
+	}
+
+	for j, i := range missing {
+		embeddings[i] = computed[j]
+		if err := ce.cache.put(keys[i], computed[j]); err != nil {
+			logErrorf("Erro ao gravar no cache de embeddings: %v", err)
+		}
+	}
+
+	return embeddings, nil
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	openAIEmbeddingsURL = "https://api.openai.com/v1/embeddings"
+	defaultOpenAIModel  = "text-embedding-3-small"
+)
+
+// Gera embeddings para um lote de textos, na mesma ordem da entrada
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// Embedder que usa o endpoint /v1/embeddings da OpenAI
+type OpenAIEmbedder struct {
+	httpClient *http.Client
+	apiKey     string
+	model      string
+}
+
+type openAIEmbeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type openAIEmbeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+func NewOpenAIEmbedder(apiKey, model string) *OpenAIEmbedder {
+	if model == "" {
+		model = defaultOpenAIModel
+	}
+
+	return &OpenAIEmbedder{
+		httpClient: &http.Client{
+			Timeout: 60 * time.Second,
+		},
+		apiKey: apiKey,
+		model:  model,
+	}
+}
+
+func (oe *OpenAIEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	body, err := json.Marshal(openAIEmbeddingRequest{
+		Model: oe.model,
+		Input: texts,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("erro ao montar requisição de embeddings: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", openAIEmbeddingsURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("erro ao criar requisição: %v", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+oe.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := oe.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao executar requisição: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, &HTTPError{
+			StatusCode: resp.StatusCode,
+			Body:       string(respBody),
+			RetryAfter: openAIRetryAfter(resp.Header),
+		}
+	}
+
+	var result openAIEmbeddingResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("erro ao decodificar resposta: %v", err)
+	}
+
+	if len(result.Data) != len(texts) {
+		return nil, fmt.Errorf("esperados %d embeddings, recebidos %d", len(texts), len(result.Data))
+	}
+
+	// A API informa o índice de cada embedding; não confiar na ordem da lista
+	embeddings := make([][]float32, len(texts))
+	for _, item := range result.Data {
+		if item.Index < 0 || item.Index >= len(texts) {
+			return nil, fmt.Errorf("índice de embedding inválido: %d", item.Index)
+		}
+		embeddings[item.Index] = item.Embedding
+	}
+
+	return embeddings, nil
+}
+
+// Espera indicada pela OpenAI em uma resposta de erro: o Retry-After, quando
+// presente, ou o x-ratelimit-reset-* do limite esgotado (requisições ou
+// tokens, com remaining igual a 0), no formato de duração do Go ("1s", "6m0s")
+func openAIRetryAfter(h http.Header) time.Duration {
+	if d := parseRetryAfter(h.Get("Retry-After")); d > 0 {
+		return d
+	}
+
+	var delay time.Duration
+	for _, limit := range []string{"requests", "tokens"} {
+		if h.Get("X-Ratelimit-Remaining-"+limit) != "0" {
+			continue
+		}
+		if d, err := time.ParseDuration(h.Get("X-Ratelimit-Reset-" + limit)); err == nil && d > delay {
+			delay = d
+		}
+	}
+	return delay
+}
+
+// Embedder que repete as chamadas com falhas transitórias conforme a política
+// de EMBED_MAX_RETRIES, separada da usada nas buscas e nos upserts
+type retryingEmbedder struct {
+	embedder Embedder
+	policy   retryPolicy
+	retries  atomic.Int64
+}
+
+func newRetryingEmbedder(embedder Embedder, cfg *Config) *retryingEmbedder {
+	return &retryingEmbedder{
+		embedder: embedder,
+		policy: retryPolicy{
+			maxAttempts: cfg.EmbedMaxRetries,
+			baseDelay:   cfg.EmbedRetryDelay,
+			maxDelay:    cfg.EmbedRetryMaxDelay,
+			retryable:   isEmbedRetryable,
+		},
+	}
+}
+
+func (re *retryingEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	var embeddings [][]float32
+	attempt := 0
+	err := re.policy.do(ctx, func() error {
+		if attempt++; attempt > 1 {
+			re.retries.Add(1)
+			embeddingRetries.Inc()
+		}
+		var err error
+		embeddings, err = re.embedder.Embed(ctx, texts)
+		return err
+	})
+	return embeddings, err
+}
+
+// Novas tentativas feitas desde o início da execução
+func (re *retryingEmbedder) retryCount() int64 {
+	return re.retries.Load()
+}
+
+// Preenche o vetor de cada documento chamando o embedder em lotes de
+// EmbedBatchSize textos, e os vetores nomeados de embed_field. A dimensão é
+// validada no upsert, conforme OnDimMismatch.
+func embedDocuments(ctx context.Context, embedder Embedder, docs []DocumentData, cfg *Config) error {
+	for start := 0; start < len(docs); start += cfg.EmbedBatchSize {
+		end := min(start+cfg.EmbedBatchSize, len(docs))
+
+		texts := make([]string, 0, end-start)
+		for _, doc := range docs[start:end] {
+			texts = append(texts, doc.Texto)
+		}
+
+		embeddings, err := embedder.Embed(ctx, texts)
+		if err != nil {
+			return fmt.Errorf("documentos %d-%d: %w", start, end-1, err)
+		}
+
+		for i, embedding := range embeddings {
+			docs[start+i].Vector = embedding
+		}
+	}
+
+	return embedFieldVectors(ctx, embedder, docs, cfg)
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Agrega os erros da exportação por categoria (etapa e causa, como
+// "upsert: Qdrant Unavailable"). Apenas as primeiras limit ocorrências de
+// cada categoria são registradas no log; as demais são só contadas e
+// aparecem no resumo final.
+type errorLog struct {
+	limit int
+
+	mu     sync.Mutex
+	counts map[string]int
+	order  []string
+}
+
+// Quantidade de erros de uma categoria
+type errorCount struct {
+	Category string `json:"category"`
+	Count    int    `json:"count"`
+}
+
+func newErrorLog(limit int) *errorLog {
+	return &errorLog{limit: limit, counts: make(map[string]int)}
+}
+
+// Contabiliza err na etapa stage (busca, embedding ou upsert) e o registra no
+// log se a categoria ainda não atingiu o limite. args são campos adicionais
+// do log estruturado.
+func (l *errorLog) record(stage string, err error, args ...any) {
+	category := stage + ": " + errorCause(err)
+
+	l.mu.Lock()
+	l.counts[category]++
+	n := l.counts[category]
+	if n == 1 {
+		l.order = append(l.order, category)
+	}
+	l.mu.Unlock()
+	errorsTotal.WithLabelValues(category).Inc()
+
+	switch {
+	case n <= l.limit:
+		args = append(args, "stage", stage, "category", category, "error", err.Error())
+		logErrorEvent("error", err.Error(), args...)
+	case n == l.limit+1:
+		logWarnf("Mais de %d erros da categoria %q; as próximas ocorrências serão apenas contabilizadas", l.limit, category)
+	}
+}
+
+// Total de erros registrados
+func (l *errorLog) total() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n := 0
+	for _, c := range l.counts {
+		n += c
+	}
+	return n
+}
+
+// Erros por categoria, da mais frequente para a menos frequente
+func (l *errorLog) summary() []errorCount {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	summary := make([]errorCount, 0, len(l.order))
+	for _, category := range l.order {
+		summary = append(summary, errorCount{Category: category, Count: l.counts[category]})
+	}
+	slices.SortStableFunc(summary, func(a, b errorCount) int { return b.Count - a.Count })
+	return summary
+}
+
+// Exibe o resumo dos erros agrupados por categoria
+func (l *errorLog) logSummary() {
+	summary := l.summary()
+	if len(summary) == 0 {
+		return
+	}
+
+	log.Printf("Resumo de erros (%d ocorrência(s)):", l.total())
+	for _, c := range summary {
+		logEvent("error_summary", fmt.Sprintf("  %s: %d", c.Category, c.Count),
+			"category", c.Category, "count", c.Count)
+	}
+}
+
+// Causa do erro para agrupamento: status HTTP, dimensão do vetor, código
+// gRPC do Qdrant, falha de conexão ou timeout
+func errorCause(err error) string {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return fmt.Sprintf("HTTP %d", httpErr.StatusCode)
+	}
+	var dimErr *DimensionError
+	if errors.As(err, &dimErr) {
+		return "dimensão do vetor"
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "timeout"
+	}
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		if urlErr.Timeout() {
+			return "timeout"
+		}
+		return "falha de conexão"
+	}
+	if code := status.Code(err); code != codes.OK && code != codes.Unknown {
+		return "Qdrant " + code.String()
+	}
+	return "outros"
+}
+
+// Erros de vários lotes de upsert, preservando cada causa para errors.As
+type batchErrors []error
+
+func (e batchErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("falha em %d lote(s): %s", len(e), strings.Join(msgs, "; "))
+}
+
+func (e batchErrors) Unwrap() []error {
+	return e
+}
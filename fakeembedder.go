@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"math"
+	"math/rand/v2"
+)
+
+// Embedder determinístico para testes de integração (-embedder fake): o
+// vetor de cada texto é pseudoaleatório, gerado com uma semente derivada do
+// SHA-256 do texto, e normalizado para norma 1, como os da OpenAI. Textos
+// iguais têm sempre o mesmo vetor e textos diferentes, vetores distintos,
+// então as buscas por similaridade nos testes encontram o próprio documento
+// sem chamar nenhum serviço. Os componentes são gerados diretamente do PCG de
+// math/rand/v2, cujo algoritmo é fixo, para que os vetores não mudem entre
+// versões do Go.
+type FakeEmbedder struct {
+	size int
+}
+
+func NewFakeEmbedder(size int) *FakeEmbedder {
+	return &FakeEmbedder{size: size}
+}
+
+func (fe *FakeEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = fe.vector(text)
+	}
+	return vectors, nil
+}
+
+// Vetor unitário do texto, com componentes uniformes em [-1, 1)
+func (fe *FakeEmbedder) vector(text string) []float32 {
+	sum := sha256.Sum256([]byte(text))
+	rng := rand.New(rand.NewPCG(binary.BigEndian.Uint64(sum[:8]), binary.BigEndian.Uint64(sum[8:16])))
+
+	values := make([]float64, fe.size)
+	var norm float64
+	for i := range values {
+		values[i] = float64(rng.Uint64()>>11)/(1<<53)*2 - 1
+		norm += values[i] * values[i]
+	}
+	norm = math.Sqrt(norm)
+
+	vector := make([]float32, fe.size)
+	for i, v := range values {
+		if norm > 0 {
+			vector[i] = float32(v / norm)
+		}
+	}
+	return vector
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestFakeEmbedder(t *testing.T) {
+	tests := []struct {
+		name  string
+		size  int
+		texts []string
+	}{
+		{name: "dimensão pequena", size: 4, texts: []string{"texto", "outro texto"}},
+		{name: "dimensão da OpenAI", size: 1536, texts: []string{"texto", "texto "}},
+		{name: "texto vazio", size: 8, texts: []string{"", "a"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fe := NewFakeEmbedder(tt.size)
+			first, err := fe.Embed(context.Background(), tt.texts)
+			if err != nil {
+				t.Fatalf("Embed: %v", err)
+			}
+			second, err := fe.Embed(context.Background(), tt.texts)
+			if err != nil {
+				t.Fatalf("Embed: %v", err)
+			}
+			if len(first) != len(tt.texts) {
+				t.Fatalf("%d vetores, esperado %d", len(first), len(tt.texts))
+			}
+
+			// O mesmo texto gera sempre o mesmo vetor
+			if !reflect.DeepEqual(first, second) {
+				t.Error("vetores diferentes para os mesmos textos")
+			}
+			// Textos diferentes geram vetores diferentes
+			if reflect.DeepEqual(first[0], first[1]) {
+				t.Errorf("vetores iguais para %q e %q", tt.texts[0], tt.texts[1])
+			}
+			for i, vector := range first {
+				if len(vector) != tt.size {
+					t.Errorf("vetor de %q com dimensão %d, esperado %d", tt.texts[i], len(vector), tt.size)
+				}
+				var norm float64
+				for _, v := range vector {
+					norm += float64(v) * float64(v)
+				}
+				if norm = math.Sqrt(norm); math.Abs(norm-1) > 1e-5 {
+					t.Errorf("vetor de %q com norma %f, esperado 1", tt.texts[i], norm)
+				}
+			}
+		})
+	}
+}
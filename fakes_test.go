@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// ESSearcher que retorna páginas fixas, na ordem, e depois páginas vazias.
+// Com err definido, todas as buscas falham.
+type fakeSearcher struct {
+	pages [][]Hit
+	err   error
+
+	mu     sync.Mutex
+	calls  int
+	afters [][]interface{} // cursores recebidos no search_after
+
+	// Páginas de cada fatia do scroll fatiado, na ordem, e depois páginas
+	// vazias
+	slices     [][][]Hit
+	sliceCalls map[int]int
+}
+
+func (f *fakeSearcher) next() (*SearchResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	total := 0
+	for _, page := range f.pages {
+		total += len(page)
+	}
+
+	result := &SearchResponse{ScrollID: "scroll-" + strconv.Itoa(f.calls)}
+	result.Hits.Total.Value = total
+	if f.calls <= len(f.pages) {
+		result.Hits.Hits = f.pages[f.calls-1]
+	}
+	return result, nil
+}
+
+func (f *fakeSearcher) searchDocumentsScroll(ctx context.Context, scrollID string) (*SearchResponse, error) {
+	return f.next()
+}
+
+func (f *fakeSearcher) searchSliceScroll(ctx context.Context, slice int, scrollID string) (*SearchResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.err != nil {
+		return nil, f.err
+	}
+	if f.sliceCalls == nil {
+		f.sliceCalls = make(map[int]int)
+	}
+	f.sliceCalls[slice]++
+	calls := f.sliceCalls[slice]
+
+	pages := f.slices[slice]
+	result := &SearchResponse{ScrollID: "slice-" + strconv.Itoa(slice) + "-" + strconv.Itoa(calls)}
+	for _, page := range pages {
+		result.Hits.Total.Value += len(page)
+	}
+	if calls <= len(pages) {
+		result.Hits.Hits = pages[calls-1]
+	}
+	return result, nil
+}
+
+func (f *fakeSearcher) searchDocumentsAfter(ctx context.Context, sort []string, after []interface{}) (*SearchResponse, []interface{}, error) {
+	f.mu.Lock()
+	f.afters = append(f.afters, after)
+	f.mu.Unlock()
+
+	result, err := f.next()
+	if err != nil {
+		return nil, nil, err
+	}
+	next := after
+	if hits := result.Hits.Hits; len(hits) > 0 {
+		next = hits[len(hits)-1].Sort
+	}
+	return result, next, nil
+}
+
+// VectorStore que guarda em memória os documentos recebidos
+type fakeStore struct {
+	mu   sync.Mutex
+	docs []DocumentData
+	err  error
+}
+
+func (f *fakeStore) upsertDocuments(ctx context.Context, docs []DocumentData) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return 0, f.err
+	}
+	f.docs = append(f.docs, docs...)
+	return len(docs), nil
+}
+
+func (f *fakeStore) ids() map[uint64]bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ids := make(map[uint64]bool, len(f.docs))
+	for _, doc := range f.docs {
+		ids[doc.ID] = true
+	}
+	return ids
+}
+
+// PointScroller que pagina os pontos em ordem, como o scroll do Qdrant: o
+// offset é o ID do primeiro ponto da página
+type fakeScroller struct {
+	points   []*qdrant.RetrievedPoint
+	pageSize int
+
+	mu      sync.Mutex
+	offsets []string // offsets recebidos, "" para a primeira página
+}
+
+func (f *fakeScroller) scrollPoints(ctx context.Context, offset *qdrant.PointId) ([]*qdrant.RetrievedPoint, *qdrant.PointId, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	start := 0
+	if offset != nil {
+		f.offsets = append(f.offsets, pointKey(offset))
+		for start < len(f.points) && pointKey(f.points[start].GetId()) != pointKey(offset) {
+			start++
+		}
+	} else {
+		f.offsets = append(f.offsets, "")
+	}
+	end := min(start+f.pageSize, len(f.points))
+	var next *qdrant.PointId
+	if end < len(f.points) {
+		next = f.points[end].GetId()
+	}
+	return f.points[start:end], next, nil
+}
+
+// PointIndexer que guarda os IDs recebidos e chama onBatch após cada lote
+type fakeIndexer struct {
+	mu      sync.Mutex
+	ids     []string
+	batches int
+	onBatch func(batch int)
+}
+
+func (f *fakeIndexer) bulkIndex(ctx context.Context, index string, points []*qdrant.RetrievedPoint, errLog *errorLog) (int, int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, point := range points {
+		f.ids = append(f.ids, pointKey(point.GetId()))
+	}
+	f.batches++
+	if f.onBatch != nil {
+		f.onBatch(f.batches)
+	}
+	return len(points), 0
+}
+
+var errFakeSearch = errors.New("falha simulada")
+
+// Configuração mínima para os testes, sem backends reais
+func testConfig() *Config {
+	return &Config{
+		PageSize:        10,
+		PaginationMode:  "scroll",
+		SortField:       "id",
+		IDField:         "id",
+		TextField:       "texto",
+		SourceFields:    []string{"id", "texto"},
+		VectorSize:      4,
+		OnDimMismatch:   "fail",
+		TextSeparator:   "\n",
+		TextArrayMixed:  "stringify",
+		ShardKeyDefault: "default",
+		UpsertBatchSize: 3,
+		EmbedBatchSize:  2,
+		Workers:         2,
+		ErrorLogLimit:   5,
+		RateLimit:       1000, // sem a pausa entre lotes
+	}
+}
+
+// Página de hits com IDs numéricos de first a first+n-1, como o
+// Elasticsearch os retorna com UseNumber
+func testHits(first, n int) []Hit {
+	hits := make([]Hit, n)
+	for i := range hits {
+		id := first + i
+		hits[i] = Hit{
+			ID: strconv.Itoa(id),
+			Source: map[string]interface{}{
+				"id":    json.Number(strconv.Itoa(id)),
+				"texto": "documento " + strconv.Itoa(id),
+			},
+			Sort: []interface{}{json.Number(strconv.Itoa(id))},
+		}
+	}
+	return hits
+}
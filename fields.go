@@ -0,0 +1,238 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Campo do template da entrada do embedding, como {title} ou {content.body}
+var templatePlaceholder = regexp.MustCompile(`\{([^{}]+)\}`)
+
+// Resolve um campo do _source pelo caminho com pontos (ex: "content.body"),
+// descendo pelos objetos aninhados. Uma chave literal com pontos tem
+// precedência sobre o caminho. Retorna false quando uma chave intermediária
+// não existe ou não é um objeto, como em arrays de objetos.
+func lookupField(source map[string]interface{}, path string) (interface{}, bool) {
+	if v, ok := source[path]; ok {
+		return v, true
+	}
+
+	var current interface{} = source
+	for _, key := range strings.Split(path, ".") {
+		obj, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if current, ok = obj[key]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// Indica se o campo é um padrão com curinga, como "content.*"
+func isFieldPattern(field string) bool {
+	return strings.Contains(field, "*")
+}
+
+// Compara o caminho com o padrão do filtro _source, em que * corresponde a
+// qualquer sequência de caracteres, inclusive pontos, como no Elasticsearch
+func matchFieldPattern(pattern, path string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == path
+	}
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	path = path[len(parts[0]):]
+	last := parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(path, part)
+		if i < 0 {
+			return false
+		}
+		path = path[i+len(part):]
+	}
+	return strings.HasSuffix(path, last)
+}
+
+// Copia para o payload, pelo caminho completo, cada valor do documento que
+// casa com o padrão e não foi descartado por SourceExcludes. Os objetos são
+// percorridos até os valores; arrays são copiados inteiros. O ID, o texto e o
+// vetor não são copiados, como nos campos informados pelo nome. Retorna false
+// se nenhum campo casou com o padrão.
+func copyMatchingFields(payload, source map[string]interface{}, pattern string, cfg *Config) bool {
+	found := false
+	walkFields(source, "", func(path string, v interface{}) {
+		if !matchFieldPattern(pattern, path) {
+			return
+		}
+		if _, excluded := cfg.excludedField(path); excluded {
+			return
+		}
+		found = true
+		if path == cfg.idPayloadField() || path == cfg.TextField || path == cfg.EmbeddingField {
+			return
+		}
+		setField(payload, cfg.payloadKey(path), normalizeJSON(v))
+	})
+	return found
+}
+
+// Chama fn com o caminho e o valor de cada campo que não é objeto
+func walkFields(obj map[string]interface{}, prefix string, fn func(path string, v interface{})) {
+	for key, v := range obj {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+		if child, ok := v.(map[string]interface{}); ok && len(child) > 0 {
+			walkFields(child, path, fn)
+			continue
+		}
+		fn(path, v)
+	}
+}
+
+// Grava o valor no payload no mesmo caminho do _source, criando os objetos
+// intermediários, para que o Qdrant filtre por "content.body" como no
+// Elasticsearch. Se um trecho do caminho já guarda um valor que não é
+// objeto, o campo é gravado com a chave completa.
+func setField(payload map[string]interface{}, path string, value interface{}) {
+	keys := strings.Split(path, ".")
+	current := payload
+	for _, key := range keys[:len(keys)-1] {
+		next, ok := current[key]
+		if !ok {
+			obj := make(map[string]interface{})
+			current[key] = obj
+			current = obj
+			continue
+		}
+		obj, ok := next.(map[string]interface{})
+		if !ok {
+			payload[path] = value
+			return
+		}
+		current = obj
+	}
+	current[keys[len(keys)-1]] = value
+}
+
+// Campos usados no template, na ordem em que aparecem
+func templateFields(template string) []string {
+	var fields []string
+	for _, match := range templatePlaceholder.FindAllStringSubmatch(template, -1) {
+		field := strings.TrimSpace(match[1])
+		if !slices.Contains(fields, field) {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// Interpreta \n e \t escritos literalmente no template, como em
+// -embed-template '{title}\n{body}'
+func unescapeTemplate(template string) string {
+	return strings.NewReplacer(`\n`, "\n", `\t`, "\t").Replace(template)
+}
+
+// Monta a entrada do embedding substituindo cada campo do template pelo seu
+// valor no _source; campos ausentes viram texto vazio
+func renderTemplate(template string, source map[string]interface{}) string {
+	text := templatePlaceholder.ReplaceAllStringFunc(template, func(match string) string {
+		field := strings.TrimSpace(match[1 : len(match)-1])
+		v, _ := lookupField(source, field)
+		return textValue(normalizeJSON(v))
+	})
+	return strings.TrimSpace(text)
+}
+
+// Texto de um valor do _source: strings sem alteração, arrays com os itens
+// separados por vírgula e os demais valores formatados
+func textValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			if s := textValue(item); s != "" {
+				items = append(items, s)
+			}
+		}
+		return strings.Join(items, ", ")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// Texto de um TextField em array: os itens unidos por TextSeparator. Itens
+// que não são strings são formatados (objetos e listas em JSON) com
+// TextArrayMixed "stringify" ou ignorados com "skip"; nulos e strings vazias
+// são sempre ignorados.
+func joinTextArray(items []interface{}, cfg *Config) string {
+	parts := make([]string, 0, len(items))
+	for _, item := range items {
+		var s string
+		switch item := item.(type) {
+		case nil:
+			continue
+		case string:
+			s = item
+		case map[string]interface{}, []interface{}:
+			if cfg.TextArrayMixed == "skip" {
+				continue
+			}
+			encoded, _ := json.Marshal(item)
+			s = string(encoded)
+		default:
+			if cfg.TextArrayMixed == "skip" {
+				continue
+			}
+			s = fmt.Sprint(item)
+		}
+		if s != "" {
+			parts = append(parts, s)
+		}
+	}
+	return strings.Join(parts, cfg.TextSeparator)
+}
+
+// Contagem dos campos configurados que não puderam ser extraídos dos
+// documentos (ausentes, em arrays ou com tipo inesperado)
+type fieldCounter struct {
+	counts map[string]int
+	order  []string
+}
+
+func newFieldCounter() *fieldCounter {
+	return &fieldCounter{counts: make(map[string]int)}
+}
+
+func (fc *fieldCounter) add(fields []string) {
+	for _, field := range fields {
+		if fc.counts[field] == 0 {
+			fc.order = append(fc.order, field)
+		}
+		fc.counts[field]++
+	}
+}
+
+// Exibe a contagem por campo, na ordem em que apareceram
+func (fc *fieldCounter) logSummary() {
+	for _, field := range fc.order {
+		logEvent("field_skipped", fmt.Sprintf("Campo %q não encontrado em %d documento(s)", field, fc.counts[field]),
+			"field", field, "count", fc.counts[field])
+	}
+	if len(fc.order) > 0 {
+		log.Println("Verifique SOURCE_FIELDS e TEXT_FIELD; caminhos dentro de arrays não são resolvidos")
+	}
+}
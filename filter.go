@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Critério aplicado aos documentos lidos, antes do embedding e do upsert;
+// documentos em que retorna false são descartados
+type docPredicate func(DocumentData) bool
+
+// Predicados aceitos em FILTERS
+var docPredicates = map[string]docPredicate{
+	// Texto do embedding com algum caractere além de espaços
+	"non-empty-text": func(d DocumentData) bool {
+		return strings.TrimSpace(d.Texto) != ""
+	},
+	// ID lido do documento; sem ele todos os documentos iriam para o ponto 0
+	"valid-id": func(d DocumentData) bool {
+		return d.ID != 0 || d.UUID != ""
+	},
+	// Todos os campos configurados encontrados no _source
+	"all-fields": func(d DocumentData) bool {
+		return len(d.Missing) == 0
+	},
+}
+
+// Nomes aceitos em FILTERS, para as mensagens de erro
+const docPredicateNames = "non-empty-text, valid-id ou all-fields"
+
+// Descarta os documentos que não atendem a todos os predicados, contando os
+// descartes pelo primeiro predicado que falhou. Usado apenas pelo laço de
+// leitura, sem concorrência.
+type docFilter struct {
+	names      []string
+	predicates []docPredicate
+	counts     map[string]int
+	skipped    int
+}
+
+func newDocFilter(names []string) *docFilter {
+	f := &docFilter{names: names, counts: make(map[string]int)}
+	for _, name := range names {
+		f.predicates = append(f.predicates, docPredicates[name])
+	}
+	return f
+}
+
+// Retorna os documentos de docs que atendem a todos os predicados
+func (f *docFilter) filter(docs []DocumentData) []DocumentData {
+	kept := docs[:0:0]
+	for _, doc := range docs {
+		if failed := f.failedPredicate(doc); failed != "" {
+			f.counts[failed]++
+			f.skipped++
+			documentsFiltered.Inc()
+			continue
+		}
+		kept = append(kept, doc)
+	}
+	return kept
+}
+
+// Nome do primeiro predicado que o documento não atende, ou ""
+func (f *docFilter) failedPredicate(doc DocumentData) string {
+	for i, predicate := range f.predicates {
+		if !predicate(doc) {
+			return f.names[i]
+		}
+	}
+	return ""
+}
+
+// Documentos descartados pelos predicados
+func (f *docFilter) skippedCount() int {
+	return f.skipped
+}
+
+// Exibe os descartes de cada predicado, na ordem de FILTERS
+func (f *docFilter) logSummary() {
+	logEvent("filtered_out", fmt.Sprintf("%d documentos descartados pelos filtros", f.skipped), "filtered_out", f.skipped)
+	for _, name := range f.names {
+		if n := f.counts[name]; n > 0 {
+			log.Printf("  %s: %d", name, n)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Campos geo_point (-geo-fields): o Elasticsearch aceita um ponto em vários
+// formatos, e o Qdrant filtra por distância e área apenas no objeto
+// {"lat": ..., "lon": ...}. Cada valor dos campos configurados é convertido
+// para esse objeto, e uma lista de pontos, para uma lista de objetos. Um
+// valor em formato desconhecido ou com coordenadas fora dos limites não é
+// gravado e o campo conta como ausente.
+
+// Ponto geográfico em graus
+type geoPoint struct {
+	Lat float64
+	Lon float64
+}
+
+// Objeto do payload geo do Qdrant
+func (p geoPoint) payload() map[string]interface{} {
+	return map[string]interface{}{"lat": p.Lat, "lon": p.Lon}
+}
+
+func (p geoPoint) valid() bool {
+	return p.Lat >= -90 && p.Lat <= 90 && p.Lon >= -180 && p.Lon <= 180
+}
+
+// Converte o valor de um campo geo_point para o payload; false se o valor
+// não é um ponto (nem uma lista de pontos) válido
+func geoValue(v interface{}) (interface{}, bool) {
+	// Uma lista que não começa por um número é uma lista de pontos; [lon,
+	// lat] é um único ponto
+	if items, ok := v.([]interface{}); ok && len(items) > 0 {
+		if _, isNumber := geoNumber(items[0]); !isNumber {
+			points := make([]interface{}, len(items))
+			for i, item := range items {
+				p, ok := parseGeoPoint(item)
+				if !ok {
+					return nil, false
+				}
+				points[i] = p.payload()
+			}
+			return points, true
+		}
+	}
+	p, ok := parseGeoPoint(v)
+	if !ok {
+		return nil, false
+	}
+	return p.payload(), true
+}
+
+// Interpreta um ponto em um dos formatos do geo_point: objeto {lat, lon},
+// GeoJSON {"type": "Point", "coordinates": [lon, lat]}, array [lon, lat],
+// string "lat,lon", WKT "POINT (lon lat)" ou geohash
+func parseGeoPoint(v interface{}) (geoPoint, bool) {
+	var p geoPoint
+	var ok bool
+	switch v := v.(type) {
+	case map[string]interface{}:
+		p, ok = geoObject(v)
+	case []interface{}:
+		p, ok = geoArray(v)
+	case string:
+		p, ok = geoString(v)
+	}
+	return p, ok && p.valid()
+}
+
+func geoObject(obj map[string]interface{}) (geoPoint, bool) {
+	if typ, _ := obj["type"].(string); strings.EqualFold(typ, "point") {
+		coordinates, _ := obj["coordinates"].([]interface{})
+		return geoArray(coordinates)
+	}
+	lat, latOK := geoNumber(obj["lat"])
+	lon, lonOK := geoNumber(obj["lon"])
+	return geoPoint{Lat: lat, Lon: lon}, latOK && lonOK
+}
+
+// Array na ordem do GeoJSON, [lon, lat] com a altitude opcional
+func geoArray(items []interface{}) (geoPoint, bool) {
+	if len(items) < 2 || len(items) > 3 {
+		return geoPoint{}, false
+	}
+	lon, lonOK := geoNumber(items[0])
+	lat, latOK := geoNumber(items[1])
+	return geoPoint{Lat: lat, Lon: lon}, latOK && lonOK
+}
+
+func geoString(s string) (geoPoint, bool) {
+	s = strings.TrimSpace(s)
+	if upper := strings.ToUpper(s); strings.HasPrefix(upper, "POINT") {
+		open, end := strings.Index(s, "("), strings.LastIndex(s, ")")
+		if open < 0 || end < open {
+			return geoPoint{}, false
+		}
+		coordinates := strings.Fields(s[open+1 : end])
+		if len(coordinates) < 2 || len(coordinates) > 3 {
+			return geoPoint{}, false
+		}
+		lon, lonErr := strconv.ParseFloat(coordinates[0], 64)
+		lat, latErr := strconv.ParseFloat(coordinates[1], 64)
+		return geoPoint{Lat: lat, Lon: lon}, lonErr == nil && latErr == nil
+	}
+	if parts := strings.Split(s, ","); len(parts) > 1 {
+		if len(parts) > 3 {
+			return geoPoint{}, false
+		}
+		lat, latErr := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+		lon, lonErr := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		return geoPoint{Lat: lat, Lon: lon}, latErr == nil && lonErr == nil
+	}
+	return decodeGeohash(s)
+}
+
+// Coordenada numérica; o Elasticsearch aceita também números em strings
+// nos objetos {lat, lon}
+func geoNumber(v interface{}) (float64, bool) {
+	var f float64
+	switch v := v.(type) {
+	case json.Number:
+		var err error
+		if f, err = v.Float64(); err != nil {
+			return 0, false
+		}
+	case float64:
+		f = v
+	case int64:
+		f = float64(v)
+	case string:
+		var err error
+		if f, err = strconv.ParseFloat(strings.TrimSpace(v), 64); err != nil {
+			return 0, false
+		}
+	default:
+		return 0, false
+	}
+	return f, !math.IsNaN(f) && !math.IsInf(f, 0)
+}
+
+const geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// Centro da célula do geohash; os bits alternam entre longitude e latitude,
+// começando pela longitude
+func decodeGeohash(hash string) (geoPoint, bool) {
+	if hash == "" || len(hash) > 12 {
+		return geoPoint{}, false
+	}
+	latMin, latMax := -90.0, 90.0
+	lonMin, lonMax := -180.0, 180.0
+	even := true
+	for _, c := range strings.ToLower(hash) {
+		bits := strings.IndexRune(geohashAlphabet, c)
+		if bits < 0 {
+			return geoPoint{}, false
+		}
+		for mask := 16; mask > 0; mask >>= 1 {
+			if even {
+				mid := (lonMin + lonMax) / 2
+				if bits&mask != 0 {
+					lonMin = mid
+				} else {
+					lonMax = mid
+				}
+			} else {
+				mid := (latMin + latMax) / 2
+				if bits&mask != 0 {
+					latMin = mid
+				} else {
+					latMax = mid
+				}
+			}
+			even = !even
+		}
+	}
+	return geoPoint{Lat: (latMin + latMax) / 2, Lon: (lonMin + lonMax) / 2}, true
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Embedder para servidores de embeddings próprios (text-embeddings-inference,
+// Ollama com adaptador compatível etc.): envia {"inputs": [...]} por POST e
+// espera como resposta um array JSON com um vetor por texto, na mesma ordem
+type HTTPEmbedder struct {
+	httpClient *http.Client
+	url        string
+	headers    map[string]string
+}
+
+type httpEmbeddingRequest struct {
+	Inputs []string `json:"inputs"`
+}
+
+func NewHTTPEmbedder(url string, timeout time.Duration, headers map[string]string) *HTTPEmbedder {
+	return &HTTPEmbedder{
+		httpClient: &http.Client{
+			Timeout: timeout,
+		},
+		url:     url,
+		headers: headers,
+	}
+}
+
+func (he *HTTPEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	body, err := json.Marshal(httpEmbeddingRequest{Inputs: texts})
+	if err != nil {
+		return nil, fmt.Errorf("erro ao montar requisição de embeddings: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", he.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("erro ao criar requisição: %v", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range he.headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := he.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao executar requisição: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, &HTTPError{
+			StatusCode: resp.StatusCode,
+			Body:       string(respBody),
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
+	}
+
+	var embeddings [][]float32
+	if err := json.NewDecoder(resp.Body).Decode(&embeddings); err != nil {
+		return nil, fmt.Errorf("erro ao decodificar resposta: %v", err)
+	}
+
+	if len(embeddings) != len(texts) {
+		return nil, fmt.Errorf("esperados %d embeddings, recebidos %d", len(texts), len(embeddings))
+	}
+
+	return embeddings, nil
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// Namespace usado na geração de UUIDs v5 a partir de IDs textuais
+// (namespace URL da RFC 4122)
+var idNamespace = [16]byte{
+	0x6b, 0xa7, 0xb8, 0x11, 0x9d, 0xad, 0x11, 0xd1,
+	0x80, 0xb4, 0x00, 0xc0, 0x4f, 0xd4, 0x30, 0xc8,
+}
+
+// Chave do payload com o namespace dos IDs, com ID_PREFIX ou ID_OFFSET
+const idNamespaceField = "id_namespace"
+
+// ID do ponto no Qdrant: numérico em ID ou, para IDs textuais, um UUID
+func (d DocumentData) pointID() *qdrant.PointId {
+	if d.UUID != "" {
+		return qdrant.NewID(d.UUID)
+	}
+	return qdrant.NewIDNum(d.ID)
+}
+
+// ID do documento para logs
+func (d DocumentData) idString() string {
+	if d.UUID != "" {
+		return d.UUID
+	}
+	return strconv.FormatUint(d.ID, 10)
+}
+
+// Interpreta o ID vindo do Elasticsearch. Números e strings numéricas viram
+// IDs numéricos; UUIDs são usados sem alteração e outras strings (hashes,
+// chaves compostas) são convertidas em um UUID v5 determinístico, de modo que
+// reexecuções gravem sempre no mesmo ponto. O Qdrant aceita apenas esses dois
+// tipos de ID.
+func parsePointID(v interface{}) (id uint64, uuid string, err error) {
+	switch v := v.(type) {
+	case int64:
+		if v < 0 {
+			return 0, "", fmt.Errorf("ID numérico negativo: %d", v)
+		}
+		return uint64(v), "", nil
+	case float64:
+		if v < 0 || v != float64(uint64(v)) {
+			return 0, "", fmt.Errorf("ID numérico inválido: %v", v)
+		}
+		return uint64(v), "", nil
+	case string:
+		if v == "" {
+			return 0, "", fmt.Errorf("ID vazio")
+		}
+		if n, err := strconv.ParseUint(v, 10, 64); err == nil {
+			return n, "", nil
+		}
+		if isUUID(v) {
+			return 0, v, nil
+		}
+		return 0, uuidV5(v), nil
+	case nil:
+		return 0, "", fmt.Errorf("ID ausente")
+	default:
+		return 0, "", fmt.Errorf("tipo de ID não suportado: %T", v)
+	}
+}
+
+// UUID v5 da chave composta: os valores dos campos, na ordem de fields, são
+// serializados como um array JSON, o que separa os valores sem ambiguidade
+// ("a,b" + "c" difere de "a" + "b,c"). "_id" usa o ID do hit. Campos ausentes,
+// vazios ou com objetos e listas tornam o ID inválido.
+func compositeUUID(hit Hit, source map[string]interface{}, fields []string) (string, error) {
+	values := make([]interface{}, len(fields))
+	for i, field := range fields {
+		var v interface{} = hit.ID
+		if field != "_id" {
+			v, _ = lookupField(source, field)
+			v = normalizeJSON(v)
+		}
+		switch v := v.(type) {
+		case nil:
+			return "", fmt.Errorf("campo %s ausente", field)
+		case string:
+			if v == "" {
+				return "", fmt.Errorf("campo %s vazio", field)
+			}
+		case int64, float64, bool:
+		default:
+			return "", fmt.Errorf("tipo não suportado no campo %s: %T", field, v)
+		}
+		values[i] = v
+	}
+
+	key, err := json.Marshal(values)
+	if err != nil {
+		return "", fmt.Errorf("erro ao montar a chave composta: %v", err)
+	}
+	return uuidV5("fields:" + string(key)), nil
+}
+
+// Namespace do ID já extraído, para unir vários índices numa coleção sem
+// colisões: com IDOffset, o offset é somado aos IDs numéricos (UUIDs não
+// mudam); com IDPrefix, o ponto recebe o UUID v5 de prefixo+ID, como
+// "index1-42". Um ID que ultrapassaria uint64 com o offset fica inválido.
+func (d *DocumentData) namespaceID(cfg *Config) {
+	switch {
+	case cfg.IDPrefix != "":
+		d.UUID = uuidV5(cfg.IDPrefix + d.idString())
+		d.ID = 0
+	case cfg.IDOffset > 0 && d.UUID == "":
+		if d.ID > math.MaxUint64-cfg.IDOffset {
+			d.InvalidID = fmt.Errorf("ID %d com ID_OFFSET %d ultrapassa o maior ID numérico", d.ID, cfg.IDOffset)
+			d.ID = 0
+			return
+		}
+		d.ID += cfg.IDOffset
+	}
+}
+
+// UUID determinístico do documento sem ID válido (ON_MISSING_ID=hash): o
+// UUID v5 do _id do hit, ou do _source na falta dele
+func fallbackUUID(hit Hit) string {
+	name := hit.ID
+	if name == "" {
+		source, _ := json.Marshal(hit.Source)
+		name = string(source)
+	}
+	return uuidV5("_id:" + name)
+}
+
+// Verifica o formato canônico 8-4-4-4-12 em hexadecimal
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i, c := range s {
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return false
+			}
+		default:
+			if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// UUID versão 5 (SHA-1) do nome no namespace idNamespace
+func uuidV5(name string) string {
+	h := sha1.New()
+	h.Write(idNamespace[:])
+	h.Write([]byte(name))
+	sum := h.Sum(nil)
+
+	var u [16]byte
+	copy(u[:], sum[:16])
+	u[6] = (u[6] & 0x0f) | 0x50
+	u[8] = (u[8] & 0x3f) | 0x80
+
+	buf := make([]byte, 36)
+	hex.Encode(buf[0:8], u[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], u[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], u[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], u[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], u[10:])
+
+	return string(buf)
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// Logs estruturados em JSON (LOG_FORMAT=json); no formato texto apenas as
+// mensagens são registradas, sem os campos
+var jsonLogs bool
+
+// Nível mínimo dos logs (LOG_LEVEL). As mensagens do pacote log, sem nível
+// próprio, são tratadas como info.
+var logLevel = new(slog.LevelVar)
+
+// Modo silencioso (QUIET): o nível mínimo passa a ser warn, mas o resumo
+// final de logSummaryEvent continua sendo registrado
+var quietLogs bool
+
+// Saída dos logs de texto com nível, que não passa pelo filtro de info
+var levelLog = log.New(os.Stderr, "", log.LstdFlags)
+
+var logLevels = map[string]slog.Level{
+	"debug": slog.LevelDebug,
+	"info":  slog.LevelInfo,
+	"warn":  slog.LevelWarn,
+	"error": slog.LevelError,
+}
+
+// Configura o formato e o nível dos logs. Em json, o slog passa a ser também
+// a saída do pacote log, então as mensagens sem campos estruturados viram
+// linhas JSON com apenas time, level e msg. Em texto, acima de info as
+// mensagens do pacote log são descartadas. Com quiet, o nível é no mínimo
+// warn.
+func setupLogging(format, level string, quiet bool) {
+	logLevel.Set(logLevels[level])
+	if quiet {
+		logLevel.Set(max(logLevels[level], slog.LevelWarn))
+		quietLogs = true
+	}
+	if format != "json" {
+		log.SetOutput(infoWriter{os.Stderr})
+		return
+	}
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: logLevel})))
+	jsonLogs = true
+}
+
+// Saída do pacote log no formato texto, descartada quando LOG_LEVEL está
+// acima de info
+type infoWriter struct {
+	w io.Writer
+}
+
+func (iw infoWriter) Write(p []byte) (int, error) {
+	if !logEnabled(slog.LevelInfo) {
+		return len(p), nil
+	}
+	return iw.w.Write(p)
+}
+
+func logEnabled(level slog.Level) bool {
+	return level >= logLevel.Level()
+}
+
+// Registra uma mensagem no nível indicado: em json, com os pares chave/valor
+// de args como campos; em texto, apenas a mensagem
+func logAt(level slog.Level, msg string, args ...any) {
+	if !logEnabled(level) {
+		return
+	}
+	if !jsonLogs {
+		levelLog.Print(msg)
+		return
+	}
+	slog.Log(context.Background(), level, msg, args...)
+}
+
+// Registra um evento, com o nome do evento entre os campos do formato json
+func logEvent(event, msg string, args ...any) {
+	logAt(slog.LevelInfo, msg, append([]any{"event", event}, args...)...)
+}
+
+// Como logEvent, para o resumo final da execução, que QUIET não suprime
+func logSummaryEvent(event, msg string, args ...any) {
+	args = append([]any{"event", event}, args...)
+	if !quietLogs || logEnabled(slog.LevelInfo) {
+		logAt(slog.LevelInfo, msg, args...)
+		return
+	}
+	if !jsonLogs {
+		levelLog.Print(msg)
+		return
+	}
+	// O handler não volta a conferir o nível
+	record := slog.NewRecord(time.Now(), slog.LevelInfo, msg, 0)
+	record.Add(args...)
+	slog.Default().Handler().Handle(context.Background(), record)
+}
+
+// Como logEvent, com nível de erro
+func logErrorEvent(event, msg string, args ...any) {
+	logAt(slog.LevelError, msg, append([]any{"event", event}, args...)...)
+}
+
+// Como logEvent, com nível debug: detalhes por lote, suprimidos por padrão
+func logDebugEvent(event, msg string, args ...any) {
+	logAt(slog.LevelDebug, msg, append([]any{"event", event}, args...)...)
+}
+
+func logDebugf(format string, args ...any) {
+	logAt(slog.LevelDebug, fmt.Sprintf(format, args...))
+}
+
+func logWarnf(format string, args ...any) {
+	logAt(slog.LevelWarn, fmt.Sprintf(format, args...))
+}
+
+func logErrorf(format string, args ...any) {
+	logAt(slog.LevelError, fmt.Sprintf(format, args...))
+}
+
+// Registra o erro, visível em qualquer LOG_LEVEL, e encerra com código de
+// saída 1. Substitui log.Fatalf, cujas mensagens seriam tratadas como info.
+func fatalf(format string, args ...any) {
+	logErrorf(format, args...)
+	os.Exit(1)
+}
+
+// Duração em milissegundos, para os campos duration_ms
+func durationMs(start time.Time) int64 {
+	return time.Since(start).Milliseconds()
+}
@@ -2,279 +2,518 @@ package main
 
 import (
 	"context"
-	"crypto/tls"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
-	"strings"
+	"maps"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
 	"time"
-	"github.com/qdrant/go-client/qdrant"
 )
 
-const (
-	esURL          = "https://elastic:9200/index/_search"
-	username       = "usuario_elastic"
-	password       = "senha_elastic"
-	pageSize       = 1000
-	// Configurações Qdrant
-	collectionName = "nome_collection_qdrant"
-	vectorSize     = 1536
-	qdrantHost = "localhost"
-	qdrantPort = 6334
-)
-
-// Estruturas para resposta do Elasticsearch
-type Hit struct {
-	Source map[string]interface{} `json:"_source"`
-}
-
-type HitsContainer struct {
-	Total struct {
-		Value int `json:"value"`
-	} `json:"total"`
-	Hits []Hit `json:"hits"`
-}
-
-type SearchResponse struct {
-	Hits HitsContainer `json:"hits"`
-}
-
-// Estrutura para dados do documento
-type DocumentData struct {
-	ID     uint64
-	Texto  string
-}
-
-// Cliente personalizado para Elasticsearch
-type ElasticsearchClient struct {
-	httpClient *http.Client
-}
-
-// Cliente personalizado para Qdrant
-type QdrantClient struct {
-	client *qdrant.Client
-}
-
-func NewElasticsearchClient() *ElasticsearchClient {
-	return &ElasticsearchClient{
-		httpClient: &http.Client{
-			Transport: &http.Transport{
-				TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-			},
-			Timeout: 10 * time.Second,
-		},
+func main() {
+	cfg, err := LoadConfig(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
 	}
-}
-
-func NewQdrantClient() (*QdrantClient, error) {
-	client, err := qdrant.NewClient(&qdrant.Config{
-		Host: qdrantHost,
-		Port: qdrantPort,
-	})
 	if err != nil {
-		return nil, fmt.Errorf("erro ao conectar com Qdrant: %v", err)
+		log.Fatalf("Erro na configuração: %v", err)
 	}
 
-	return &QdrantClient{
-		client: client,
-	}, nil
-}
+	setupLogging(cfg.LogFormat, cfg.LogLevel, cfg.Quiet)
+	inicioExportacao := time.Now()
 
-func (qc *QdrantClient) Close() error {
-	return qc.client.Close()
-}
+	inicioMsg := "Iniciando exportação Elasticsearch → Qdrant"
+	if cfg.Direction == "qdrant-to-es" {
+		inicioMsg = "Iniciando exportação Qdrant → Elasticsearch"
+	} else if cfg.Verify > 0 {
+		inicioMsg = "Iniciando verificação Elasticsearch → Qdrant"
+	} else if cfg.SamplePayloads > 0 {
+		inicioMsg = "Iniciando amostra dos payloads do Elasticsearch"
+	}
+	logEvent("start", inicioMsg,
+		"index", cfg.indexName(), "collection", cfg.CollectionName, "dry_run", cfg.DryRun)
+	if cfg.DryRun && cfg.Direction == "qdrant-to-es" {
+		log.Println("Modo dry-run: nenhum dado será gravado no Elasticsearch")
+	} else if cfg.DryRun && cfg.Verify == 0 && cfg.SamplePayloads == 0 {
+		log.Println("Modo dry-run: nenhum dado será gravado no Qdrant")
+	}
 
-func (ec *ElasticsearchClient) searchDocuments(from int) (*SearchResponse, error) {
-	query := fmt.Sprintf(`{
-		"size": %d,
-		"from": %d,
-		"track_total_hits": true,
-		"_source": [
-			"id",
-			"texto"
-		],
-		"query": {
-			"match_all": {}
-		}
-	}`, pageSize, from)
-
-	req, err := http.NewRequest("POST", esURL, strings.NewReader(query))
-	if err != nil {
-		return nil, fmt.Errorf("erro ao criar requisição: %v", err)
+	// Encerrado ao final da exportação, inclusive quando interrompida
+	stopMetrics := func() {}
+	if cfg.MetricsAddr != "" {
+		stopMetrics = startMetricsServer(cfg.MetricsAddr)
 	}
+	defer stopMetrics()
+
+	// Cancelado no primeiro SIGINT/SIGTERM; a partir daí o tratamento padrão
+	// é restaurado, e um segundo sinal encerra o processo imediatamente
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
 
-	req.SetBasicAuth(username, password)
-	req.Header.Set("Content-Type", "application/json")
+	// Gravações e a liberação do scroll não são canceladas pelo sinal, para
+	// que o lote em andamento seja concluído antes de encerrar
+	writeCtx := context.WithoutCancel(ctx)
 
-	resp, err := ec.httpClient.Do(req)
+	// Inicializar clientes
+	esClient, err := NewElasticsearchClient(cfg)
 	if err != nil {
-		return nil, fmt.Errorf("erro ao executar requisição: %v", err)
+		fatalf("Erro ao configurar cliente do Elasticsearch: %v", err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("erro HTTP %d: %s", resp.StatusCode, string(body))
+	// Amostra dos pontos da primeira página, sem embeddings nem Qdrant
+	if cfg.SamplePayloads > 0 {
+		if !runSamplePayloads(ctx, cfg, esClient) {
+			stopMetrics()
+			os.Exit(1)
+		}
+		return
 	}
-
-	var result SearchResponse
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("erro ao decodificar resposta: %v", err)
+	var embedder Embedder
+	switch cfg.Embedder {
+	case "http":
+		embedder = NewHTTPEmbedder(cfg.EmbedURL, cfg.EmbedTimeout, cfg.EmbedHeaders)
+	case "fake":
+		embedder = NewFakeEmbedder(cfg.VectorSize)
+	default:
+		embedder = NewOpenAIEmbedder(cfg.OpenAIAPIKey, cfg.OpenAIModel)
+	}
+	// Novas tentativas com a política própria dos embeddings (EMBED_MAX_RETRIES)
+	embedRetrier := newRetryingEmbedder(embedder, cfg)
+	embedder = embedRetrier
+
+	// Destino dos pontos: o Qdrant ou, com -output, um arquivo JSON lines
+	var qdrantClient *QdrantClient
+	var sink Sink
+	if cfg.Output != "" {
+		fileSink, err := newFileSink(cfg)
+		if err != nil {
+			fatalf("Erro na saída: %v", err)
+		}
+		log.Printf("Os pontos serão gravados em %s, sem conexão com o Qdrant", cfg.Output)
+		sink = fileSink
+	} else {
+		qdrantClient, err = NewQdrantClient(cfg)
+		if err != nil {
+			fatalf("Erro ao conectar com Qdrant: %v", err)
+		}
+		sink = qdrantClient
+	}
+	defer sink.Close()
+
+	// Exportação inversa, da coleção para o índice de ES_URL
+	if cfg.Direction == "qdrant-to-es" {
+		if !runReverseExport(ctx, writeCtx, cfg, esClient, qdrantClient) {
+			sink.Close()
+			stopMetrics()
+			os.Exit(1)
+		}
+		return
 	}
 
-	return &result, nil
-}
-
-func extractDocumentData(hit Hit) DocumentData {
-	data := DocumentData{}
+	// Verificação de uma exportação anterior, sem gravar nos backends
+	if cfg.Verify > 0 {
+		if !runVerify(ctx, cfg, esClient, qdrantClient) {
+			sink.Close()
+			stopMetrics()
+			os.Exit(1)
+		}
+		return
+	}
 
-	// Extrair ID
-	if v, ok := hit.Source["id"].(float64); ok {
-		data.ID = uint64(v)
+	// Diferença entre o índice e a coleção, sem gravar nos backends
+	if cfg.Diff {
+		if !runDiff(ctx, cfg, esClient, qdrantClient) {
+			sink.Close()
+			stopMetrics()
+			os.Exit(1)
+		}
+		return
 	}
 
-	// Extrair campos de texto
-	if v, ok := hit.Source["texto"].(string); ok {
-		data.Texto = v
+	// Validar os backends antes de ler qualquer documento
+	if !cfg.SkipPreflight {
+		if err := preflight(ctx, cfg, esClient, embedder, qdrantClient); err != nil {
+			fatalf("Falha na verificação inicial: %v", err)
+		}
 	}
 
-	return data
-}
+	// Cache de embeddings, apenas quando os embeddings serão gerados; os do
+	// embedder fake são recalculados sem custo
+	var cache *embeddingCache
+	if cfg.EmbedCache != "" && !cfg.NoCache && cfg.generatesEmbeddings() && cfg.Embedder != "fake" {
+		cache, err = openEmbeddingCache(cfg.EmbedCache, cfg.embeddingModel())
+		if err != nil {
+			fatalf("Erro no cache de embeddings: %v", err)
+		}
+		defer cache.Close()
+		embedder = &cachedEmbedder{embedder: embedder, cache: cache}
+	}
 
-func generateEmbedding(texto string) []float32 {
-	embedding := make([]float32, vectorSize)
-	return embedding
-}
+	// Criar coleção no Qdrant; com COLLECTION_TEMPLATE, as coleções são
+	// criadas no primeiro upsert de cada uma. Com SKIP_CREATE, a coleção
+	// provisionada fora da migração não é criada nem consultada.
+	if qdrantClient != nil && cfg.SkipCreate {
+		logEvent("collection_skipped", fmt.Sprintf("Criação da coleção ignorada (-skip-create): '%s' precisa já existir no Qdrant", cfg.CollectionName),
+			"collection", cfg.CollectionName)
+		for _, index := range cfg.PayloadIndexes {
+			logWarnf("Índice de payload '%s' (%s) não é criado com -skip-create; crie-o junto com a coleção", index.Field, index.Type)
+		}
+	} else if qdrantClient != nil && cfg.PayloadOnly {
+		if err := qdrantClient.requireCollection(ctx, cfg.CollectionName); err != nil {
+			fatalf("Erro na coleção: %v", err)
+		}
+		if err := qdrantClient.createPayloadIndexes(ctx, cfg.CollectionName); err != nil {
+			fatalf("Erro ao criar índices de payload: %v", err)
+		}
+	} else if qdrantClient != nil && cfg.CollectionTemplate == "" {
+		if cfg.Recreate {
+			if err := qdrantClient.deleteCollection(ctx, cfg.CollectionName); err != nil {
+				fatalf("Erro ao recriar coleção: %v", err)
+			}
+		}
+		log.Println("Criando coleção no Qdrant...")
+		if err := qdrantClient.createCollection(ctx, cfg.CollectionName); err != nil {
+			fatalf("Erro ao criar coleção: %v", err)
+		}
+		if err := qdrantClient.createPayloadIndexes(ctx, cfg.CollectionName); err != nil {
+			fatalf("Erro ao criar índices de payload: %v", err)
+		}
+		logEvent("collection_model", fmt.Sprintf("Coleção '%s': embeddings de %s (dimensão %d)", cfg.CollectionName, cfg.embeddingModel(), cfg.VectorSize),
+			"collection", cfg.CollectionName, "embedding_model", cfg.embeddingModel(), "vector_size", cfg.VectorSize)
+	}
 
-func (qc *QdrantClient) createCollection() error {
-	exists, err := qc.client.CollectionExists(context.Background(), collectionName)
-	if err != nil {
-		return fmt.Errorf("erro ao verificar se coleção existe: %v", err)
+	// Snapshot consistente do índice para o search_after
+	if cfg.UsePIT {
+		if err := esClient.openPIT(ctx); err != nil {
+			fatalf("Erro ao abrir point-in-time: %v", err)
+		}
+		log.Printf("Point-in-time aberto em '%s' (keep_alive %s)", cfg.indexName(), cfg.ScrollTTL)
 	}
 
-	if exists {
-		log.Printf("Coleção '%s' já existe", collectionName)
-		return nil
+	// Processar documentos em lotes usando scroll ou search_after
+	r := &reader{cfg: cfg, es: esClient}
+
+	// Retomar do checkpoint, se houver. No search_after a busca continua a
+	// partir do cursor; no scroll os documentos já gravados são lidos de novo
+	// e descartados, o que pressupõe a mesma ordem de retorno.
+	var ckpt *Checkpoint
+	if cfg.Checkpoint != "" {
+		ckpt, err = loadCheckpoint(cfg.Checkpoint, cfg)
+		if err != nil {
+			fatalf("Erro no checkpoint: %v", err)
+		}
+		if ckpt != nil && (ckpt.From > 0 || ckpt.SearchAfter != nil) {
+			log.Printf("Retomando do checkpoint %s: %d documentos lidos, %d processados",
+				cfg.Checkpoint, ckpt.From, ckpt.Processed)
+			r.read = ckpt.From
+			r.start = ckpt.From
+			r.processedBefore = ckpt.Processed
+			if cfg.PaginationMode == "search_after" {
+				r.after = ckpt.SearchAfter
+			} else {
+				r.skip = ckpt.From
+				log.Printf("Modo scroll: os primeiros %d documentos serão descartados; a retomada depende de uma ordenação estável", r.skip)
+			}
+		}
 	}
 
-	err = qc.client.CreateCollection(context.Background(), &qdrant.CreateCollection{
-		CollectionName: collectionName,
-		VectorsConfig: qdrant.NewVectorsConfig(&qdrant.VectorParams{
-			Size:     vectorSize,
-			Distance: qdrant.Distance_Cosine,
-		}),
-	})
+	ckptState := newCheckpointState(cfg, ckpt)
 
-	if err != nil {
-		return fmt.Errorf("erro ao criar coleção: %v", err)
+	// Sincronização incremental a partir do limite registrado no checkpoint
+	if cfg.SyncField != "" {
+		esClient.since = ckptState.syncFrom
+		if esClient.since == "" {
+			log.Printf("Sincronização incremental por '%s': primeira execução, todos os documentos serão migrados", cfg.SyncField)
+		} else {
+			log.Printf("Sincronização incremental por '%s' a partir de %s (janela de %s)", cfg.SyncField, esClient.since, cfg.SyncOverlap)
+		}
 	}
 
-	log.Printf("Coleção '%s' criada com sucesso", collectionName)
-	return nil
-}
+	if cfg.MinID != nil || cfg.MaxID != nil {
+		log.Printf("Migrando apenas os documentos com %s", cfg.idRangeDescription())
+	}
 
-func (qc *QdrantClient) upsertDocument(doc DocumentData) error {
-	// Gerar embedding
-	embedding := generateEmbedding(doc.Texto)
-	
-	// Criar payload
-	payload := map[string]interface{}{
-		"texto":  doc.Texto,
+	// Retomada a partir do maior ID já gravado na coleção
+	if cfg.ResumeFromQdrant {
+		maxID, total, ok, err := qdrantClient.maxPointID(ctx)
+		if err != nil {
+			fatalf("Erro ao consultar o maior ID da coleção: %v", err)
+		}
+		if ok {
+			esClient.afterID = &maxID
+			logEvent("resume_from_qdrant", fmt.Sprintf("Coleção '%s' com %d pontos: retomando a partir de %s > %d", cfg.CollectionName, total, cfg.IDField, maxID),
+				"points", total, "max_id", maxID)
+		} else {
+			log.Printf("Coleção '%s' sem pontos com ID numérico: todos os documentos serão migrados", cfg.CollectionName)
+		}
 	}
 
-	// Criar ponto
-	point := &qdrant.PointStruct{
-		Id: qdrant.NewIDNum(doc.ID),
-		Vectors: qdrant.NewVectors(embedding...),
-		Payload: qdrant.NewValueMap(payload),
+	// Total exato da consulta, já com os filtros de sincronização e retomada,
+	// antes da leitura
+	if cfg.CountFirst {
+		total, err := esClient.count(ctx)
+		if err != nil {
+			fatalf("Erro ao contar documentos: %v", err)
+		}
+		r.updateTotal(total)
+		logEvent("documents_counted", fmt.Sprintf("%d documentos a migrar de '%s'", total, cfg.indexName()), "total", total)
 	}
 
-	// Upsert no Qdrant
-	_, err := qc.client.Upsert(context.Background(), &qdrant.UpsertPoints{
-		CollectionName: collectionName,
-		Points:         []*qdrant.PointStruct{point},
-	})
+	// Embeddings e upserts são feitos em paralelo ao longo da leitura
+	errLog := newErrorLog(cfg.ErrorLogLimit)
+	pipe := newPipeline(writeCtx, cfg, embedder, sink, errLog)
 
-	return err
-}
+	var progress *progressBar
+	if cfg.Progress {
+		progress = newProgressBar(os.Stderr, r.processedBefore)
+	}
 
-func main() {
-	log.Println("Iniciando exportação Elasticsearch → Qdrant")
+	r.pipe = pipe
+	r.errLog = errLog
+	r.ckptState = ckptState
+	r.progress = progress
+	// Campos configurados não encontrados nos documentos
+	r.missing = newFieldCounter()
+	// IDs de todos os documentos lidos, para a reconciliação do -prune
+	if cfg.Prune {
+		r.seen = make(map[string]struct{})
+	}
+	// Predicados que descartam documentos antes do embedding
+	if len(cfg.Filters) > 0 {
+		r.filter = newDocFilter(cfg.Filters)
+	}
+	// Hashes dos textos já enviados, para descartar documentos duplicados
+	if cfg.Dedup {
+		r.dedup = newDedupFilter(cfg.DedupHash, cfg.DedupCacheSize)
+	}
 
-	// Inicializar clientes
-	esClient := NewElasticsearchClient()
+	// Relatório JSON final (-report), gravado em todas as formas de encerramento
+	relatorio := func(status string, processados, erros int) {
+		if cfg.Report == "" {
+			return
+		}
+		rep := newRunReport(cfg, status, inicioExportacao, r, sink, processados, erros, cache)
+		rep.EmbeddingRetries = embedRetrier.retryCount()
+		if err := writeReport(cfg.Report, rep); err != nil {
+			logErrorf("Erro no relatório: %v", err)
+		}
+	}
 
-	qdrantClient, err := NewQdrantClient()
-	if err != nil {
-		log.Fatalf("Erro ao conectar com Qdrant: %v", err)
+	// Carga em massa: indexação desativada até o fim da gravação. A
+	// desativação fica logo antes da leitura para que nenhum erro fatal da
+	// preparação deixe a coleção sem indexação.
+	var limiarIndexacao uint64
+	if cfg.BulkLoad && cfg.DryRun {
+		log.Printf("Dry-run: a indexação da coleção '%s' seria desativada durante a carga", cfg.CollectionName)
+	} else if cfg.BulkLoad {
+		if limiarIndexacao, err = qdrantClient.disableIndexing(ctx, cfg.CollectionName); err != nil {
+			fatalf("Erro ao desativar a indexação: %v", err)
+		}
+	}
+	restaurarIndexacao := func() {
+		if limiarIndexacao == 0 {
+			return
+		}
+		if err := qdrantClient.restoreIndexing(writeCtx, cfg.CollectionName, limiarIndexacao); err != nil {
+			logErrorf("Erro ao reativar a indexação: %v (restaure indexing_threshold manualmente)", err)
+		}
+		limiarIndexacao = 0
 	}
-	defer qdrantClient.Close()
 
-	// Criar coleção no Qdrant
-	log.Println("Criando coleção no Qdrant...")
-	if err := qdrantClient.createCollection(); err != nil {
-		log.Fatalf("Erro ao criar coleção: %v", err)
+	if err := r.run(ctx); err != nil {
+		// A indexação só é restaurada depois da gravação dos pendentes
+		pipe.close()
+		restaurarIndexacao()
+		gravados, falhas := pipe.stats()
+		relatorio("failed", r.processedBefore+gravados, r.errors+falhas)
+		fatalf("Muitos erros consecutivos, encerrando: %v", err)
 	}
+	scrollID, after, lidos, erros, interrompido := r.scrollID, r.after, r.read, r.errors, r.interrupted
+	inicio, processadosAntes, total, vistos := r.start, r.processedBefore, r.total, r.seen
 
-	// Processar documentos em lotes
-	from := 0
-	totalProcessados := 0
-	erros := 0
+	if interrompido {
+		if progress != nil {
+			progress.finish()
+		}
+		log.Println("Sinal de interrupção recebido, gravando documentos pendentes...")
+	}
 
-	for {
-		log.Printf("Buscando documentos de %d a %d...", from, from+pageSize)
+	// Aguardar os workers e o envio do último lote parcial
+	pipe.close()
+	restaurarIndexacao()
+	totalProcessados, falhas := pipe.stats()
+	totalProcessados += processadosAntes
+	if progress != nil {
+		progress.update(totalProcessados+falhas+pipe.unchangedCount(), total)
+		progress.finish()
+	}
+	erros += falhas
+	errLog.logSummary()
+	if falhasEmbedding, tentativas := pipe.embedFailedCount(), embedRetrier.retryCount(); falhasEmbedding > 0 || tentativas > 0 {
+		logEvent("failures_by_stage", fmt.Sprintf("Falhas: %d documentos no embedding (após %d novas tentativas), %d na gravação",
+			falhasEmbedding, tentativas, falhas-falhasEmbedding),
+			"embedding_failed", falhasEmbedding, "embedding_retries", tentativas, "upsert_failed", falhas-falhasEmbedding)
+	}
+	logStageTimings(&r.searchTiming, &pipe.embedTiming, &pipe.upsertTiming)
+	r.missing.logSummary()
+	if r.filter != nil {
+		r.filter.logSummary()
+	}
+	if r.dedup != nil {
+		n := r.dedup.skippedCount()
+		logEvent("duplicates_skipped", fmt.Sprintf("%d documentos com texto duplicado ignorados", n), "duplicates_skipped", n)
+	}
+	if cfg.SkipUnchanged {
+		n := pipe.unchangedCount()
+		logEvent("skipped_unchanged", fmt.Sprintf("%d documentos sem alteração ignorados", n), "skipped_unchanged", n)
+	}
+	if r.invalidIDs > 0 {
+		action := "ignorados"
+		if cfg.OnMissingID == "hash" {
+			action = "gravados com ID derivado do _id"
+		}
+		logWarnf("%d documentos sem ID válido em '%s' %s", r.invalidIDs, cfg.idDescription(), action)
+	}
+	if esperas, tempo := pipe.inFlight.stats(); esperas > 0 {
+		logEvent("backpressure_summary", fmt.Sprintf("A leitura aguardou a gravação %d vezes (%s no total) pelo limite de documentos em processamento", esperas, tempo.Round(time.Second)),
+			"waits", esperas, "waited_ms", tempo.Milliseconds())
+	}
+	if pipe.memory != nil && pipe.memory.pauses > 0 {
+		logWarnf("A leitura pausou %d vezes (%s no total) por memória acima de %d MiB", pipe.memory.pauses, pipe.memory.paused.Round(time.Second), cfg.MemoryLimitMB)
+	}
+	if n := pipe.breaker.tripCount(); n > 0 {
+		logWarnf("O circuit breaker pausou a migração %d vezes por excesso de erros", n)
+	}
+	if n := pipe.zeroVectorCount(); n > 0 {
+		logWarnf("%d embeddings nulos gravados sem normalização", n)
+	}
+	if n := sink.skippedDimensions(); n > 0 {
+		logWarnf("%d documentos ignorados por embedding com dimensão diferente de %d", n, cfg.VectorSize)
+	}
 
-		// Buscar documentos no Elasticsearch
-		result, err := esClient.searchDocuments(from)
-		if err != nil {
-			log.Printf("Erro ao buscar documentos: %v", err)
-			erros++
-			if erros >= 5 {
-				log.Fatal("Muitos erros consecutivos, encerrando")
-			}
+	// Liberar os contextos de scroll no Elasticsearch, um por fatia no
+	// scroll fatiado
+	for _, id := range append([]string{scrollID}, r.sliceScrollIDs...) {
+		if id == "" {
 			continue
 		}
-
-		// Se não há mais documentos, encerrar
-		if len(result.Hits.Hits) == 0 {
-			log.Println("Não há mais documentos para processar")
-			break
+		if err := esClient.clearScroll(writeCtx, id); err != nil {
+			logErrorf("Erro ao liberar contexto de scroll: %v", err)
 		}
+	}
+	if err := esClient.closePIT(writeCtx); err != nil {
+		logErrorf("Erro ao fechar point-in-time: %v", err)
+	}
 
-		log.Printf("Total de documentos encontrados: %d", result.Hits.Total.Value)
+	status := "interrupted"
+	if err := pipe.aborted(); err != nil {
+		hint := "use -on-dim-mismatch skip para ignorar esses documentos"
+		var idErr *MissingIDError
+		var rateErr *FailureRateError
+		var notFoundErr *CollectionNotFoundError
+		switch {
+		case errors.As(err, &idErr):
+			hint = "use -on-missing-id skip ou hash para ignorar esses documentos"
+		case errors.As(err, &rateErr):
+			hint = "veja o resumo de erros; para tolerar mais falhas, aumente " + rateErr.Threshold
+		case errors.As(err, &notFoundErr):
+			hint = "crie a coleção, com os índices de payload, antes da migração ou remova -skip-create"
+		}
+		logErrorf("Exportação abortada: %v (%s)", err, hint)
+		interrompido = true
+		status = "aborted"
+	}
 
-		// Processar cada documento
-		sucessos := 0
-		for i, hit := range result.Hits.Hits {
-			doc := extractDocumentData(hit)
+	if interrompido {
+		logSummaryEvent("interrupted", fmt.Sprintf("Exportação interrompida após %d documentos lidos (from=%d): %d processados, %d erros",
+			lidos, lidos, totalProcessados, erros),
+			"read", lidos, "processed", totalProcessados, "errors", erros, "duration_ms", durationMs(inicioExportacao))
+		if cfg.PaginationMode == "search_after" && after != nil {
+			cursor, _ := json.Marshal(after)
+			log.Printf("Último cursor search_after: %s", cursor)
+		}
+		if cfg.Checkpoint != "" && !cfg.DryRun {
+			log.Printf("Progresso salvo em %s; execute novamente para retomar", cfg.Checkpoint)
+		}
+		logCacheStats(cache)
+		relatorio(status, totalProcessados, erros)
+		sink.Close()
+		stopMetrics()
+		os.Exit(1)
+	}
 
-			if err := qdrantClient.upsertDocument(doc); err != nil {
-				log.Printf("Erro ao inserir documento %d: %v", doc.ID, err)
+	// Reconciliação: remover pontos cujos documentos não existem mais. Exige
+	// que todos os documentos tenham sido lidos nesta execução.
+	if cfg.Prune {
+		switch {
+		case cfg.PaginationMode == "search_after" && inicio > 0:
+			log.Println("Reconciliação ignorada: a execução foi retomada do checkpoint e não leu todos os documentos")
+		case len(vistos) == 0:
+			log.Println("Reconciliação ignorada: nenhum documento lido do Elasticsearch")
+		default:
+			log.Printf("Reconciliando '%s' com %d documentos do Elasticsearch...", cfg.CollectionName, len(vistos))
+			removidos, err := qdrantClient.prune(writeCtx, vistos)
+			if err != nil {
+				errLog.record("prune", err)
 				erros++
-			} else {
-				sucessos++
 			}
-
-			// Log de progresso a cada 100 documentos
-			if (i+1)%100 == 0 {
-				log.Printf("Processados %d/%d documentos do lote atual", i+1, len(result.Hits.Hits))
+			if cfg.DryRun {
+				log.Printf("Dry-run: %d pontos seriam removidos", removidos)
+			} else {
+				logEvent("pruned", fmt.Sprintf("Reconciliação concluída: %d pontos removidos", removidos), "removed", removidos)
 			}
 		}
+	}
 
-		totalProcessados += sucessos
-		from += pageSize
-
-		log.Printf("Lote concluído: %d sucessos, %d erros. Total processado: %d",
-			sucessos, erros, totalProcessados)
+	// Exportação concluída sem falhas: o checkpoint é removido ou, na
+	// sincronização incremental, preparado para a próxima execução
+	if falhas == 0 {
+		ckptState.finish()
+	}
 
-		// Pequena pausa entre lotes para não sobrecarregar
-		time.Sleep(10 * time.Millisecond)
+	if cfg.DryRun {
+		log.Printf("Dry-run: %d documentos seriam gravados em '%s'", totalProcessados, cfg.CollectionName)
+		log.Printf("Dry-run: %d vetores de dimensão %d estimados (~%.1f MB em float32)",
+			totalProcessados, cfg.VectorSize, float64(totalProcessados)*float64(cfg.VectorSize)*4/(1<<20))
 	}
 
-	log.Printf("Exportação finalizada!")
-	log.Printf("Total de documentos processados: %d", totalProcessados)
-	log.Printf("Total de erros: %d", erros)
+	logSummaryEvent("finished", fmt.Sprintf("Exportação finalizada: %d documentos processados, %d erros", totalProcessados, erros),
+		"processed", totalProcessados, "errors", erros, "duration_ms", durationMs(inicioExportacao))
+	logCacheStats(cache)
+
+	// Falha da exportação para o CI quando os erros passam de MAX_ERRORS
+	if cfg.MaxErrors >= 0 && erros > cfg.MaxErrors {
+		logErrorf("Exportação com %d erros, acima do limite de %d (MAX_ERRORS)", erros, cfg.MaxErrors)
+		relatorio("failed", totalProcessados, erros)
+		sink.Close()
+		stopMetrics()
+		os.Exit(1)
+	}
+	relatorio("completed", totalProcessados, erros)
 }
 
+// Exibe os acertos e falhas do cache de embeddings, quando habilitado
+func logCacheStats(cache *embeddingCache) {
+	if cache == nil {
+		return
+	}
+	hits, misses := cache.stats()
+	logEvent("cache", fmt.Sprintf("Cache de embeddings: %d acertos, %d falhas", hits, misses),
+		"hits", hits, "misses", misses)
+	fields := cache.fieldStats()
+	for _, field := range slices.Sorted(maps.Keys(fields)) {
+		counts := fields[field]
+		logEvent("cache_field", fmt.Sprintf("Cache de embeddings do campo %s: %d acertos, %d falhas (%.1f%% de acertos)",
+			field, counts.hits, counts.misses, counts.hitRate()),
+			"field", field, "hits", counts.hits, "misses", counts.misses)
+	}
+}
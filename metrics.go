@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Métricas da exportação, expostas em /metrics quando METRICS_ADDR é
+// informado. São atualizadas sempre; sem o servidor apenas não são lidas.
+var (
+	metricsRegistry = prometheus.NewRegistry()
+
+	documentsRead = registerMetric(prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "migration_documents_read_total",
+		Help: "Documentos lidos do Elasticsearch.",
+	}))
+	documentsTotal = registerMetric(prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "migration_documents_expected",
+		Help: "Total de documentos da consulta informado pelo Elasticsearch.",
+	}))
+	documentsProcessed = registerMetric(prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "migration_documents_processed_total",
+		Help: "Documentos gravados no Qdrant.",
+	}))
+	documentsFailed = registerMetric(prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "migration_documents_failed_total",
+		Help: "Documentos que falharam no embedding ou no upsert.",
+	}))
+	errorsTotal = registerMetric(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "migration_errors_total",
+		Help: "Erros por categoria (etapa e causa).",
+	}, []string{"category"}))
+	duplicatesSkipped = registerMetric(prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "migration_duplicates_skipped_total",
+		Help: "Documentos com texto repetido descartados pelo -dedup.",
+	}))
+	documentsFiltered = registerMetric(prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "migration_documents_filtered_total",
+		Help: "Documentos descartados pelos predicados de FILTERS.",
+	}))
+	documentsInvalidID = registerMetric(prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "migration_documents_invalid_id_total",
+		Help: "Documentos sem ID válido em ID_FIELD, ignorados ou gravados com ID derivado do _id.",
+	}))
+	documentsUnchanged = registerMetric(prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "migration_documents_unchanged_total",
+		Help: "Documentos com content_hash igual ao gravado, ignorados pelo -skip-unchanged.",
+	}))
+	batchesFlushed = registerMetric(prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "migration_batches_flushed_total",
+		Help: "Lotes de upsert gravados no Qdrant.",
+	}))
+	breakerOpen = registerMetric(prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "migration_circuit_breaker_open",
+		Help: "1 enquanto o circuit breaker pausa a migração por excesso de erros.",
+	}))
+	breakerTrips = registerMetric(prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "migration_circuit_breaker_trips_total",
+		Help: "Vezes em que o circuit breaker abriu.",
+	}))
+	documentsInFlight = registerMetric(prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "migration_documents_in_flight",
+		Help: "Documentos em processamento no pipeline, com MAX_IN_FLIGHT.",
+	}))
+	backpressureWaits = registerMetric(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "migration_backpressure_waits_total",
+		Help: "Pausas da leitura por contrapressão, por motivo (in_flight ou memory).",
+	}, []string{"reason"}))
+	embeddingRetries = registerMetric(prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "migration_embedding_retries_total",
+		Help: "Novas tentativas de requisições de embeddings após falhas transitórias.",
+	}))
+	embeddingDuration = registerMetric(prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "migration_embedding_duration_seconds",
+		Help:    "Duração da geração de embeddings por lote.",
+		Buckets: prometheus.ExponentialBuckets(0.05, 2, 10),
+	}))
+	upsertDuration = registerMetric(prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "migration_upsert_duration_seconds",
+		Help:    "Duração dos upserts no Qdrant por lote.",
+		Buckets: prometheus.ExponentialBuckets(0.01, 2, 12),
+	}))
+)
+
+func init() {
+	metricsRegistry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Registra a métrica em metricsRegistry
+func registerMetric[T prometheus.Collector](c T) T {
+	metricsRegistry.MustRegister(c)
+	return c
+}
+
+// Inicia o servidor de métricas em addr e retorna a função que o encerra,
+// aguardando as requisições em andamento
+func startMetricsServer(addr string) func() {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{}))
+	srv := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logErrorf("Erro no servidor de métricas: %v", err)
+		}
+	}()
+	log.Printf("Métricas disponíveis em http://%s/metrics", addr)
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(ctx); err != nil {
+			logErrorf("Erro ao encerrar servidor de métricas: %v", err)
+		}
+	}
+}
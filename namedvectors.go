@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"strings"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// Vetores nomeados (NAMED_VECTORS): a coleção é criada com vários vetores
+// densos, cada um com a sua dimensão e distância, como um vetor do título e
+// outro do corpo em buscas híbridas. Um dos vetores, sem "field", recebe o
+// embedding gerado do documento (ou lido de EMBEDDING_FIELD); os demais são
+// lidos de campos dense_vector do _source ou, com "embed_field", gerados pelo
+// embedder a partir do texto de um campo, como em coleções com um vetor por
+// campo que muda independentemente dos outros. Um vetor lido ausente ou com
+// outra dimensão, ou um campo de embed_field sem texto, não é gravado e o
+// campo conta como ausente.
+
+// Vetor nomeado da coleção
+type namedVector struct {
+	Name     string `json:"name"`
+	Size     int    `json:"size"`     // 0 usa VECTOR_SIZE no vetor do embedding
+	Distance string `json:"distance"` // vazio usa DISTANCE
+	Field    string `json:"field"`    // campo do _source; sem ele e EmbedField, recebe o embedding
+	// Campo do _source cujo texto gera o embedding do vetor, no lugar de Field
+	EmbedField string `json:"embed_field"`
+}
+
+// Indica se o vetor recebe o embedding do documento
+func (v namedVector) receivesEmbedding() bool {
+	return v.Field == "" && v.EmbedField == ""
+}
+
+// Interpreta a lista JSON de NAMED_VECTORS; vazio mantém o vetor sem nome
+func parseNamedVectors(v string) ([]namedVector, error) {
+	if strings.TrimSpace(v) == "" {
+		return nil, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader([]byte(v)))
+	dec.DisallowUnknownFields()
+	var vectors []namedVector
+	if err := dec.Decode(&vectors); err != nil {
+		return nil, fmt.Errorf("esperado um array JSON de {name, size, distance, field, embed_field}: %v", err)
+	}
+	return vectors, nil
+}
+
+// Vetores lidos dos campos dense_vector do _source, pelo nome do vetor; os
+// campos ausentes ou com outra dimensão são retornados em missing
+func namedVectorValues(source map[string]interface{}, cfg *Config) (map[string][]float32, []string) {
+	var values map[string][]float32
+	var missing []string
+	for _, spec := range cfg.NamedVectors {
+		if spec.Field == "" {
+			continue
+		}
+		v, _ := lookupField(source, spec.Field)
+		vector, ok := vectorValue(v)
+		if !ok || len(vector) != spec.Size {
+			missing = append(missing, spec.Field)
+			continue
+		}
+		if values == nil {
+			values = make(map[string][]float32, len(cfg.NamedVectors))
+		}
+		values[spec.Name] = vector
+	}
+	return values, missing
+}
+
+// Textos dos campos de embed_field, pelo nome do vetor; os campos sem texto
+// são retornados em missing
+func embedFieldTexts(source map[string]interface{}, cfg *Config) (map[string]string, []string) {
+	var texts map[string]string
+	var missing []string
+	for _, spec := range cfg.NamedVectors {
+		if spec.EmbedField == "" {
+			continue
+		}
+		v, _ := lookupField(source, spec.EmbedField)
+		text := strings.TrimSpace(textValue(normalizeJSON(v)))
+		if text == "" {
+			missing = append(missing, spec.EmbedField)
+			continue
+		}
+		if texts == nil {
+			texts = make(map[string]string, len(cfg.NamedVectors))
+		}
+		texts[spec.Name] = text
+	}
+	return texts, missing
+}
+
+// Gera os vetores de cada campo de embed_field em lotes de EmbedBatchSize
+// textos. Com o cache, cada texto é procurado pela chave do próprio campo:
+// um campo sem alteração reaproveita o vetor, mesmo que os outros mudem.
+func embedFieldVectors(ctx context.Context, embedder Embedder, docs []DocumentData, cfg *Config) error {
+	for _, spec := range cfg.NamedVectors {
+		if spec.EmbedField == "" {
+			continue
+		}
+		fieldEmbedder := embedder
+		if cached, ok := embedder.(*cachedEmbedder); ok {
+			fieldEmbedder = cached.forField(spec.EmbedField)
+		}
+
+		var pending []int
+		for i, doc := range docs {
+			if doc.FieldTexts[spec.Name] != "" {
+				pending = append(pending, i)
+			}
+		}
+		for start := 0; start < len(pending); start += cfg.EmbedBatchSize {
+			batch := pending[start:min(start+cfg.EmbedBatchSize, len(pending))]
+			texts := make([]string, len(batch))
+			for j, i := range batch {
+				texts[j] = docs[i].FieldTexts[spec.Name]
+			}
+
+			embeddings, err := fieldEmbedder.Embed(ctx, texts)
+			if err != nil {
+				return fmt.Errorf("vetor '%s' (campo %s): %w", spec.Name, spec.EmbedField, err)
+			}
+			if len(embeddings) != len(batch) {
+				return fmt.Errorf("vetor '%s': esperados %d embeddings, recebidos %d", spec.Name, len(batch), len(embeddings))
+			}
+
+			// Os trechos de LONG_TEXT=chunk compartilham o mapa do documento
+			for j, i := range batch {
+				vectors := make(map[string][]float32, len(docs[i].NamedVectors)+1)
+				maps.Copy(vectors, docs[i].NamedVectors)
+				vectors[spec.Name] = embeddings[j]
+				docs[i].NamedVectors = vectors
+			}
+		}
+	}
+	return nil
+}
+
+// Vetores densos do ponto pelo nome, sem os ausentes no documento
+func (d DocumentData) denseVectors(cfg *Config) map[string][]float32 {
+	vectors := make(map[string][]float32, len(cfg.NamedVectors))
+	for _, spec := range cfg.NamedVectors {
+		vector := d.NamedVectors[spec.Name]
+		if spec.receivesEmbedding() {
+			vector = d.Vector
+		}
+		if len(vector) > 0 {
+			vectors[spec.Name] = vector
+		}
+	}
+	return vectors
+}
+
+// Configuração dos vetores densos da coleção: o vetor sem nome ou, com
+// NamedVectors, um por nome
+func (qc *QdrantClient) vectorsConfig() *qdrant.VectorsConfig {
+	if len(qc.cfg.NamedVectors) == 0 {
+		return qdrant.NewVectorsConfig(qc.vectorParams(qc.cfg.VectorSize, qc.cfg.distance()))
+	}
+	params := make(map[string]*qdrant.VectorParams, len(qc.cfg.NamedVectors))
+	for _, spec := range qc.cfg.NamedVectors {
+		params[spec.Name] = qc.vectorParams(spec.Size, distances[spec.Distance])
+	}
+	return qdrant.NewVectorsConfigMap(params)
+}
+
+func (qc *QdrantClient) vectorParams(size int, distance qdrant.Distance) *qdrant.VectorParams {
+	return &qdrant.VectorParams{
+		Size:     uint64(size),
+		Distance: distance,
+		OnDisk:   optionalBool(qc.cfg.OnDiskVectors),
+		Datatype: qc.cfg.vectorDatatype(),
+	}
+}
+
+// Confere se a coleção existente tem cada vetor nomeado com a dimensão e a
+// distância configuradas
+func (qc *QdrantClient) checkNamedVectors(name string, config *qdrant.VectorsConfig) error {
+	existing := config.GetParamsMap().GetMap()
+	if existing == nil {
+		return fmt.Errorf("a coleção '%s' já existe com um vetor sem nome, e NAMED_VECTORS grava vetores nomeados (use -recreate ou outra COLLECTION_NAME)", name)
+	}
+	for _, spec := range qc.cfg.NamedVectors {
+		params, ok := existing[spec.Name]
+		switch {
+		case !ok:
+			return fmt.Errorf("a coleção '%s' já existe sem o vetor '%s' de NAMED_VECTORS; use -recreate para recriá-la", name, spec.Name)
+		case params.GetSize() != uint64(spec.Size):
+			return fmt.Errorf("o vetor '%s' da coleção '%s' tem dimensão %d, diferente da de NAMED_VECTORS (%d); use -recreate para recriá-la",
+				spec.Name, name, params.GetSize(), spec.Size)
+		case params.GetDistance() != distances[spec.Distance]:
+			return fmt.Errorf("o vetor '%s' da coleção '%s' usa a distância %s, diferente da de NAMED_VECTORS (%s); use -recreate para recriá-la",
+				spec.Name, name, strings.ToLower(params.GetDistance().String()), spec.Distance)
+		}
+	}
+	return nil
+}
+
+// Vetores nomeados, para os logs do dry-run
+func (c *Config) namedVectorsDescription() string {
+	parts := make([]string, 0, len(c.NamedVectors))
+	for _, spec := range c.NamedVectors {
+		source := "embedding"
+		switch {
+		case spec.Field != "":
+			source = "campo " + spec.Field
+		case spec.EmbedField != "":
+			source = "embedding do campo " + spec.EmbedField
+		}
+		parts = append(parts, fmt.Sprintf("'%s' (dimensão %d, distância %s, %s)", spec.Name, spec.Size, spec.Distance, source))
+	}
+	return strings.Join(parts, ", ")
+}
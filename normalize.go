@@ -0,0 +1,53 @@
+package main
+
+import "math"
+
+// Normalização L2 (-normalize): divide cada componente pela norma do vetor,
+// de modo que todos tenham norma 1. Com a distância cosine o Qdrant já
+// normaliza internamente, mas vetores normalizados tornam dot e euclid
+// equivalentes e uniformizam coleções com embeddings de origens diferentes.
+
+// Retorna o vetor normalizado (um novo slice, sem alterar o original, que pode
+// vir do cache de embeddings) e false para o vetor nulo, devolvido sem
+// alteração
+func normalizeVector(v []float32) ([]float32, bool) {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return v, false
+	}
+
+	norm := math.Sqrt(sum)
+	out := make([]float32, len(v))
+	for i, x := range v {
+		out[i] = float32(float64(x) / norm)
+	}
+	return out, true
+}
+
+// Normaliza os vetores dos documentos e conta os vetores nulos
+func (p *pipeline) normalize(docs []DocumentData) {
+	zero := 0
+	for i := range docs {
+		var ok bool
+		if docs[i].Vector, ok = normalizeVector(docs[i].Vector); !ok {
+			zero++
+		}
+	}
+	if zero == 0 {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.zeroVectors += zero
+}
+
+// Vetores nulos mantidos sem normalização
+func (p *pipeline) zeroVectorCount() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.zeroVectors
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// Sincronização apenas do payload (-payload-only): os vetores já gravados são
+// mantidos e só o payload dos pontos é atualizado, sem gerar embeddings. Cada
+// lote vira uma única requisição UpdateBatch com uma operação por ponto,
+// SetPayload (mescla com o payload existente) ou, com PayloadOverwrite,
+// OverwritePayload (substitui o payload inteiro). Os pontos precisam já
+// existir na coleção: o Qdrant rejeita o lote com um ID ausente.
+
+// Grava os pontos no Qdrant: upsert completo ou, com PayloadOnly, apenas o
+// payload dos pontos existentes. shardKey vazio não seleciona shards.
+func (qc *QdrantClient) writePoints(ctx context.Context, collection, shardKey string, points []*qdrant.PointStruct) error {
+	if qc.cfg.PayloadOnly {
+		return qc.setPayloads(ctx, collection, shardKey, points)
+	}
+	return qc.upsertPoints(ctx, collection, shardKey, points)
+}
+
+// Atualiza o payload dos pontos pelo ID, ignorando os vetores
+func (qc *QdrantClient) setPayloads(ctx context.Context, collection, shardKey string, points []*qdrant.PointStruct) error {
+	operations := make([]*qdrant.PointsUpdateOperation, 0, len(points))
+	for _, point := range points {
+		selector := qdrant.NewPointsSelector(point.GetId())
+		if qc.cfg.PayloadOverwrite {
+			operations = append(operations, qdrant.NewPointsUpdateOverwritePayload(&qdrant.PointsUpdateOperation_OverwritePayload{
+				Payload:          point.GetPayload(),
+				PointsSelector:   selector,
+				ShardKeySelector: shardKeySelector(shardKey),
+			}))
+		} else {
+			operations = append(operations, qdrant.NewPointsUpdateSetPayload(&qdrant.PointsUpdateOperation_SetPayload{
+				Payload:          point.GetPayload(),
+				PointsSelector:   selector,
+				ShardKeySelector: shardKeySelector(shardKey),
+			}))
+		}
+	}
+
+	return qc.do(ctx, func(ctx context.Context) error {
+		_, err := qc.client.UpdateBatch(ctx, &qdrant.UpdateBatchPoints{
+			CollectionName: collection,
+			Wait:           qdrant.PtrOf(qc.cfg.Wait),
+			Operations:     operations,
+		})
+		return err
+	})
+}
+
+// Verifica se a coleção existe; com PayloadOnly ela não é criada, já que os
+// pontos precisam estar gravados
+func (qc *QdrantClient) requireCollection(ctx context.Context, name string) error {
+	var exists bool
+	err := qc.do(ctx, func(ctx context.Context) error {
+		var err error
+		exists, err = qc.client.CollectionExists(ctx, name)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("erro ao verificar se coleção existe: %v", err)
+	}
+	if !exists {
+		return fmt.Errorf("coleção '%s' não encontrada no Qdrant; -payload-only só atualiza pontos existentes", name)
+	}
+
+	log.Printf("Coleção '%s' encontrada; apenas o payload dos pontos será atualizado", name)
+	return nil
+}
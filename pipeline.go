@@ -0,0 +1,418 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Pipeline de processamento: os documentos lidos do Elasticsearch são
+// divididos em lotes de embedding consumidos por um pool de workers, e os
+// documentos com vetor são agrupados em lotes de upsert por um único coletor.
+// Os canais são limitados, então submit bloqueia quando o Qdrant ou o
+// embedder ficam para trás, em vez de acumular documentos em memória.
+type pipeline struct {
+	ctx      context.Context
+	cfg      *Config
+	embedder Embedder
+	store    VectorStore
+	errLog   *errorLog
+	// Consulta dos hashes já gravados, com SkipUnchanged
+	hashes HashLookup
+	// Pausa entre as páginas lidas, ajustada pela latência dos upserts
+	throttle *adaptiveThrottle
+	// Pausa a migração quando a taxa de erros dispara (BREAKER_WINDOW); nil
+	// se desativado
+	breaker *circuitBreaker
+	// Limite de documentos em processamento (MAX_IN_FLIGHT) e pausa da
+	// leitura por memória (MEMORY_LIMIT_MB); nil se desativados
+	inFlight *inFlightLimiter
+	memory   *memoryWatchdog
+
+	batches  chan workItem
+	embedded chan workItem
+	workers  sync.WaitGroup
+	done     chan struct{}
+
+	mu      sync.Mutex
+	written int
+	failed  int
+	// Parte de failed que falhou na geração dos embeddings, para separar
+	// problemas do embedder dos de gravação no resumo
+	embedFailed int
+	flushed     int // lotes de upsert gravados
+	// Documentos sem alteração desde a última gravação (-skip-unchanged)
+	unchanged int
+	// Vetores nulos, que o -normalize mantém sem alteração
+	zeroVectors int
+	// Duração dos lotes de embeddings e de upsert, para o resumo por etapa
+	embedTiming  stageTiming
+	upsertTiming stageTiming
+	// Erro que encerra a exportação (dimensão divergente com OnDimMismatch
+	// "fail", documento sem ID com OnMissingID "fail" ou falhas acima dos
+	// limites de MaxBatchFailureRate e MaxFailureRate)
+	abortErr error
+
+	// Páginas enviadas e ainda não finalizadas, para notificar em ordem as
+	// que foram completamente gravadas
+	pages    map[int]*pageState
+	lastPage int
+	nextPage int
+	stalled  bool
+}
+
+// Lote de documentos de uma mesma página
+type workItem struct {
+	page int
+	docs []DocumentData
+}
+
+type pageState struct {
+	remaining int
+	failed    bool
+	onCommit  func()
+}
+
+func newPipeline(ctx context.Context, cfg *Config, embedder Embedder, store VectorStore, errLog *errorLog) *pipeline {
+	p := &pipeline{
+		ctx:      ctx,
+		cfg:      cfg,
+		embedder: embedder,
+		store:    store,
+		errLog:   errLog,
+		throttle: newAdaptiveThrottle(cfg.ThrottleMin, cfg.ThrottleMax),
+		breaker:  newCircuitBreaker(cfg.BreakerWindow, cfg.BreakerThreshold, cfg.BreakerCooldown),
+		inFlight: newInFlightLimiter(cfg.MaxInFlight),
+		memory:   newMemoryWatchdog(cfg.MemoryLimitMB),
+		batches:  make(chan workItem, cfg.Workers),
+		embedded: make(chan workItem, cfg.Workers),
+		done:     make(chan struct{}),
+		pages:    make(map[int]*pageState),
+		nextPage: 1,
+	}
+	if cfg.SkipUnchanged {
+		p.hashes, _ = store.(HashLookup)
+	}
+
+	for i := 0; i < cfg.Workers; i++ {
+		p.workers.Add(1)
+		go p.embedWorker()
+	}
+	go p.collect()
+
+	return p
+}
+
+// Envia os documentos de uma página para o pool, em lotes de EmbedBatchSize.
+// onCommit (opcional) é chamado quando esta página e todas as anteriores foram
+// gravadas; depois da primeira página com falha ele não é mais chamado, para
+// que a posição registrada nunca avance sobre documentos não gravados.
+func (p *pipeline) submit(docs []DocumentData, onCommit func()) {
+	if len(docs) == 0 {
+		return
+	}
+
+	p.mu.Lock()
+	p.lastPage++
+	page := p.lastPage
+	p.pages[page] = &pageState{remaining: len(docs), onCommit: onCommit}
+	p.mu.Unlock()
+
+	for start := 0; start < len(docs); start += p.cfg.EmbedBatchSize {
+		end := min(start+p.cfg.EmbedBatchSize, len(docs))
+		p.inFlight.acquire(end - start)
+		p.batches <- workItem{page: page, docs: docs[start:end]}
+	}
+}
+
+// Aguarda o processamento de todos os documentos enviados, incluindo o
+// último lote parcial de upsert
+func (p *pipeline) close() {
+	close(p.batches)
+	p.workers.Wait()
+	close(p.embedded)
+	<-p.done
+}
+
+// Encerra a exportação com err; vale o primeiro erro registrado
+func (p *pipeline) abort(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.abortErr == nil {
+		p.abortErr = err
+	}
+}
+
+// Erro que exige encerrar a exportação, ou nil
+func (p *pipeline) aborted() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.abortErr
+}
+
+// Lotes de upsert gravados até o momento
+func (p *pipeline) flushedBatches() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.flushed
+}
+
+// Documentos ignorados por não terem mudado desde a última gravação
+func (p *pipeline) unchangedCount() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.unchanged
+}
+
+// Documentos enviados e ainda não gravados nem com falha
+func (p *pipeline) pendingDocs() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n := 0
+	for _, st := range p.pages {
+		n += st.remaining
+	}
+	return n
+}
+
+// Documentos com falha na geração dos embeddings
+func (p *pipeline) embedFailedCount() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.embedFailed
+}
+
+// Documentos gravados e com falha até o momento
+func (p *pipeline) stats() (written, failed int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.written, p.failed
+}
+
+func (p *pipeline) embedWorker() {
+	defer p.workers.Done()
+
+	// Em dry-run os embeddings só são gerados quando pedido explicitamente,
+	// para não gerar custo com a API; com PayloadOnly nunca, e com
+	// EmbeddingField os vetores já vêm do Elasticsearch
+	skipEmbed := !p.cfg.generatesEmbeddings()
+
+	for item := range p.batches {
+		if p.hashes != nil {
+			if item = p.skipUnchanged(item); len(item.docs) == 0 {
+				continue
+			}
+		}
+		if skipEmbed {
+			if p.cfg.Normalize && p.cfg.EmbeddingField != "" {
+				p.normalize(item.docs)
+			}
+			p.embedded <- item
+			continue
+		}
+		p.breaker.wait(p.ctx)
+		start := time.Now()
+		err := embedDocuments(p.ctx, p.embedder, item.docs, p.cfg)
+		elapsed := time.Since(start)
+		embeddingDuration.Observe(elapsed.Seconds())
+		p.embedTiming.observe(elapsed)
+		logDebugEvent("batch_embedded", fmt.Sprintf("Embeddings de %d documentos em %s", len(item.docs), elapsed.Round(time.Millisecond)),
+			"page", item.page, "documents", len(item.docs), "duration_ms", elapsed.Milliseconds())
+		p.breaker.record(err)
+		if err != nil {
+			p.fail("embedding", pagesOf(item), fmt.Errorf("erro ao gerar embeddings: %w", err))
+			continue
+		}
+		if p.cfg.Normalize {
+			p.normalize(item.docs)
+		}
+		p.embedded <- item
+	}
+}
+
+func (p *pipeline) collect() {
+	defer close(p.done)
+
+	// pages[i] é a página de origem de pending[i]
+	var pending []DocumentData
+	var pages []int
+
+	// Com FlushInterval, o lote parcial é gravado quando o documento mais
+	// antigo espera esse tempo, para que leituras lentas não retenham
+	// documentos sem gravar; oldest é a chegada do primeiro de pending
+	var tick <-chan time.Time
+	if p.cfg.FlushInterval > 0 {
+		ticker := time.NewTicker(p.cfg.FlushInterval / 2)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	var oldest time.Time
+
+	for {
+		select {
+		case item, ok := <-p.embedded:
+			if !ok {
+				if len(pending) > 0 {
+					logDebugf("Enviando lote final com %d documentos...", len(pending))
+					p.upsert(pending, pages)
+				}
+				return
+			}
+			if len(pending) == 0 {
+				oldest = time.Now()
+			}
+			pending = append(pending, item.docs...)
+			pages = append(pages, pagesOf(item)...)
+
+			for len(pending) >= p.cfg.UpsertBatchSize {
+				n := p.cfg.UpsertBatchSize
+				p.upsert(pending[:n], pages[:n])
+				pending = append([]DocumentData(nil), pending[n:]...)
+				pages = append([]int(nil), pages[n:]...)
+				oldest = time.Now()
+			}
+		case <-tick:
+			if len(pending) == 0 || time.Since(oldest) < p.cfg.FlushInterval {
+				continue
+			}
+			logDebugf("Enviando lote parcial com %d documentos após %s sem completar...", len(pending), p.cfg.FlushInterval)
+			p.upsert(pending, pages)
+			pending, pages = nil, nil
+		}
+	}
+}
+
+// Grava um lote de no máximo UpsertBatchSize documentos
+func (p *pipeline) upsert(docs []DocumentData, pages []int) {
+	p.breaker.wait(p.ctx)
+	start := time.Now()
+	written, err := p.store.upsertDocuments(p.ctx, docs)
+	elapsed := time.Since(start)
+	upsertDuration.Observe(elapsed.Seconds())
+	p.throttle.observe(elapsed)
+	p.upsertTiming.observe(elapsed)
+	logDebugEvent("batch_upserted", fmt.Sprintf("Lote de %d documentos gravado em %s (%d gravados)", len(docs), elapsed.Round(time.Millisecond), written),
+		"documents", len(docs), "written", written, "duration_ms", elapsed.Milliseconds())
+	// Lotes gravados em parte (ISOLATE_FAILURES) indicam pontos inválidos,
+	// não um backend com problemas
+	if written == 0 {
+		p.breaker.record(err)
+	} else {
+		p.breaker.record(nil)
+	}
+	var dimErr *DimensionError
+	var notFoundErr *CollectionNotFoundError
+	if errors.As(err, &dimErr) || errors.As(err, &notFoundErr) {
+		p.abort(err)
+	}
+	if err != nil && written > 0 {
+		// Lote com falhas parciais (ISOLATE_FAILURES): os pontos gravados
+		// contam como processados e apenas os rejeitados como falhas
+		p.failPartial(pages, written, fmt.Errorf("erro ao inserir documentos: %w", err))
+		return
+	}
+	if err != nil {
+		p.fail("upsert", pages, fmt.Errorf("erro ao inserir documentos: %w", err))
+		return
+	}
+	batchesFlushed.Inc()
+	documentsProcessed.Add(float64(written))
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.written += written
+	p.flushed++
+	p.complete(pages, false)
+}
+
+// Registra a falha, na etapa stage, dos documentos das páginas indicadas
+func (p *pipeline) fail(stage string, pages []int, err error) {
+	p.errLog.record(stage, err, "documents", len(pages))
+	documentsFailed.Add(float64(len(pages)))
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.failed += len(pages)
+	if stage == "embedding" {
+		p.embedFailed += len(pages)
+	}
+	p.checkFailureRates(len(pages), len(pages))
+	p.complete(pages, true)
+}
+
+// Registra a falha de um upsert que gravou parte dos documentos das páginas.
+// As páginas contam como falhas para o checkpoint, que não avança além delas.
+func (p *pipeline) failPartial(pages []int, written int, err error) {
+	failed := len(pages) - written
+	p.errLog.record("upsert", err, "documents", failed)
+	documentsFailed.Add(float64(failed))
+	documentsProcessed.Add(float64(written))
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.written += written
+	p.failed += failed
+	p.checkFailureRates(failed, len(pages))
+	p.complete(pages, true)
+}
+
+// Documentos finalizados a partir dos quais MaxFailureRate é conferido, para
+// que as falhas dos primeiros lotes não abortem a exportação sozinhas
+const minFailureRateDocs = 1000
+
+// Confere os limites de falhas após um lote com failed falhas em size
+// documentos; o primeiro limite excedido aborta a exportação. Poucos
+// documentos inválidos ficam abaixo dos limites, enquanto uma falha
+// sistemática, como um backend fora do ar, os excede. Deve ser chamado com
+// p.mu travado.
+func (p *pipeline) checkFailureRates(failed, size int) {
+	if p.abortErr != nil {
+		return
+	}
+	if limit := p.cfg.MaxBatchFailureRate; limit > 0 && failureRate(failed, size) > limit {
+		p.abortErr = &FailureRateError{Threshold: "MAX_BATCH_FAILURE_RATE", Failed: failed, Total: size, Limit: limit}
+		return
+	}
+	total := p.written + p.failed
+	if limit := p.cfg.MaxFailureRate; limit > 0 && total >= minFailureRateDocs && failureRate(p.failed, total) > limit {
+		p.abortErr = &FailureRateError{Threshold: "MAX_FAILURE_RATE", Failed: p.failed, Total: total, Limit: limit}
+	}
+}
+
+// Marca os documentos como finalizados e notifica, em ordem, as páginas
+// concluídas. Deve ser chamado com p.mu travado.
+func (p *pipeline) complete(pages []int, failed bool) {
+	p.inFlight.release(len(pages))
+	for _, page := range pages {
+		st := p.pages[page]
+		st.remaining--
+		st.failed = st.failed || failed
+	}
+
+	for {
+		st, ok := p.pages[p.nextPage]
+		if !ok || st.remaining > 0 {
+			return
+		}
+		if st.failed && !p.stalled {
+			p.stalled = true
+			logWarnf("A página %d teve falhas; o checkpoint não avançará nesta execução", p.nextPage)
+		}
+		if !p.stalled && st.onCommit != nil {
+			st.onCommit()
+		}
+		delete(p.pages, p.nextPage)
+		p.nextPage++
+	}
+}
+
+// Página de origem de cada documento do lote
+func pagesOf(item workItem) []int {
+	pages := make([]int, len(item.docs))
+	for i := range pages {
+		pages[i] = item.page
+	}
+	return pages
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Valida a configuração contra os dois backends antes de iniciar a
+// migração: Elasticsearch acessível e com o índice, Qdrant no ar (exceto com
+// -output, em que store é nil) e o embedder gerando vetores com a dimensão de
+// VECTOR_SIZE. Retorna o primeiro problema encontrado, com a indicação do que
+// ajustar.
+func preflight(ctx context.Context, cfg *Config, es *ElasticsearchClient, embedder Embedder, store *QdrantClient) error {
+	log.Println("Verificando Elasticsearch, Qdrant e embedder...")
+
+	if err := es.ping(ctx); err != nil {
+		return err
+	}
+	if err := es.checkIndex(ctx); err != nil {
+		return err
+	}
+	if cfg.MinID != nil || cfg.MaxID != nil {
+		if err := es.checkNumericField(ctx, cfg.IDField); err != nil {
+			return err
+		}
+	}
+	if cfg.ScrollSlices > 1 {
+		if err := es.checkScrollSlices(ctx); err != nil {
+			return err
+		}
+	}
+
+	if store != nil {
+		qdrantCtx, cancel := context.WithTimeout(ctx, cfg.QdrantTimeout)
+		defer cancel()
+		if _, err := store.client.HealthCheck(qdrantCtx); err != nil {
+			return fmt.Errorf("Qdrant inacessível em %s:%d (verifique QDRANT_URL, QDRANT_HOST, QDRANT_PORT, QDRANT_TLS e QDRANT_API_KEY): %v",
+				cfg.QdrantHost, cfg.QdrantPort, err)
+		}
+	}
+
+	// Em dry-run sem embeddings e com -payload-only a API do embedder não é
+	// chamada
+	if cfg.generatesEmbeddings() {
+		if err := checkEmbedder(ctx, cfg, embedder); err != nil {
+			return err
+		}
+	}
+
+	log.Println("Verificação concluída")
+	return nil
+}
+
+// Gera um embedding de teste e compara a dimensão com VECTOR_SIZE
+func checkEmbedder(ctx context.Context, cfg *Config, embedder Embedder) error {
+	vectors, err := embedder.Embed(ctx, []string{"preflight"})
+	if err != nil {
+		hint := "OPENAI_API_KEY e OPENAI_MODEL"
+		if cfg.Embedder == "http" {
+			hint = "EMBED_URL e EMBED_HEADERS"
+		}
+		return fmt.Errorf("embedder indisponível (verifique %s): %v", hint, err)
+	}
+	if len(vectors) != 1 || len(vectors[0]) != cfg.VectorSize {
+		got := 0
+		if len(vectors) > 0 {
+			got = len(vectors[0])
+		}
+		return fmt.Errorf("o embedder gera vetores de dimensão %d, mas VECTOR_SIZE é %d; ajuste VECTOR_SIZE ou o modelo", got, cfg.VectorSize)
+	}
+	return nil
+}
+
+// Verifica se o cluster responde e aceita as credenciais
+func (ec *ElasticsearchClient) ping(ctx context.Context) error {
+	status, body, err := ec.request(ctx, "GET", ec.cfg.esBaseURL()+"/")
+	if err != nil {
+		return fmt.Errorf("Elasticsearch inacessível em %s (verifique ES_URL e o certificado TLS): %v", ec.cfg.esBaseURL(), err)
+	}
+
+	switch status {
+	case http.StatusOK:
+		return nil
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return fmt.Errorf("Elasticsearch recusou as credenciais (HTTP %d); verifique ES_AUTH_MODE e as credenciais configuradas", status)
+	default:
+		return fmt.Errorf("Elasticsearch respondeu HTTP %d: %s", status, body)
+	}
+}
+
+// Verifica se o índice de ES_URL existe. Buscas em todos os índices e em
+// clusters remotos (cluster:indice) não são verificadas.
+func (ec *ElasticsearchClient) checkIndex(ctx context.Context) error {
+	index := ec.cfg.indexName()
+	if index == "" || strings.Contains(index, ":") {
+		return nil
+	}
+
+	status, body, err := ec.request(ctx, "HEAD", ec.cfg.indexURL(""))
+	if err != nil {
+		return fmt.Errorf("erro ao verificar índice %q: %v", index, err)
+	}
+
+	switch status {
+	case http.StatusOK:
+		return nil
+	case http.StatusNotFound:
+		return fmt.Errorf("índice %q não encontrado no Elasticsearch; verifique o caminho de ES_URL", index)
+	default:
+		return fmt.Errorf("erro ao verificar índice %q: HTTP %d %s", index, status, body)
+	}
+}
+
+// Compara SCROLL_SLICES com a quantidade de shards dos índices de ES_URL. O
+// Elasticsearch recomenda no máximo uma fatia por shard: acima disso, cada
+// shard filtra os documentos das suas fatias e as primeiras buscas ficam
+// lentas, então o excesso gera apenas um aviso. Buscas em todos os índices e
+// em clusters remotos não são verificadas.
+func (ec *ElasticsearchClient) checkScrollSlices(ctx context.Context) error {
+	index := ec.cfg.indexName()
+	if index == "" || strings.Contains(index, ":") {
+		return nil
+	}
+
+	status, body, err := ec.request(ctx, "GET", ec.cfg.indexURL("/_search_shards"))
+	if err != nil {
+		return fmt.Errorf("erro ao consultar os shards de %q: %v", index, err)
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("erro ao consultar os shards de %q: HTTP %d %s", index, status, body)
+	}
+
+	// {"shards": [[{"index": "docs", "shard": 0, "primary": true}, ...], ...]},
+	// um grupo de cópias por shard
+	var response struct {
+		Shards [][]json.RawMessage `json:"shards"`
+	}
+	if err := json.Unmarshal([]byte(body), &response); err != nil {
+		return fmt.Errorf("erro ao decodificar os shards de %q: %v", index, err)
+	}
+
+	shards := len(response.Shards)
+	if ec.cfg.ScrollSlices > shards {
+		logWarnf("SCROLL_SLICES=%d é maior que os %d shards de %q; o Elasticsearch recomenda no máximo uma fatia por shard, e as fatias excedentes deixam as primeiras buscas mais lentas",
+			ec.cfg.ScrollSlices, shards, index)
+		return nil
+	}
+	log.Printf("Scroll fatiado: %d fatias em %d shards de %q", ec.cfg.ScrollSlices, shards, index)
+	return nil
+}
+
+// Tipos numéricos inteiros do Elasticsearch aceitos nos filtros de intervalo
+// de ID
+var numericIDTypes = map[string]bool{
+	"long": true, "integer": true, "short": true, "byte": true, "unsigned_long": true,
+}
+
+// Verifica, pelo mapeamento, se field é um inteiro em todos os índices de
+// ES_URL que o mapeiam. Índices de clusters remotos não são verificados.
+func (ec *ElasticsearchClient) checkNumericField(ctx context.Context, field string) error {
+	if strings.Contains(ec.cfg.indexName(), ":") {
+		return nil
+	}
+
+	status, body, err := ec.request(ctx, "GET", ec.cfg.indexURL("/_mapping/field/"+url.PathEscape(field)))
+	if err != nil {
+		return fmt.Errorf("erro ao consultar o mapeamento de %q: %v", field, err)
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("erro ao consultar o mapeamento de %q: HTTP %d %s", field, status, body)
+	}
+
+	// {"indice": {"mappings": {"campo": {"mapping": {"folha": {"type": "long"}}}}}}
+	var mappings map[string]struct {
+		Mappings map[string]struct {
+			Mapping map[string]struct {
+				Type string `json:"type"`
+			} `json:"mapping"`
+		} `json:"mappings"`
+	}
+	if err := json.Unmarshal([]byte(body), &mappings); err != nil {
+		return fmt.Errorf("erro ao decodificar o mapeamento de %q: %v", field, err)
+	}
+
+	mapped := false
+	for index, m := range mappings {
+		for _, f := range m.Mappings {
+			for _, leaf := range f.Mapping {
+				if !numericIDTypes[leaf.Type] {
+					return fmt.Errorf("ID_FIELD %q é do tipo %s no índice %s; MIN_ID e MAX_ID requerem um campo inteiro", field, leaf.Type, index)
+				}
+				mapped = true
+			}
+		}
+	}
+	if !mapped {
+		return fmt.Errorf("ID_FIELD %q não está mapeado em %q; MIN_ID e MAX_ID requerem um campo inteiro", field, ec.cfg.indexName())
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const progressWidth = 30
+
+// Barra de progresso em uma única linha, redesenhada a cada página com o
+// total de documentos informado pelo track_total_hits da busca
+type progressBar struct {
+	out   io.Writer
+	start time.Time
+	base  int // documentos já processados antes desta execução (checkpoint)
+}
+
+func newProgressBar(out io.Writer, base int) *progressBar {
+	return &progressBar{out: out, start: time.Now(), base: base}
+}
+
+// Redesenha a barra com os documentos processados até o momento
+func (pb *progressBar) update(processed, total int) {
+	elapsed := time.Since(pb.start)
+	rate := 0.0
+	if elapsed > 0 {
+		rate = float64(processed-pb.base) / elapsed.Seconds()
+	}
+
+	if total <= 0 {
+		fmt.Fprintf(pb.out, "\r%d documentos  %.0f docs/s", processed, rate)
+		return
+	}
+
+	ratio := min(float64(processed)/float64(total), 1)
+	filled := int(ratio * progressWidth)
+	bar := strings.Repeat("=", filled) + strings.Repeat(" ", progressWidth-filled)
+
+	eta := "--"
+	if rate > 0 && processed < total {
+		eta = time.Duration(float64(total-processed) / rate * float64(time.Second)).Round(time.Second).String()
+	}
+
+	fmt.Fprintf(pb.out, "\r[%s] %d/%d %5.1f%%  %.0f docs/s  ETA %s   ",
+		bar, processed, total, ratio*100, rate, eta)
+}
+
+// Encerra a linha da barra para que os próximos logs comecem em nova linha
+func (pb *progressBar) finish() {
+	fmt.Fprintln(pb.out)
+}
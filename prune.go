@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// Pontos lidos por requisição de scroll na reconciliação
+const pruneScrollLimit = 1000
+
+// Remove da coleção os pontos cujo ID não está em keep, o conjunto de IDs
+// presentes no Elasticsearch. A coleção é percorrida com scroll, sem payload
+// nem vetores, e as remoções são enviadas em lotes de UpsertBatchSize IDs.
+// Com ID_PREFIX ou ID_OFFSET apenas os pontos do namespace são considerados.
+// Em dry-run apenas conta os pontos que seriam removidos.
+func (qc *QdrantClient) prune(ctx context.Context, keep map[string]struct{}) (int, error) {
+	removed := 0
+	var stale []*qdrant.PointId
+	var offset *qdrant.PointId
+
+	for {
+		var points []*qdrant.RetrievedPoint
+		err := qc.do(ctx, func(ctx context.Context) error {
+			var err error
+			points, offset, err = qc.client.ScrollAndOffset(ctx, &qdrant.ScrollPoints{
+				CollectionName: qc.cfg.CollectionName,
+				Offset:         offset,
+				Limit:          qdrant.PtrOf(uint32(pruneScrollLimit)),
+				Filter:         qc.namespaceFilter(),
+				WithPayload:    qdrant.NewWithPayload(false),
+				WithVectors:    qdrant.NewWithVectors(false),
+			})
+			return err
+		})
+		if err != nil {
+			return removed, fmt.Errorf("erro ao percorrer pontos da coleção: %v", err)
+		}
+
+		for _, point := range points {
+			if _, ok := keep[pointKey(point.GetId())]; !ok {
+				stale = append(stale, point.GetId())
+			}
+		}
+
+		// Remover em lotes completos ao longo do scroll; o restante fica
+		// para o final. Pontos já percorridos não afetam o próximo offset.
+		for len(stale) >= qc.cfg.UpsertBatchSize {
+			if err := qc.deletePoints(ctx, stale[:qc.cfg.UpsertBatchSize]); err != nil {
+				return removed, err
+			}
+			removed += qc.cfg.UpsertBatchSize
+			stale = stale[qc.cfg.UpsertBatchSize:]
+		}
+
+		if offset == nil {
+			break
+		}
+	}
+
+	if len(stale) > 0 {
+		if err := qc.deletePoints(ctx, stale); err != nil {
+			return removed, err
+		}
+		removed += len(stale)
+	}
+
+	return removed, nil
+}
+
+func (qc *QdrantClient) deletePoints(ctx context.Context, ids []*qdrant.PointId) error {
+	if qc.cfg.DryRun {
+		return nil
+	}
+
+	err := qc.do(ctx, func(ctx context.Context) error {
+		_, err := qc.client.Delete(ctx, &qdrant.DeletePoints{
+			CollectionName: qc.cfg.CollectionName,
+			Wait:           qdrant.PtrOf(qc.cfg.Wait),
+			Points:         qdrant.NewPointsSelectorIDs(ids),
+		})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("erro ao remover %d pontos: %v", len(ids), err)
+	}
+
+	log.Printf("%d pontos removidos da coleção '%s'", len(ids), qc.cfg.CollectionName)
+	return nil
+}
+
+// Filtro dos pontos gravados com o namespace de IDs desta execução, para que
+// o -prune e o -diff não tratem os pontos de outros índices da coleção como
+// removidos no Elasticsearch; nil sem namespace
+func (qc *QdrantClient) namespaceFilter() *qdrant.Filter {
+	ns := qc.cfg.idNamespace()
+	if ns == "" {
+		return nil
+	}
+	return &qdrant.Filter{Must: []*qdrant.Condition{qdrant.NewMatch(idNamespaceField, ns)}}
+}
+
+// Chave do ID do ponto retornado pelo Qdrant, comparável com docKey
+func pointKey(id *qdrant.PointId) string {
+	if uuid := id.GetUuid(); uuid != "" {
+		return strings.ToLower(uuid)
+	}
+	return strconv.FormatUint(id.GetNum(), 10)
+}
+
+// Chave do ID do documento; UUIDs em minúsculas, como o Qdrant os retorna
+func docKey(doc DocumentData) string {
+	return strings.ToLower(doc.idString())
+}
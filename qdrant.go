@@ -0,0 +1,738 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/qdrant/go-client/qdrant"
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Quantidade de payloads exibidos como amostra no dry-run
+const dryRunSamples = 3
+
+// Chave do payload com o texto do embedding, salvo em PayloadRename
+const textPayloadKey = "texto"
+
+// Chave do payload com a validade do ponto, com POINT_TTL
+const expiresAtField = "expires_at"
+
+// Chaves do payload com o hash da entrada do embedding e o modelo que gerou o
+// vetor, com EMBEDDING_METADATA
+const (
+	textHashField       = "text_hash"
+	embeddingModelField = "embedding_model"
+)
+
+// Cliente personalizado para Qdrant
+type QdrantClient struct {
+	client  *qdrant.Client
+	cfg     *Config
+	limiter *rate.Limiter
+
+	dimensionCheck
+
+	dryRunSampled atomic.Int32
+
+	mu sync.Mutex
+	// Coleções do CollectionTemplate já criadas ou verificadas nesta execução
+	ready map[string]bool
+	// Coleções já recriadas após um upsert com NotFound nesta execução
+	recreated map[string]bool
+	// Chaves de shard já criadas nesta execução, por coleção e chave
+	shardKeys map[string]bool
+}
+
+func NewQdrantClient(cfg *Config) (*QdrantClient, error) {
+	// Sem TLS a chave trafega em texto puro e pode ser capturada na rede
+	if cfg.QdrantAPIKey != "" && !cfg.QdrantTLS {
+		logWarnf("Aviso: QDRANT_API_KEY definida sem QDRANT_TLS; a chave será enviada sem criptografia")
+	}
+
+	client, err := qdrant.NewClient(&qdrant.Config{
+		Host:   cfg.QdrantHost,
+		Port:   cfg.QdrantPort,
+		UseTLS: cfg.QdrantTLS,
+		APIKey: cfg.QdrantAPIKey,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("erro ao conectar com Qdrant: %v", err)
+	}
+
+	return &QdrantClient{
+		client:         client,
+		cfg:            cfg,
+		limiter:        newRateLimiter(cfg.RateLimit),
+		dimensionCheck: dimensionCheck{cfg: cfg},
+		ready:          make(map[string]bool),
+		recreated:      make(map[string]bool),
+		shardKeys:      make(map[string]bool),
+	}, nil
+}
+
+func (qc *QdrantClient) Close() error {
+	return qc.client.Close()
+}
+
+// Executa uma requisição ao Qdrant com novas tentativas para erros
+// transitórios e limite de requisições por segundo. Cada tentativa recebe um
+// contexto limitado a QdrantTimeout, para que um servidor travado não bloqueie
+// a migração: o timeout é transitório e a requisição é repetida.
+func (qc *QdrantClient) do(ctx context.Context, fn func(ctx context.Context) error) error {
+	return withRetry(ctx, qc.cfg.MaxRetries, func() error {
+		if err := qc.limiter.Wait(ctx); err != nil {
+			return err
+		}
+		reqCtx, cancel := context.WithTimeout(ctx, qc.cfg.QdrantTimeout)
+		defer cancel()
+		return fn(reqCtx)
+	})
+}
+
+// Cria a coleção name, se ainda não existir
+func (qc *QdrantClient) createCollection(ctx context.Context, name string) error {
+	var exists bool
+	err := qc.do(ctx, func(ctx context.Context) error {
+		var err error
+		exists, err = qc.client.CollectionExists(ctx, name)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("erro ao verificar se coleção existe: %v", err)
+	}
+
+	if exists {
+		log.Printf("Coleção '%s' já existe", name)
+		// Em dry-run com -recreate a coleção antiga não foi apagada
+		if qc.cfg.Recreate && qc.cfg.DryRun {
+			return nil
+		}
+		return qc.checkCollection(ctx, name)
+	}
+
+	if qc.cfg.DryRun {
+		log.Printf("Dry-run: coleção '%s' seria criada (dimensão %d, distância %s, %s, %s)",
+			name, qc.cfg.VectorSize, qc.cfg.Distance, qc.shardingDescription(),
+			storageDescription(qc.cfg.OnDiskPayload, qc.cfg.OnDiskVectors))
+		if len(qc.cfg.NamedVectors) > 0 {
+			log.Printf("Dry-run: com os vetores nomeados %s", qc.cfg.namedVectorsDescription())
+		}
+		if qc.cfg.SparseVectors {
+			log.Printf("Dry-run: com vetor esparso '%s' (peso %s)", qc.cfg.SparseVectorName, qc.cfg.SparseWeighting)
+		}
+		log.Printf("Dry-run: %s", qc.cfg.datatypeDescription())
+		return nil
+	}
+
+	err = qc.do(ctx, func(ctx context.Context) error {
+		return qc.client.CreateCollection(ctx, &qdrant.CreateCollection{
+			CollectionName:      name,
+			VectorsConfig:       qc.vectorsConfig(),
+			OnDiskPayload:       optionalBool(qc.cfg.OnDiskPayload),
+			ShardNumber:         optionalUint32(qc.cfg.ShardNumber),
+			ReplicationFactor:   optionalUint32(qc.cfg.ReplicationFactor),
+			ShardingMethod:      qc.cfg.shardingMethod(),
+			HnswConfig:          qc.hnswConfig(),
+			QuantizationConfig:  qc.quantizationConfig(),
+			SparseVectorsConfig: qc.sparseVectorsConfig(),
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("erro ao criar coleção: %v", err)
+	}
+
+	log.Printf("Coleção '%s' criada com sucesso", name)
+	logEvent("vector_datatype", fmt.Sprintf("Coleção '%s': %s", name, qc.cfg.datatypeDescription()),
+		"collection", name, "datatype", qc.cfg.VectorDatatype)
+	qc.logCollectionConfig(ctx, name)
+	return nil
+}
+
+// Confere se a coleção existente aceita os pontos da migração: vetor sem
+// nome com a dimensão de VectorSize e a distância configurada (ou os vetores
+// de NamedVectors) e, com SparseVectors, o vetor esparso, e com
+// ShardKeyField o sharding custom. Upserts com outra dimensão seriam
+// rejeitados, e com outra distância as buscas dariam resultados incoerentes.
+func (qc *QdrantClient) checkCollection(ctx context.Context, name string) error {
+	info, err := qc.collectionInfo(ctx, name)
+	if err != nil {
+		return err
+	}
+	params := info.GetConfig().GetParams()
+
+	if len(qc.cfg.NamedVectors) > 0 {
+		if err := qc.checkNamedVectors(name, params.GetVectorsConfig()); err != nil {
+			return err
+		}
+	} else if err := qc.checkVector(name, params.GetVectorsConfig().GetParams()); err != nil {
+		return err
+	}
+	if qc.cfg.SparseVectors {
+		if _, ok := params.GetSparseVectorsConfig().GetMap()[qc.cfg.SparseVectorName]; !ok {
+			return fmt.Errorf("a coleção '%s' já existe sem o vetor esparso '%s' (SPARSE_VECTOR_NAME); use -recreate para recriá-la", name, qc.cfg.SparseVectorName)
+		}
+	}
+	if qc.cfg.ShardKeyField != "" && params.GetShardingMethod() != qdrant.ShardingMethod_Custom {
+		return fmt.Errorf("a coleção '%s' já existe com sharding automático, e SHARD_KEY_FIELD exige sharding custom; use -recreate para recriá-la", name)
+	}
+	return nil
+}
+
+// Confere o vetor sem nome da coleção existente; nil indica vetores nomeados
+func (qc *QdrantClient) checkVector(name string, vectors *qdrant.VectorParams) error {
+	if vectors == nil {
+		return fmt.Errorf("a coleção '%s' já existe com vetores nomeados, e a migração grava um vetor sem nome (use NAMED_VECTORS, -recreate ou outra COLLECTION_NAME)", name)
+	}
+	if vectors.GetSize() != uint64(qc.cfg.VectorSize) {
+		return fmt.Errorf("a coleção '%s' já existe com dimensão %d, diferente de VECTOR_SIZE (%d); use -recreate para recriá-la ou outra COLLECTION_NAME",
+			name, vectors.GetSize(), qc.cfg.VectorSize)
+	}
+	if vectors.GetDistance() != qc.cfg.distance() {
+		return fmt.Errorf("a coleção '%s' já existe com distância %s, diferente de DISTANCE (%s); use -recreate para recriá-la ou outra COLLECTION_NAME",
+			name, strings.ToLower(vectors.GetDistance().String()), qc.cfg.Distance)
+	}
+	return nil
+}
+
+// Apaga a coleção, se existir, para que createCollection a recrie com a
+// configuração atual (-recreate). A quantidade de pontos perdidos é
+// registrada antes; sem Force, a exclusão exige digitar o nome da coleção
+// no terminal.
+func (qc *QdrantClient) deleteCollection(ctx context.Context, name string) error {
+	var exists bool
+	err := qc.do(ctx, func(ctx context.Context) error {
+		var err error
+		exists, err = qc.client.CollectionExists(ctx, name)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("erro ao verificar se coleção existe: %v", err)
+	}
+	if !exists {
+		log.Printf("Coleção '%s' não existe; nada a apagar", name)
+		return nil
+	}
+
+	info, err := qc.collectionInfo(ctx, name)
+	if err != nil {
+		return err
+	}
+	points := info.GetPointsCount()
+	logWarnf("A coleção '%s' será apagada e recriada: %d pontos serão perdidos", name, points)
+
+	if qc.cfg.DryRun {
+		log.Printf("Dry-run: coleção '%s' seria apagada e recriada", name)
+		return nil
+	}
+	if !qc.cfg.Force {
+		if err := confirmDeletion(name); err != nil {
+			return err
+		}
+	}
+
+	err = qc.do(ctx, func(ctx context.Context) error {
+		return qc.client.DeleteCollection(ctx, name)
+	})
+	if err != nil {
+		return fmt.Errorf("erro ao apagar coleção: %v", err)
+	}
+
+	logEvent("collection_deleted", fmt.Sprintf("Coleção '%s' apagada (%d pontos)", name, points),
+		"collection", name, "points", points)
+	return nil
+}
+
+// Pede que o nome da coleção seja digitado no terminal. Sem um terminal
+// (CI, contêineres), a exclusão exige -force.
+func confirmDeletion(name string) error {
+	if fi, err := os.Stdin.Stat(); err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		return fmt.Errorf("a exclusão da coleção '%s' precisa de confirmação; sem um terminal, use -force", name)
+	}
+
+	fmt.Fprintf(os.Stderr, "Digite o nome da coleção ('%s') para confirmar a exclusão: ", name)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	if strings.TrimSpace(answer) != name {
+		return fmt.Errorf("confirmação não recebida; a coleção '%s' foi mantida", name)
+	}
+	return nil
+}
+
+// Informações da coleção: configuração, esquema do payload e contagens
+func (qc *QdrantClient) collectionInfo(ctx context.Context, name string) (*qdrant.CollectionInfo, error) {
+	var info *qdrant.CollectionInfo
+	err := qc.do(ctx, func(ctx context.Context) error {
+		var err error
+		info, err = qc.client.GetCollectionInfo(ctx, name)
+		return err
+	})
+	return info, err
+}
+
+// Shards e fator de replicação pedidos, para os logs do dry-run
+func (qc *QdrantClient) shardingDescription() string {
+	shards, replicas := "padrão", "padrão"
+	if qc.cfg.ShardNumber > 0 {
+		shards = fmt.Sprint(qc.cfg.ShardNumber)
+	}
+	if qc.cfg.ReplicationFactor > 0 {
+		replicas = fmt.Sprint(qc.cfg.ReplicationFactor)
+	}
+	if qc.cfg.ShardKeyField != "" {
+		return fmt.Sprintf("sharding custom por '%s', shards %s por chave, replicação %s", qc.cfg.ShardKeyField, shards, replicas)
+	}
+	return fmt.Sprintf("shards %s, replicação %s", shards, replicas)
+}
+
+// Armazenamento do payload e dos vetores, para os logs
+func storageDescription(onDiskPayload, onDiskVectors bool) string {
+	where := func(onDisk bool) string {
+		if onDisk {
+			return "disco"
+		}
+		return "RAM"
+	}
+	return fmt.Sprintf("payload em %s, vetores em %s", where(onDiskPayload), where(onDiskVectors))
+}
+
+// Opção booleana do CreateCollection; false mantém o padrão do Qdrant
+func optionalBool(v bool) *bool {
+	if !v {
+		return nil
+	}
+	return qdrant.PtrOf(true)
+}
+
+// Valor opcional do CreateCollection; zero mantém o padrão do Qdrant
+func optionalUint32(v int) *uint32 {
+	if v == 0 {
+		return nil
+	}
+	return qdrant.PtrOf(uint32(v))
+}
+
+// Exibe a configuração efetiva da coleção recém-criada. O Qdrant aceita um
+// fator de replicação maior que a quantidade de nós, mas mantém no máximo uma
+// réplica de cada shard por nó; nesse caso a distribuição das réplicas revela
+// o problema, e um aviso é exibido. Falhas na consulta apenas omitem o log.
+func (qc *QdrantClient) logCollectionConfig(ctx context.Context, name string) {
+	info, err := qc.collectionInfo(ctx, name)
+	if err != nil {
+		logErrorf("Erro ao consultar configuração da coleção: %v", err)
+		return
+	}
+	params := info.GetConfig().GetParams()
+	logEvent("collection_config", fmt.Sprintf("Configuração da coleção '%s': %d shard(s), fator de replicação %d, consistência de escrita %d",
+		name, params.GetShardNumber(), params.GetReplicationFactor(), params.GetWriteConsistencyFactor()),
+		"collection", name, "shards", params.GetShardNumber(),
+		"replication_factor", params.GetReplicationFactor(), "write_consistency_factor", params.GetWriteConsistencyFactor())
+	onDiskVectors := params.GetVectorsConfig().GetParams().GetOnDisk()
+	logEvent("collection_storage", fmt.Sprintf("Armazenamento da coleção '%s': %s", name, storageDescription(params.GetOnDiskPayload(), onDiskVectors)),
+		"collection", name, "on_disk_payload", params.GetOnDiskPayload(), "on_disk_vectors", onDiskVectors)
+
+	if params.GetReplicationFactor() <= 1 {
+		return
+	}
+	var cluster *qdrant.CollectionClusterInfoResponse
+	err = qc.do(ctx, func(ctx context.Context) error {
+		var err error
+		cluster, err = qc.client.GetCollectionsClient().CollectionClusterInfo(ctx, &qdrant.CollectionClusterInfoRequest{
+			CollectionName: name,
+		})
+		return err
+	})
+	if err != nil {
+		logErrorf("Erro ao consultar distribuição dos shards: %v", err)
+		return
+	}
+
+	// Réplicas por shard e nós que mantêm alguma réplica
+	replicas := make(map[uint32]int)
+	peers := map[uint64]struct{}{cluster.GetPeerId(): {}}
+	for _, shard := range cluster.GetLocalShards() {
+		replicas[shard.GetShardId()]++
+	}
+	for _, shard := range cluster.GetRemoteShards() {
+		replicas[shard.GetShardId()]++
+		peers[shard.GetPeerId()] = struct{}{}
+	}
+	for shard, n := range replicas {
+		if uint32(n) < params.GetReplicationFactor() {
+			logWarnf("Aviso: fator de replicação %d maior que os nós disponíveis; o shard %d tem %d réplica(s) em %d nó(s)",
+				params.GetReplicationFactor(), shard, n, len(peers))
+			return
+		}
+	}
+}
+
+// Cria os índices de PayloadIndexes que ainda não existem na coleção name
+func (qc *QdrantClient) createPayloadIndexes(ctx context.Context, name string) error {
+	if len(qc.cfg.PayloadIndexes) == 0 {
+		return nil
+	}
+
+	if qc.cfg.DryRun {
+		for _, index := range qc.cfg.PayloadIndexes {
+			log.Printf("Dry-run: índice de payload '%s' (%s) seria criado", index.Field, index.Type)
+		}
+		return nil
+	}
+
+	info, err := qc.collectionInfo(ctx, name)
+	if err != nil {
+		return fmt.Errorf("erro ao consultar índices da coleção: %v", err)
+	}
+	existing := info.GetPayloadSchema()
+
+	for _, index := range qc.cfg.PayloadIndexes {
+		if _, ok := existing[index.Field]; ok {
+			log.Printf("Índice de payload '%s' já existe", index.Field)
+			continue
+		}
+
+		err := qc.do(ctx, func(ctx context.Context) error {
+			_, err := qc.client.CreateFieldIndex(ctx, &qdrant.CreateFieldIndexCollection{
+				CollectionName: name,
+				Wait:           qdrant.PtrOf(true),
+				FieldName:      index.Field,
+				FieldType:      payloadIndexTypes[index.Type].Enum(),
+			})
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("erro ao criar índice de payload '%s': %v", index.Field, err)
+		}
+		log.Printf("Índice de payload '%s' (%s) criado", index.Field, index.Type)
+	}
+
+	return nil
+}
+
+// Parâmetros do HNSW informados na configuração; nil mantém o padrão
+func (qc *QdrantClient) hnswConfig() *qdrant.HnswConfigDiff {
+	if qc.cfg.HnswM == 0 && qc.cfg.HnswEfConstruct == 0 {
+		return nil
+	}
+
+	hnsw := &qdrant.HnswConfigDiff{}
+	if qc.cfg.HnswM > 0 {
+		hnsw.M = qdrant.PtrOf(uint64(qc.cfg.HnswM))
+	}
+	if qc.cfg.HnswEfConstruct > 0 {
+		hnsw.EfConstruct = qdrant.PtrOf(uint64(qc.cfg.HnswEfConstruct))
+	}
+	return hnsw
+}
+
+// Quantização scalar (int8) ou product; nil quando desativada
+func (qc *QdrantClient) quantizationConfig() *qdrant.QuantizationConfig {
+	var alwaysRAM *bool
+	if qc.cfg.QuantizationAlwaysRAM {
+		alwaysRAM = qdrant.PtrOf(true)
+	}
+
+	switch qc.cfg.Quantization {
+	case "scalar":
+		scalar := &qdrant.ScalarQuantization{
+			Type:      qdrant.QuantizationType_Int8,
+			AlwaysRam: alwaysRAM,
+		}
+		if qc.cfg.QuantizationQuantile > 0 {
+			scalar.Quantile = qdrant.PtrOf(float32(qc.cfg.QuantizationQuantile))
+		}
+		return qdrant.NewQuantizationScalar(scalar)
+	case "product":
+		return qdrant.NewQuantizationProduct(&qdrant.ProductQuantization{
+			Compression: qdrant.CompressionRatio(qdrant.CompressionRatio_value[qc.cfg.PQCompression]),
+			AlwaysRam:   alwaysRAM,
+		})
+	}
+	return nil
+}
+
+// Vetor esparso declarado na coleção; nil quando desativado
+func (qc *QdrantClient) sparseVectorsConfig() *qdrant.SparseVectorConfig {
+	if !qc.cfg.SparseVectors {
+		return nil
+	}
+
+	params := &qdrant.SparseVectorParams{}
+	if qc.cfg.SparseIDF {
+		params.Modifier = qdrant.Modifier_Idf.Enum()
+	}
+	return qdrant.NewSparseVectorsConfig(map[string]*qdrant.SparseVectorParams{
+		qc.cfg.SparseVectorName: params,
+	})
+}
+
+// Payload do ponto: os campos extraídos e o texto do documento, na chave
+// "texto" ou na definida em PayloadRename; um TextField em array é gravado
+// como array
+func newPointPayload(doc DocumentData, cfg *Config) map[string]interface{} {
+	payload := make(map[string]interface{}, len(doc.Payload)+1)
+	for k, v := range doc.Payload {
+		payload[k] = v
+	}
+	if doc.TextArray != nil {
+		setField(payload, cfg.payloadKey(textPayloadKey), doc.TextArray)
+	} else {
+		setField(payload, cfg.payloadKey(textPayloadKey), doc.Texto)
+	}
+	if doc.ContentHash != "" {
+		payload[contentHashField] = doc.ContentHash
+	}
+	if ns := cfg.idNamespace(); ns != "" {
+		payload[idNamespaceField] = ns
+	}
+	if cfg.PointTTL > 0 {
+		payload[expiresAtField] = time.Now().Add(cfg.PointTTL).UTC().Format(time.RFC3339)
+	}
+	if cfg.EmbeddingMetadata {
+		payload[textHashField] = textHash(doc.Texto)
+		payload[embeddingModelField] = cfg.embeddingModel()
+	}
+	return payload
+}
+
+func (qc *QdrantClient) newPoint(doc DocumentData) *qdrant.PointStruct {
+	return &qdrant.PointStruct{
+		Id:      doc.pointID(),
+		Vectors: qc.pointVectors(doc),
+		Payload: qdrant.NewValueMap(newPointPayload(doc, qc.cfg)),
+	}
+}
+
+// Vetor denso do documento (ou, com NamedVectors, um por nome) e, com
+// SparseVectors, o vetor esparso do texto. Sem NamedVectors o vetor denso
+// continua sem nome (""), como na coleção sem vetor esparso, e com
+// VECTOR_DATATYPE=uint8 os densos são convertidos para inteiros de 0 a 255.
+func (qc *QdrantClient) pointVectors(doc DocumentData) *qdrant.Vectors {
+	dense := map[string][]float32{"": doc.Vector}
+	if len(qc.cfg.NamedVectors) > 0 {
+		dense = doc.denseVectors(qc.cfg)
+	}
+	if qc.cfg.VectorDatatype == "uint8" {
+		for name, vector := range dense {
+			dense[name] = quantizeUint8(vector)
+		}
+	}
+	if len(qc.cfg.NamedVectors) == 0 && !qc.cfg.SparseVectors {
+		return qdrant.NewVectors(dense[""]...)
+	}
+
+	vectors := make(map[string]*qdrant.Vector, len(dense)+1)
+	for name, vector := range dense {
+		vectors[name] = qdrant.NewVectorDense(vector)
+	}
+	if indices, values := sparseVector(doc.Texto, qc.cfg); len(indices) > 0 {
+		vectors[qc.cfg.SparseVectorName] = qdrant.NewVectorSparse(indices, values)
+	}
+	return qdrant.NewVectorsMap(vectors)
+}
+
+// Upsert no Qdrant, com novas tentativas para erros transitórios e limite
+// de requisições por segundo. Se a coleção foi removida ou ainda não está
+// disponível, o upsert falha com NotFound: a coleção é então recriada, com
+// os índices de payload, e o lote repetido, apenas uma vez por execução para
+// não entrar em laço se ela continuar sendo removida. Com SkipCreate, a
+// coleção não é recriada e o erro indica que ela precisa ser criada fora da
+// migração.
+func (qc *QdrantClient) upsertPoints(ctx context.Context, collection, shardKey string, points []*qdrant.PointStruct) error {
+	err := qc.upsertOnce(ctx, collection, shardKey, points)
+	if status.Code(err) != codes.NotFound {
+		return err
+	}
+	if qc.cfg.SkipCreate {
+		return &CollectionNotFoundError{Collection: collection, Err: err}
+	}
+	qc.mu.Lock()
+	recreated := qc.recreated[collection]
+	qc.recreated[collection] = true
+	qc.mu.Unlock()
+	if recreated {
+		return err
+	}
+
+	logWarnf("Coleção '%s' não encontrada no upsert (%v); recriando e repetindo o lote", collection, err)
+	if err := qc.createCollection(ctx, collection); err != nil {
+		return fmt.Errorf("erro ao recriar coleção: %w", err)
+	}
+	if err := qc.createPayloadIndexes(ctx, collection); err != nil {
+		return fmt.Errorf("erro ao recriar índices de payload: %w", err)
+	}
+	// A coleção recriada não tem as chaves de shard do cache
+	if shardKey != "" {
+		if err := qc.createShardKey(ctx, collection, shardKey); err != nil {
+			return err
+		}
+	}
+	return qc.upsertOnce(ctx, collection, shardKey, points)
+}
+
+func (qc *QdrantClient) upsertOnce(ctx context.Context, collection, shardKey string, points []*qdrant.PointStruct) error {
+	return qc.do(ctx, func(ctx context.Context) error {
+		_, err := qc.client.Upsert(ctx, &qdrant.UpsertPoints{
+			CollectionName:   collection,
+			Wait:             qdrant.PtrOf(qc.cfg.Wait),
+			Points:           points,
+			ShardKeySelector: shardKeySelector(shardKey),
+		})
+		return err
+	})
+}
+
+// Divide pela metade, recursivamente, um lote rejeitado, gravando as partes
+// aceitas, até chegar aos pontos que o Qdrant recusa individualmente. Retorna
+// os pontos gravados e um erro por ponto rejeitado, ou por parte quando a
+// falha passa a ser transitória, como uma indisponibilidade no meio do processo.
+func (qc *QdrantClient) isolateFailures(ctx context.Context, collection, shardKey string, points []*qdrant.PointStruct) (int, []error) {
+	written := 0
+	var rejected []error
+	mid := len(points) / 2
+	for _, part := range [][]*qdrant.PointStruct{points[:mid], points[mid:]} {
+		if len(part) == 0 {
+			continue
+		}
+		err := qc.writePoints(ctx, collection, shardKey, part)
+		switch {
+		case err == nil:
+			written += len(part)
+		case isRetryable(err) || ctx.Err() != nil:
+			rejected = append(rejected, fmt.Errorf("coleção '%s', %d pontos: %w", collection, len(part), err))
+		case len(part) == 1:
+			key := pointKey(part[0].GetId())
+			logErrorEvent("point_rejected", fmt.Sprintf("Ponto %s rejeitado pela coleção '%s': %v", key, collection, err),
+				"id", key, "collection", collection)
+			rejected = append(rejected, fmt.Errorf("coleção '%s', ponto %s: %w", collection, key, err))
+		default:
+			n, errs := qc.isolateFailures(ctx, collection, shardKey, part)
+			written += n
+			rejected = append(rejected, errs...)
+		}
+	}
+	return written, rejected
+}
+
+// Insere os documentos em lotes de UpsertBatchSize pontos, uma requisição por
+// lote, coleção de destino e chave de shard; com CollectionTemplate, as
+// coleções que ainda não existem são criadas antes do primeiro lote, e com
+// ShardKeyField, as chaves de shard. Lotes com falha não interrompem os
+// demais; retorna a quantidade de pontos efetivamente gravados.
+func (qc *QdrantClient) upsertDocuments(ctx context.Context, docs []DocumentData) (int, error) {
+	docs, err := qc.checkDimensions(docs)
+	if err != nil {
+		return 0, err
+	}
+
+	written := 0
+	var failures batchErrors
+
+	for _, group := range groupByCollection(docs, qc.cfg) {
+		if qc.cfg.CollectionTemplate != "" && !qc.cfg.SkipCreate {
+			if err := qc.ensureCollection(ctx, group.collection); err != nil {
+				failures = append(failures, fmt.Errorf("coleção '%s': %w", group.collection, err))
+				continue
+			}
+		}
+		if qc.cfg.DryRun {
+			qc.logDryRunSample(group.docs)
+			written += len(group.docs)
+			continue
+		}
+		if group.shardKey != "" && !qc.cfg.PayloadOnly {
+			if err := qc.ensureShardKey(ctx, group.collection, group.shardKey); err != nil {
+				failures = append(failures, fmt.Errorf("coleção '%s': %w", group.collection, err))
+				continue
+			}
+		}
+
+		for start := 0; start < len(group.docs); start += qc.cfg.UpsertBatchSize {
+			end := min(start+qc.cfg.UpsertBatchSize, len(group.docs))
+
+			points := make([]*qdrant.PointStruct, 0, end-start)
+			for _, doc := range group.docs[start:end] {
+				points = append(points, qc.newPoint(doc))
+			}
+
+			if err := qc.writePoints(ctx, group.collection, group.shardKey, points); err != nil {
+				// Falhas transitórias já foram repetidas por qc.do e atingiriam
+				// também as partes do lote
+				if !qc.cfg.IsolateFailures || isRetryable(err) || ctx.Err() != nil {
+					failures = append(failures, fmt.Errorf("coleção '%s', documentos %d-%d: %w", group.collection, start, end-1, err))
+					continue
+				}
+				logWarnf("Lote %d-%d rejeitado pela coleção '%s' (%v); reenviando em partes para isolar os pontos inválidos",
+					start, end-1, group.collection, err)
+				n, rejected := qc.isolateFailures(ctx, group.collection, group.shardKey, points)
+				written += n
+				failures = append(failures, rejected...)
+				continue
+			}
+
+			written += len(points)
+		}
+	}
+
+	if len(failures) > 0 {
+		return written, failures
+	}
+
+	return written, nil
+}
+
+// Validação da dimensão dos vetores antes da gravação, comum aos sinks
+type dimensionCheck struct {
+	cfg     *Config
+	skipped atomic.Int64
+}
+
+// Compara a dimensão dos vetores com VectorSize antes do upsert. Com
+// OnDimMismatch "skip" os documentos divergentes são removidos do lote e
+// contados; com "fail" retorna *DimensionError sem gravar nenhum documento.
+// Em dry-run sem embeddings não há vetores para validar.
+func (d *dimensionCheck) checkDimensions(docs []DocumentData) ([]DocumentData, error) {
+	if !d.cfg.hasVectors() {
+		return docs, nil
+	}
+
+	valid := docs[:0:0]
+	for _, doc := range docs {
+		if len(doc.Vector) == d.cfg.VectorSize {
+			valid = append(valid, doc)
+			continue
+		}
+
+		dimErr := &DimensionError{ID: doc.idString(), Got: len(doc.Vector), Want: d.cfg.VectorSize}
+		if d.cfg.OnDimMismatch != "skip" {
+			return nil, dimErr
+		}
+		d.skipped.Add(1)
+		logWarnf("Documento ignorado: %v", dimErr)
+	}
+	return valid, nil
+}
+
+// Documentos ignorados por dimensão divergente (OnDimMismatch "skip")
+func (d *dimensionCheck) skippedDimensions() int64 {
+	return d.skipped.Load()
+}
+
+// Exibe os payloads dos primeiros documentos do dry-run
+func (qc *QdrantClient) logDryRunSample(docs []DocumentData) {
+	for _, doc := range docs {
+		if qc.dryRunSampled.Add(1) > dryRunSamples {
+			return
+		}
+		payload, _ := json.Marshal(newPointPayload(doc, qc.cfg))
+		log.Printf("Dry-run: ponto %s, payload %s", doc.idString(), payload)
+	}
+}
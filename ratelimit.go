@@ -0,0 +1,13 @@
+package main
+
+import (
+	"golang.org/x/time/rate"
+)
+
+// Limitador de requisições por segundo; rps <= 0 não limita
+func newRateLimiter(rps float64) *rate.Limiter {
+	if rps <= 0 {
+		return rate.NewLimiter(rate.Inf, 0)
+	}
+	return rate.NewLimiter(rate.Limit(rps), 1)
+}
@@ -0,0 +1,383 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Leitura paginada do Elasticsearch, por scroll (fatiado ou não) ou
+// search_after
+type ESSearcher interface {
+	searchDocumentsScroll(ctx context.Context, scrollID string) (*SearchResponse, error)
+	searchSliceScroll(ctx context.Context, slice int, scrollID string) (*SearchResponse, error)
+	searchDocumentsAfter(ctx context.Context, sort []string, after []interface{}) (*SearchResponse, []interface{}, error)
+}
+
+// Destino dos documentos com vetor; retorna a quantidade gravada
+type VectorStore interface {
+	upsertDocuments(ctx context.Context, docs []DocumentData) (int, error)
+}
+
+// Quantidade de erros de busca que encerra a leitura
+const maxSearchErrors = 5
+
+// Laço de leitura: busca as páginas do Elasticsearch e as envia ao pipeline
+// até uma página vazia ou um cursor que não avança, um sinal de interrupção
+// ou o abort do pipeline
+type reader struct {
+	cfg       *Config
+	es        ESSearcher
+	pipe      *pipeline
+	errLog    *errorLog
+	ckptState *checkpointState
+	progress  *progressBar // nil exibe os logs por lote
+	missing   *fieldCounter
+	seen      map[string]struct{} // IDs lidos, para o -prune; nil desativa
+	dedup     *dedupFilter        // nil não descarta duplicados
+	filter    *docFilter          // nil não aplica predicados
+
+	// Posição da leitura; inicializados a partir do checkpoint na retomada
+	scrollID        string
+	after           []interface{}
+	read            int // documentos lidos, incluindo os da execução anterior
+	start           int // documentos já lidos ao iniciar esta execução
+	skip            int // documentos a descartar na retomada em modo scroll
+	processedBefore int
+
+	batch     int
+	errors    int
+	submitted int // documentos enviados ao pipeline, para o -limit
+	// Documentos sem ID válido, ignorados ou com ID derivado do _id
+	invalidIDs int
+	// Total da consulta informado pelo Elasticsearch na última página
+	total        int
+	totalChanged bool
+	interrupted  bool
+
+	// Duração das buscas bem-sucedidas, com as novas tentativas
+	searchTiming stageTiming
+
+	// Scroll fatiado (ScrollSlices > 1): o scroll ID de cada fatia, para
+	// liberar os contextos ao final, e o total informado por cada uma, -1 até
+	// a primeira página
+	sliceScrollIDs []string
+	sliceTotals    []int
+	sliceReaders   sync.WaitGroup
+}
+
+// Página de uma fatia do scroll fatiado
+type slicePage struct {
+	slice  int
+	result *SearchResponse
+	err    error
+}
+
+// Executa a leitura até o fim dos documentos. Retorna erro apenas quando as
+// buscas falham repetidamente; a interrupção por ctx é indicada em interrupted.
+func (r *reader) run(ctx context.Context) error {
+	var pages <-chan slicePage
+	if r.cfg.PaginationMode == "scroll" && r.cfg.ScrollSlices > 1 {
+		sliceCtx, cancel := context.WithCancel(ctx)
+		pages = r.readSlices(sliceCtx)
+		// As fatias terminam antes do retorno, com os scroll IDs finais
+		defer r.sliceReaders.Wait()
+		defer cancel()
+	}
+
+	for {
+		if ctx.Err() != nil {
+			r.interrupted = true
+			return nil
+		}
+		if r.pipe.aborted() != nil {
+			return nil
+		}
+
+		if r.progress == nil {
+			logDebugf("Buscando lote %d (%d documentos por lote)...", r.batch+1, r.cfg.PageSize)
+		}
+
+		// Circuito aberto por excesso de erros: aguardar antes de nova busca
+		if err := r.pipe.breaker.wait(ctx); err != nil {
+			r.interrupted = true
+			return nil
+		}
+		// Memória acima de MEMORY_LIMIT_MB: aguardar a gravação dos pendentes
+		if err := r.pipe.memory.wait(ctx, r.pipe.pendingDocs); err != nil {
+			r.interrupted = true
+			return nil
+		}
+
+		// Buscar documentos no Elasticsearch
+		inicioBusca := time.Now()
+		var result *SearchResponse
+		var err error
+		var stuck bool
+		slice := -1
+		if pages != nil {
+			select {
+			case <-ctx.Done():
+				r.interrupted = true
+				return nil
+			case page, ok := <-pages:
+				// Canal fechado: todas as fatias chegaram à página vazia
+				result = &SearchResponse{}
+				if ok {
+					slice, result, err = page.slice, page.result, page.err
+				}
+			}
+		} else if r.cfg.PaginationMode == "search_after" {
+			var next []interface{}
+			result, next, err = r.es.searchDocumentsAfter(ctx, []string{r.cfg.SortField}, r.after)
+			if err == nil {
+				// Uma página com documentos sempre avança o cursor; se ele não
+				// mudou, a próxima busca repetiria a mesma página
+				stuck = len(result.Hits.Hits) > 0 && (next == nil || reflect.DeepEqual(next, r.after))
+				r.after = next
+			}
+		} else {
+			result, err = r.es.searchDocumentsScroll(ctx, r.scrollID)
+		}
+		r.pipe.breaker.record(err)
+		if err != nil {
+			if ctx.Err() != nil {
+				r.interrupted = true
+				return nil
+			}
+			r.errors++
+			err = fmt.Errorf("erro ao buscar documentos: %w", err)
+			r.errLog.record("busca", err, "batch", r.batch+1)
+			if r.errors >= maxSearchErrors {
+				return err
+			}
+			continue
+		}
+
+		r.searchTiming.observe(time.Since(inicioBusca))
+
+		// O scroll ID pode mudar entre as chamadas; o das fatias fica com
+		// readSlice
+		if result.ScrollID != "" && slice < 0 {
+			r.scrollID = result.ScrollID
+		}
+		if slice >= 0 {
+			if !r.cfg.CountFirst {
+				r.updateSliceTotal(slice, result.Hits.Total.Value)
+			}
+			// Fatia encerrada; a leitura continua até o fim de todas
+			if len(result.Hits.Hits) == 0 {
+				continue
+			}
+		}
+		r.batch++
+
+		// Se não há mais documentos, encerrar. Páginas com menos de PageSize
+		// documentos não indicam o fim: apenas a página vazia.
+		if len(result.Hits.Hits) == 0 {
+			log.Println("Não há mais documentos para processar")
+			r.checkTotal()
+			return nil
+		}
+
+		// Com CountFirst as buscas não trazem o total; vale o do _count
+		if !r.cfg.CountFirst && pages == nil {
+			r.updateTotal(result.Hits.Total.Value)
+		}
+		if r.progress == nil {
+			logDebugEvent("batch_fetched", fmt.Sprintf("Total de documentos encontrados: %d", r.total),
+				"batch", r.batch, "hits", len(result.Hits.Hits), "total", r.total, "duration_ms", durationMs(inicioBusca),
+				"es_took_ms", result.Took, "round_trip_ms", result.RoundTrip.Milliseconds())
+		}
+
+		hits := result.Hits.Hits
+		if r.seen != nil {
+			for _, hit := range hits {
+				doc := extractDocumentData(hit, r.cfg)
+				if doc.InvalidID != nil && r.cfg.OnMissingID != "hash" {
+					continue
+				}
+				// Com LONG_TEXT=chunk, os IDs dos pontos são os dos trechos
+				for _, doc := range limitTextLength([]DocumentData{doc}, r.cfg) {
+					r.seen[docKey(doc)] = struct{}{}
+				}
+			}
+		}
+		if r.skip > 0 {
+			n := min(r.skip, len(hits))
+			hits = hits[n:]
+			r.skip -= n
+			if len(hits) == 0 {
+				continue
+			}
+		}
+		r.read += len(hits)
+		documentsRead.Add(float64(len(hits)))
+
+		docs := make([]DocumentData, 0, len(hits))
+		var maxSync time.Time
+		for _, hit := range hits {
+			doc := extractDocumentData(hit, r.cfg)
+			r.missing.add(doc.Missing)
+			if doc.InvalidID != nil {
+				if r.cfg.OnMissingID == "fail" {
+					r.pipe.abort(&MissingIDError{DocID: hit.ID, Err: doc.InvalidID})
+					return nil
+				}
+				r.invalidID(hit, doc)
+				if r.cfg.OnMissingID == "skip" {
+					continue
+				}
+			}
+			docs = append(docs, doc)
+			if r.cfg.SyncField != "" {
+				v, _ := lookupField(hit.fieldsSource(), r.cfg.SyncField)
+				if t, ok := parseSyncTime(normalizeJSON(v)); ok && t.After(maxSync) {
+					maxSync = t
+				}
+			}
+		}
+		if r.filter != nil {
+			docs = r.filter.filter(docs)
+		}
+		docs = limitTextLength(docs, r.cfg)
+		if r.dedup != nil {
+			docs = r.dedup.filter(docs)
+		}
+		limited := r.cfg.Limit > 0 && r.submitted+len(docs) >= r.cfg.Limit
+		if limited {
+			docs = docs[:r.cfg.Limit-r.submitted]
+		}
+		r.submitted += len(docs)
+		r.pipe.submit(docs, r.ckptState.commitFunc(r.read, r.after, r.processedBefore+r.read-r.start, maxSync))
+
+		gravados, falhas := r.pipe.stats()
+		if r.progress != nil {
+			r.progress.update(r.processedBefore+gravados+falhas+r.pipe.unchangedCount(), r.total)
+		} else {
+			logEvent("batch_queued", fmt.Sprintf("Lote %d enfileirado: %d documentos lidos. Total gravado: %d, falhas: %d",
+				r.batch, r.read, gravados, falhas),
+				"batch", r.batch, "read", r.read, "processed", r.processedBefore+gravados, "errors", falhas)
+		}
+
+		if limited {
+			logEvent("limit_reached", fmt.Sprintf("Limite de %d documentos atingido; encerrando a leitura", r.cfg.Limit),
+				"limit", r.cfg.Limit, "read", r.read)
+			return nil
+		}
+
+		if stuck {
+			logErrorEvent("cursor_stuck", fmt.Sprintf("O cursor search_after não avançou no lote %d; encerrando a leitura (verifique se SORT_FIELD existe em todos os documentos)", r.batch),
+				"batch", r.batch, "search_after", r.after)
+			r.checkTotal()
+			return nil
+		}
+
+		// Pausa entre lotes, maior quando os upserts ficam lentos; com -rate
+		// o limitador dos clientes já controla a carga
+		if r.cfg.RateLimit == 0 {
+			if delay := r.pipe.throttle.delay(); delay > 0 {
+				select {
+				case <-ctx.Done():
+				case <-time.After(delay):
+				}
+			}
+		}
+	}
+}
+
+// Inicia a leitura das ScrollSlices fatias do scroll em paralelo, uma
+// goroutine e um contexto de scroll por fatia, e retorna o canal das páginas,
+// que o laço de leitura processa na ordem em que chegam. O canal é fechado
+// quando todas as fatias chegam à página vazia ou ctx é cancelado.
+func (r *reader) readSlices(ctx context.Context) <-chan slicePage {
+	pages := make(chan slicePage)
+	r.sliceScrollIDs = make([]string, r.cfg.ScrollSlices)
+	r.sliceTotals = make([]int, r.cfg.ScrollSlices)
+	for i := 0; i < r.cfg.ScrollSlices; i++ {
+		r.sliceTotals[i] = -1
+		r.sliceReaders.Add(1)
+		go r.readSlice(ctx, i, pages)
+	}
+	go func() {
+		r.sliceReaders.Wait()
+		close(pages)
+	}()
+	return pages
+}
+
+// Lê as páginas da fatia, inclusive a vazia do fim, e as falhas de busca,
+// que são repetidas com o mesmo scroll ID. A próxima página só é buscada
+// depois que a anterior é recebida, então a contrapressão do laço de leitura
+// também vale para as fatias.
+func (r *reader) readSlice(ctx context.Context, slice int, pages chan<- slicePage) {
+	defer r.sliceReaders.Done()
+	for {
+		result, err := r.es.searchSliceScroll(ctx, slice, r.sliceScrollIDs[slice])
+		if err == nil && result.ScrollID != "" {
+			r.sliceScrollIDs[slice] = result.ScrollID
+		}
+		select {
+		case pages <- slicePage{slice: slice, result: result, err: err}:
+		case <-ctx.Done():
+			return
+		}
+		if err == nil && len(result.Hits.Hits) == 0 {
+			return
+		}
+	}
+}
+
+// Registra o total do scroll fatiado, a soma dos totais das fatias, quando
+// todas já informaram o seu
+func (r *reader) updateSliceTotal(slice, total int) {
+	r.sliceTotals[slice] = total
+	sum := 0
+	for _, t := range r.sliceTotals {
+		if t < 0 {
+			return
+		}
+		sum += t
+	}
+	r.updateTotal(sum)
+}
+
+// Conta o documento sem ID válido e o exibe no log, até ErrorLogLimit vezes
+func (r *reader) invalidID(hit Hit, doc DocumentData) {
+	r.invalidIDs++
+	documentsInvalidID.Inc()
+	if r.invalidIDs > r.cfg.ErrorLogLimit {
+		return
+	}
+	action := "ignorado"
+	if r.cfg.OnMissingID == "hash" {
+		action = "gravado com o ID " + doc.UUID
+	}
+	logWarnf("Documento %s sem ID válido em '%s' (%v): %s", hit.ID, r.cfg.idDescription(), doc.InvalidID, action)
+}
+
+// Registra o total informado pelo Elasticsearch e avisa, uma vez, quando ele
+// muda entre as páginas: documentos indexados ou removidos durante a leitura
+// podem ser lidos duas vezes ou não ser lidos sem um point-in-time
+func (r *reader) updateTotal(total int) {
+	if r.total != 0 && total != r.total && !r.totalChanged {
+		r.totalChanged = true
+		logWarnf("Aviso: o total da consulta mudou de %d para %d durante a leitura; use USE_PIT para uma leitura consistente", r.total, total)
+	}
+	r.total = total
+	documentsTotal.Set(float64(total))
+}
+
+// Compara, ao fim da leitura, os documentos lidos com o total informado pelo
+// Elasticsearch
+func (r *reader) checkTotal() {
+	if r.total == 0 || r.read >= r.total {
+		return
+	}
+	logErrorEvent("incomplete_read", fmt.Sprintf("Leitura encerrada com %d de %d documentos informados pelo Elasticsearch; %d não foram lidos",
+		r.read, r.total, r.total-r.read),
+		"read", r.read, "total", r.total, "missing", r.total-r.read)
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Leitor com pipeline e fakes, sem checkpoint nem barra de progresso
+func newTestReader(cfg *Config, es ESSearcher, store VectorStore) *reader {
+	errLog := newErrorLog(cfg.ErrorLogLimit)
+	return &reader{
+		cfg:       cfg,
+		es:        es,
+		pipe:      newPipeline(context.Background(), cfg, NewFakeEmbedder(cfg.VectorSize), store, errLog),
+		errLog:    errLog,
+		ckptState: newCheckpointState(cfg, nil),
+		missing:   newFieldCounter(),
+	}
+}
+
+func TestReaderRun(t *testing.T) {
+	tests := []struct {
+		name        string
+		mode        string
+		pages       [][]Hit
+		skip        int
+		limit       int
+		wantBatches int
+		wantRead    int
+		wantWritten int
+	}{
+		{
+			name:        "scroll até a página vazia",
+			mode:        "scroll",
+			pages:       [][]Hit{testHits(1, 10), testHits(11, 10), testHits(21, 4)},
+			wantBatches: 4,
+			wantRead:    24,
+			wantWritten: 24,
+		},
+		{
+			name:        "search_after até a página vazia",
+			mode:        "search_after",
+			pages:       [][]Hit{testHits(1, 10), testHits(11, 5)},
+			wantBatches: 3,
+			wantRead:    15,
+			wantWritten: 15,
+		},
+		{
+			name:        "índice vazio",
+			mode:        "scroll",
+			wantBatches: 1,
+		},
+		{
+			name:        "retomada em scroll descarta os já gravados",
+			mode:        "scroll",
+			pages:       [][]Hit{testHits(1, 10), testHits(11, 10)},
+			skip:        12,
+			wantBatches: 3,
+			wantRead:    8,
+			wantWritten: 8,
+		},
+		{
+			name:        "limite encerra a leitura no meio da página",
+			mode:        "scroll",
+			pages:       [][]Hit{testHits(1, 10), testHits(11, 10), testHits(21, 10)},
+			limit:       15,
+			wantBatches: 2,
+			wantRead:    20,
+			wantWritten: 15,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+			cfg.PaginationMode = tt.mode
+			cfg.Limit = tt.limit
+			es := &fakeSearcher{pages: tt.pages}
+			store := &fakeStore{}
+			r := newTestReader(cfg, es, store)
+			r.skip = tt.skip
+
+			if err := r.run(context.Background()); err != nil {
+				t.Fatalf("run: %v", err)
+			}
+			r.pipe.close()
+
+			if r.interrupted {
+				t.Error("leitura marcada como interrompida")
+			}
+			if r.batch != tt.wantBatches || es.calls != tt.wantBatches {
+				t.Errorf("lotes = %d, buscas = %d, esperado %d", r.batch, es.calls, tt.wantBatches)
+			}
+			if r.read != tt.wantRead {
+				t.Errorf("lidos = %d, esperado %d", r.read, tt.wantRead)
+			}
+			written, failed := r.pipe.stats()
+			if written != tt.wantWritten || failed != 0 || len(store.ids()) != tt.wantWritten {
+				t.Errorf("gravados = %d (%d distintos), falhas = %d, esperado %d",
+					written, len(store.ids()), failed, tt.wantWritten)
+			}
+		})
+	}
+}
+
+func TestReaderRunSearchAfterCursor(t *testing.T) {
+	cfg := testConfig()
+	cfg.PaginationMode = "search_after"
+	es := &fakeSearcher{pages: [][]Hit{testHits(1, 10), testHits(11, 10)}}
+	r := newTestReader(cfg, es, &fakeStore{})
+
+	if err := r.run(context.Background()); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	r.pipe.close()
+
+	// Cada busca recebe o sort do último hit da página anterior
+	want := [][]interface{}{nil, testHits(10, 1)[0].Sort, testHits(20, 1)[0].Sort}
+	if !reflect.DeepEqual(es.afters, want) {
+		t.Errorf("cursores = %v, esperado %v", es.afters, want)
+	}
+}
+
+func TestReaderRunStopsWhenCursorStuck(t *testing.T) {
+	cfg := testConfig()
+	cfg.PaginationMode = "search_after"
+	// Sem o campo de ordenação nos hits, o cursor da página fica vazio
+	page := testHits(1, 10)
+	for i := range page {
+		page[i].Sort = nil
+	}
+	es := &fakeSearcher{pages: [][]Hit{page, testHits(11, 10)}}
+	r := newTestReader(cfg, es, &fakeStore{})
+
+	if err := r.run(context.Background()); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	r.pipe.close()
+
+	if es.calls != 1 || r.read != 10 {
+		t.Errorf("buscas = %d, lidos = %d, esperado 1 e 10", es.calls, r.read)
+	}
+}
+
+func TestReaderRunSlices(t *testing.T) {
+	cfg := testConfig()
+	cfg.ScrollSlices = 3
+	// A última fatia não tem documentos
+	es := &fakeSearcher{slices: [][][]Hit{
+		{testHits(1, 10), testHits(11, 5)},
+		{testHits(16, 5)},
+		nil,
+	}}
+	store := &fakeStore{}
+	r := newTestReader(cfg, es, store)
+
+	if err := r.run(context.Background()); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	r.pipe.close()
+
+	// Três páginas com documentos e o fim de todas as fatias
+	if r.batch != 4 || r.read != 20 || r.total != 20 {
+		t.Errorf("lotes = %d, lidos = %d, total = %d, esperado 4, 20 e 20", r.batch, r.read, r.total)
+	}
+	if written, _ := r.pipe.stats(); written != 20 || len(store.ids()) != 20 {
+		t.Errorf("gravados = %d (%d distintos), esperado 20", written, len(store.ids()))
+	}
+	// Cada fatia é lida até a página vazia, com o seu scroll ID
+	for slice, pages := range es.slices {
+		if calls := es.sliceCalls[slice]; calls != len(pages)+1 {
+			t.Errorf("fatia %d: %d buscas, esperado %d", slice, calls, len(pages)+1)
+		}
+		if r.sliceScrollIDs[slice] == "" {
+			t.Errorf("fatia %d sem scroll ID", slice)
+		}
+	}
+	if es.calls != 0 || r.scrollID != "" {
+		t.Errorf("scroll sem fatias usado: %d buscas, scroll ID %q", es.calls, r.scrollID)
+	}
+}
+
+func TestReaderRunStopsAfterSearchErrors(t *testing.T) {
+	cfg := testConfig()
+	es := &fakeSearcher{err: errFakeSearch}
+	r := newTestReader(cfg, es, &fakeStore{})
+
+	err := r.run(context.Background())
+	r.pipe.close()
+
+	if !errors.Is(err, errFakeSearch) {
+		t.Fatalf("erro = %v, esperado %v", err, errFakeSearch)
+	}
+	if es.calls != maxSearchErrors || r.errors != maxSearchErrors {
+		t.Errorf("buscas = %d, erros = %d, esperado %d", es.calls, r.errors, maxSearchErrors)
+	}
+	if r.errLog.total() != maxSearchErrors {
+		t.Errorf("erros registrados = %d, esperado %d", r.errLog.total(), maxSearchErrors)
+	}
+}
+
+func TestReaderRunInterrupted(t *testing.T) {
+	cfg := testConfig()
+	es := &fakeSearcher{pages: [][]Hit{testHits(1, 10)}}
+	r := newTestReader(cfg, es, &fakeStore{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := r.run(ctx); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	r.pipe.close()
+
+	if !r.interrupted {
+		t.Error("leitura não marcada como interrompida")
+	}
+	if es.calls != 0 {
+		t.Errorf("buscas = %d após o cancelamento, esperado 0", es.calls)
+	}
+}
+
+func TestPipelineFlushInterval(t *testing.T) {
+	cfg := testConfig()
+	cfg.UpsertBatchSize = 100
+	cfg.FlushInterval = 20 * time.Millisecond
+	store := &fakeStore{}
+	p := newPipeline(context.Background(), cfg, NewFakeEmbedder(cfg.VectorSize), store, newErrorLog(cfg.ErrorLogLimit))
+
+	var docs []DocumentData
+	for _, hit := range testHits(1, 5) {
+		docs = append(docs, extractDocumentData(hit, cfg))
+	}
+	p.submit(docs, nil)
+
+	// O lote parcial é gravado sem esperar o fim da leitura
+	deadline := time.Now().Add(time.Second)
+	for len(store.ids()) < len(docs) && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := len(store.ids()); got != len(docs) {
+		t.Errorf("documentos gravados antes do close = %d, esperado %d", got, len(docs))
+	}
+	p.close()
+
+	if got := p.flushedBatches(); got != 1 {
+		t.Errorf("lotes gravados = %d, esperado 1", got)
+	}
+}
+
+func TestPipelineMaxInFlight(t *testing.T) {
+	cfg := testConfig()
+	cfg.MaxInFlight = cfg.UpsertBatchSize + cfg.EmbedBatchSize
+	store := &fakeStore{}
+	p := newPipeline(context.Background(), cfg, NewFakeEmbedder(cfg.VectorSize), store, newErrorLog(cfg.ErrorLogLimit))
+
+	for page := 0; page < 10; page++ {
+		var docs []DocumentData
+		for _, hit := range testHits(page*10+1, 10) {
+			docs = append(docs, extractDocumentData(hit, cfg))
+		}
+		p.submit(docs, nil)
+		if n := p.pendingDocs(); n > cfg.MaxInFlight {
+			t.Fatalf("página %d: %d documentos em processamento, limite %d", page+1, n, cfg.MaxInFlight)
+		}
+	}
+	p.close()
+
+	if got := len(store.ids()); got != 100 {
+		t.Errorf("documentos gravados = %d, esperado 100", got)
+	}
+	if waits, _ := p.inFlight.stats(); waits == 0 {
+		t.Error("a leitura não aguardou o limite de documentos em processamento")
+	}
+	if n := p.pendingDocs(); n != 0 {
+		t.Errorf("documentos pendentes após o close = %d, esperado 0", n)
+	}
+}
+
+func TestPipelineFailureRates(t *testing.T) {
+	tests := []struct {
+		name          string
+		batchRate     float64
+		totalRate     float64
+		wantThreshold string
+	}{
+		{name: "limites desativados"},
+		{name: "limite por lote", batchRate: 50, wantThreshold: "MAX_BATCH_FAILURE_RATE"},
+		{name: "limite total", totalRate: 10, wantThreshold: "MAX_FAILURE_RATE"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+			cfg.MaxBatchFailureRate = tt.batchRate
+			cfg.MaxFailureRate = tt.totalRate
+			store := &fakeStore{err: errors.New("qdrant indisponível")}
+			p := newPipeline(context.Background(), cfg, NewFakeEmbedder(cfg.VectorSize), store, newErrorLog(cfg.ErrorLogLimit))
+
+			// O limite total só é conferido a partir de minFailureRateDocs
+			for page := 0; page*10 < minFailureRateDocs; page++ {
+				var docs []DocumentData
+				for _, hit := range testHits(page*10+1, 10) {
+					docs = append(docs, extractDocumentData(hit, cfg))
+				}
+				p.submit(docs, nil)
+			}
+			p.close()
+
+			err := p.aborted()
+			var rateErr *FailureRateError
+			switch {
+			case tt.wantThreshold == "" && err != nil:
+				t.Errorf("exportação abortada com os limites desativados: %v", err)
+			case tt.wantThreshold != "" && !errors.As(err, &rateErr):
+				t.Errorf("erro = %v, esperado o limite %s", err, tt.wantThreshold)
+			case tt.wantThreshold != "" && rateErr.Threshold != tt.wantThreshold:
+				t.Errorf("limite excedido = %s, esperado %s", rateErr.Threshold, tt.wantThreshold)
+			}
+		})
+	}
+}
+
+func TestReaderRunMissingID(t *testing.T) {
+	tests := []struct {
+		policy      string
+		wantWritten int
+		wantInvalid int
+		wantAbort   bool
+	}{
+		{policy: "skip", wantWritten: 8, wantInvalid: 2},
+		{policy: "hash", wantWritten: 10, wantInvalid: 2},
+		{policy: "fail", wantAbort: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			cfg := testConfig()
+			cfg.OnMissingID = tt.policy
+			hits := testHits(1, 10)
+			delete(hits[3].Source, "id")
+			hits[7].Source["id"] = "" // ID vazio
+			store := &fakeStore{}
+			r := newTestReader(cfg, &fakeSearcher{pages: [][]Hit{hits}}, store)
+
+			if err := r.run(context.Background()); err != nil {
+				t.Fatalf("run: %v", err)
+			}
+			r.pipe.close()
+
+			var idErr *MissingIDError
+			if aborted := errors.As(r.pipe.aborted(), &idErr); aborted != tt.wantAbort {
+				t.Fatalf("abortado = %v, esperado %v", aborted, tt.wantAbort)
+			}
+			if r.invalidIDs != tt.wantInvalid {
+				t.Errorf("IDs inválidos = %d, esperado %d", r.invalidIDs, tt.wantInvalid)
+			}
+			keys := make(map[string]bool)
+			for _, doc := range store.docs {
+				keys[docKey(doc)] = true
+			}
+			if len(store.docs) != tt.wantWritten || len(keys) != tt.wantWritten {
+				t.Errorf("gravados = %d (%d distintos), esperado %d", len(store.docs), len(keys), tt.wantWritten)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Resumo da exportação gravado com -report, para que pipelines de CI
+// verifiquem o resultado sem interpretar os logs. Status é completed,
+// failed (erros acima de MAX_ERRORS ou buscas falhando repetidamente),
+// interrupted ou aborted (dimensão divergente ou documento sem ID).
+type runReport struct {
+	Status            string       `json:"status"`
+	Index             string       `json:"index"`
+	Collection        string       `json:"collection"`
+	DryRun            bool         `json:"dry_run"`
+	StartedAt         time.Time    `json:"started_at"`
+	FinishedAt        time.Time    `json:"finished_at"`
+	DurationMs        int64        `json:"duration_ms"`
+	Read              int          `json:"read"`
+	Expected          int          `json:"expected"`
+	Processed         int          `json:"processed"`
+	Errors            int          `json:"errors"`
+	ErrorsByCategory  []errorCount `json:"errors_by_category"`
+	EmbeddingFailed   int          `json:"embedding_failed"`
+	EmbeddingRetries  int64        `json:"embedding_retries"`
+	DocsPerSecond     float64      `json:"docs_per_second"`
+	Pages             int          `json:"pages"`
+	UpsertBatches     int          `json:"upsert_batches"`
+	FilteredOut       int          `json:"filtered_out"`
+	DuplicatesSkipped int          `json:"duplicates_skipped"`
+	SkippedUnchanged  int          `json:"skipped_unchanged"`
+	DimensionSkipped  int64        `json:"dimension_skipped"`
+	InvalidIDs        int          `json:"invalid_ids"`
+	Cache             *cacheReport `json:"cache,omitempty"`
+	Cursor            reportCursor `json:"cursor"`
+}
+
+type cacheReport struct {
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
+	// Acertos e falhas de cada campo de embed_field, incluídos nos totais
+	Fields map[string]cacheReport `json:"fields,omitempty"`
+}
+
+// Posição final da leitura, a mesma registrada no checkpoint
+type reportCursor struct {
+	From        int           `json:"from"`
+	SearchAfter []interface{} `json:"search_after,omitempty"`
+}
+
+// Monta o relatório a partir do estado final da leitura e do pipeline.
+// processed inclui os documentos de execuções anteriores retomadas do
+// checkpoint; a vazão considera apenas os desta execução.
+func newRunReport(cfg *Config, status string, started time.Time, r *reader, sink Sink, processed, errors int, cache *embeddingCache) *runReport {
+	finished := time.Now()
+	rep := &runReport{
+		Status:           status,
+		Index:            cfg.indexName(),
+		Collection:       cfg.CollectionName,
+		DryRun:           cfg.DryRun,
+		StartedAt:        started,
+		FinishedAt:       finished,
+		DurationMs:       finished.Sub(started).Milliseconds(),
+		Read:             r.read,
+		Expected:         r.total,
+		Processed:        processed,
+		Errors:           errors,
+		ErrorsByCategory: r.errLog.summary(),
+		EmbeddingFailed:  r.pipe.embedFailedCount(),
+		Pages:            r.batch,
+		UpsertBatches:    r.pipe.flushedBatches(),
+		SkippedUnchanged: r.pipe.unchangedCount(),
+		DimensionSkipped: sink.skippedDimensions(),
+		InvalidIDs:       r.invalidIDs,
+		Cursor:           reportCursor{From: r.read, SearchAfter: r.after},
+	}
+	if seconds := finished.Sub(started).Seconds(); seconds > 0 {
+		rep.DocsPerSecond = float64(processed-r.processedBefore) / seconds
+	}
+	if r.filter != nil {
+		rep.FilteredOut = r.filter.skippedCount()
+	}
+	if r.dedup != nil {
+		rep.DuplicatesSkipped = r.dedup.skippedCount()
+	}
+	if cache != nil {
+		hits, misses := cache.stats()
+		rep.Cache = &cacheReport{Hits: hits, Misses: misses}
+		for field, counts := range cache.fieldStats() {
+			if rep.Cache.Fields == nil {
+				rep.Cache.Fields = make(map[string]cacheReport)
+			}
+			rep.Cache.Fields[field] = cacheReport{Hits: counts.hits, Misses: counts.misses}
+		}
+	}
+	return rep
+}
+
+// Grava o relatório em JSON em path; "-" escreve na saída padrão, que não
+// recebe os logs
+func writeReport(path string, rep *runReport) error {
+	data, err := json.MarshalIndent(rep, "", "  ")
+	if err != nil {
+		return fmt.Errorf("erro ao serializar relatório: %v", err)
+	}
+	data = append(data, '\n')
+
+	if path == "-" {
+		_, err = os.Stdout.Write(data)
+	} else {
+		err = os.WriteFile(path, data, 0o644)
+	}
+	if err != nil {
+		return fmt.Errorf("erro ao gravar relatório: %v", err)
+	}
+	return nil
+}
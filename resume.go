@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// Retomada pela coleção (-resume-from-qdrant): em vez de um checkpoint, a
+// posição de leitura é derivada do maior ID numérico já gravado, e a busca no
+// Elasticsearch recomeça a partir dele (ID_FIELD > máximo, em ordem
+// crescente). Pressupõe IDs monotônicos: documentos indexados depois com IDs
+// menores que o máximo não são migrados.
+
+// Maior ID numérico da coleção e o total de pontos. O scroll do Qdrant
+// percorre os IDs em ordem crescente, com os numéricos antes dos UUIDs, então
+// o máximo é encontrado por busca binária com scrolls de um único ponto a
+// partir de cada offset, sem percorrer a coleção. ok é false se a coleção não
+// tem pontos com ID numérico.
+func (qc *QdrantClient) maxPointID(ctx context.Context) (max uint64, count uint64, ok bool, err error) {
+	err = qc.do(ctx, func(ctx context.Context) error {
+		var err error
+		count, err = qc.client.Count(ctx, &qdrant.CountPoints{
+			CollectionName: qc.cfg.CollectionName,
+			Exact:          qdrant.PtrOf(true),
+		})
+		return err
+	})
+	if err != nil {
+		return 0, 0, false, fmt.Errorf("erro ao contar pontos da coleção: %v", err)
+	}
+
+	lo, found, err := qc.nextNumericID(ctx, 0)
+	if err != nil || !found {
+		return 0, count, false, err
+	}
+
+	// lo é sempre um ID existente; acima de hi não há IDs numéricos
+	hi := uint64(math.MaxUint64)
+	for lo < hi {
+		mid := lo + (hi-lo)/2 + 1
+		next, found, err := qc.nextNumericID(ctx, mid)
+		if err != nil {
+			return 0, count, false, err
+		}
+		if found {
+			lo = next
+		} else {
+			hi = mid - 1
+		}
+	}
+
+	return lo, count, true, nil
+}
+
+// Menor ID numérico da coleção maior ou igual a offset
+func (qc *QdrantClient) nextNumericID(ctx context.Context, offset uint64) (uint64, bool, error) {
+	var points []*qdrant.RetrievedPoint
+	err := qc.do(ctx, func(ctx context.Context) error {
+		var err error
+		points, err = qc.client.Scroll(ctx, &qdrant.ScrollPoints{
+			CollectionName: qc.cfg.CollectionName,
+			Offset:         qdrant.NewIDNum(offset),
+			Limit:          qdrant.PtrOf(uint32(1)),
+			WithPayload:    qdrant.NewWithPayload(false),
+			WithVectors:    qdrant.NewWithVectors(false),
+		})
+		return err
+	})
+	if err != nil {
+		return 0, false, fmt.Errorf("erro ao percorrer pontos da coleção: %v", err)
+	}
+
+	if len(points) == 0 {
+		return 0, false, nil
+	}
+	// Um UUID indica que não há mais IDs numéricos a partir de offset
+	num, isNum := points[0].GetId().GetPointIdOptions().(*qdrant.PointId_Num)
+	if !isNum {
+		return 0, false, nil
+	}
+	return num.Num, true, nil
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/qdrant/go-client/qdrant"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	retryBaseDelay = 500 * time.Millisecond
+	retryMaxDelay  = 30 * time.Second
+)
+
+// Erro HTTP retornado pelo Elasticsearch, com o Retry-After quando presente
+type HTTPError struct {
+	StatusCode int
+	Body       string
+	RetryAfter time.Duration
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("erro HTTP %d: %s", e.StatusCode, e.Body)
+}
+
+// Embedding com dimensão diferente de VECTOR_SIZE, que o Qdrant rejeitaria
+// junto com todo o lote
+type DimensionError struct {
+	ID   string
+	Got  int
+	Want int
+}
+
+func (e *DimensionError) Error() string {
+	return fmt.Sprintf("embedding do documento %s tem dimensão %d, esperado %d", e.ID, e.Got, e.Want)
+}
+
+// Falhas acima de um dos limites: MAX_BATCH_FAILURE_RATE nos documentos de
+// um lote ou MAX_FAILURE_RATE no total de documentos finalizados
+type FailureRateError struct {
+	Threshold string // variável do limite excedido
+	Failed    int
+	Total     int
+	Limit     float64
+}
+
+func (e *FailureRateError) Error() string {
+	scope := "finalizados"
+	if e.Threshold == "MAX_BATCH_FAILURE_RATE" {
+		scope = "do lote"
+	}
+	return fmt.Sprintf("limite %s excedido: %d de %d documentos %s falharam (%.1f%%, máximo %g%%)",
+		e.Threshold, e.Failed, e.Total, scope, failureRate(e.Failed, e.Total), e.Limit)
+}
+
+// Percentual de falhas em total documentos
+func failureRate(failed, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(failed) * 100 / float64(total)
+}
+
+// Coleção inexistente no upsert com SkipCreate, que não a cria: ela precisa
+// ser criada fora da migração
+type CollectionNotFoundError struct {
+	Collection string
+	Err        error
+}
+
+func (e *CollectionNotFoundError) Error() string {
+	return fmt.Sprintf("a coleção '%s' não existe no Qdrant (%v); com -skip-create ela precisa ser criada fora da migração", e.Collection, e.Err)
+}
+
+func (e *CollectionNotFoundError) Unwrap() error {
+	return e.Err
+}
+
+// Documento sem ID válido em ID_FIELD com OnMissingID "fail"
+type MissingIDError struct {
+	DocID string // _id do hit
+	Err   error
+}
+
+func (e *MissingIDError) Error() string {
+	return fmt.Sprintf("documento %s sem ID válido: %v", e.DocID, e.Err)
+}
+
+// Política de novas tentativas: quantidade máxima, backoff exponencial entre
+// baseDelay e maxDelay e os erros considerados transitórios
+type retryPolicy struct {
+	maxAttempts int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+	retryable   func(error) bool
+}
+
+// Executa fn até maxAttempts vezes enquanto o erro for transitório (HTTP 429,
+// 502, 503, 504, falhas de conexão ou indisponibilidade do Qdrant), com
+// backoff exponencial e jitter entre as tentativas.
+func withRetry(ctx context.Context, maxAttempts int, fn func() error) error {
+	policy := retryPolicy{maxAttempts: maxAttempts, baseDelay: retryBaseDelay, maxDelay: retryMaxDelay, retryable: isRetryable}
+	return policy.do(ctx, fn)
+}
+
+// Executa fn conforme a política, aguardando retryDelay entre as tentativas
+func (rp retryPolicy) do(ctx context.Context, fn func() error) error {
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = fn(); err == nil {
+			return nil
+		}
+		if attempt >= rp.maxAttempts || !rp.retryable(err) || ctx.Err() != nil {
+			return err
+		}
+
+		delay := retryDelay(err, attempt, rp.baseDelay, rp.maxDelay)
+		logWarnf("Tentativa %d/%d falhou: %v. Nova tentativa em %s", attempt, rp.maxAttempts, err, delay.Round(time.Millisecond))
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+	}
+}
+
+func isRetryable(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		switch httpErr.StatusCode {
+		case http.StatusTooManyRequests, http.StatusBadGateway,
+			http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+
+	// Falhas de transporte do http.Client (conexão recusada, reset, timeout)
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return true
+	}
+
+	var exhausted *qdrant.QdrantResourceExhaustedError
+	if errors.As(err, &exhausted) {
+		return true
+	}
+
+	switch status.Code(err) {
+	case codes.Unavailable, codes.ResourceExhausted, codes.DeadlineExceeded, codes.Aborted:
+		return true
+	}
+
+	// Timeout por requisição (QDRANT_TIMEOUT) expirado antes da chamada gRPC
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	return false
+}
+
+// Erros transitórios das APIs de embeddings: os mesmos de isRetryable e
+// também HTTP 500, que os provedores retornam em falhas temporárias
+func isEmbedRetryable(err error) bool {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusInternalServerError {
+		return true
+	}
+	return isRetryable(err)
+}
+
+// Usa o Retry-After informado pelo servidor; caso contrário, backoff
+// exponencial a partir de base, limitado a max, com até 50% de jitter
+func retryDelay(err error, attempt int, base, max time.Duration) time.Duration {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) && httpErr.RetryAfter > 0 {
+		return httpErr.RetryAfter
+	}
+
+	var exhausted *qdrant.QdrantResourceExhaustedError
+	if errors.As(err, &exhausted) && exhausted.RetryAfterS > 0 {
+		return time.Duration(exhausted.RetryAfterS) * time.Second
+	}
+
+	delay := base << (attempt - 1)
+	if delay <= 0 || delay > max {
+		delay = max
+	}
+
+	return delay + time.Duration(rand.Int63n(int64(delay)/2+1))
+}
+
+// Interpreta o cabeçalho Retry-After, em segundos ou como data HTTP
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// Resposta do _bulk; cada item traz a ação ("index") com o resultado
+type bulkResponse struct {
+	Errors bool                        `json:"errors"`
+	Items  []map[string]bulkItemResult `json:"items"`
+}
+
+type bulkItemResult struct {
+	ID     string          `json:"_id"`
+	Status int             `json:"status"`
+	Error  json.RawMessage `json:"error,omitempty"`
+}
+
+// Leitura paginada da coleção na exportação inversa: retorna a página que
+// começa em offset (nil para a primeira) e o offset da próxima, nil na última
+type PointScroller interface {
+	scrollPoints(ctx context.Context, offset *qdrant.PointId) ([]*qdrant.RetrievedPoint, *qdrant.PointId, error)
+}
+
+// Destino dos pontos na exportação inversa; retorna os gravados e as falhas
+type PointIndexer interface {
+	bulkIndex(ctx context.Context, index string, points []*qdrant.RetrievedPoint, errLog *errorLog) (int, int)
+}
+
+// Exportação inversa (-direction qdrant-to-es): percorre a coleção com
+// scroll, em páginas de PageSize pontos, e grava cada página no índice de
+// ES_URL com uma requisição _bulk. O ID do ponto vira o _id do documento e o
+// payload, o _source; com ExportVectors o vetor denso vai para ESVectorField.
+// Retorna false se a exportação foi interrompida ou teve falhas.
+func runReverseExport(ctx, writeCtx context.Context, cfg *Config, es *ElasticsearchClient, qc *QdrantClient) bool {
+	if !cfg.SkipPreflight {
+		if err := reversePreflight(ctx, cfg, es, qc); err != nil {
+			fatalf("Falha na verificação inicial: %v", err)
+		}
+	}
+
+	var ckpt *Checkpoint
+	if cfg.Checkpoint != "" {
+		var err error
+		if ckpt, err = loadCheckpoint(cfg.Checkpoint, cfg); err != nil {
+			fatalf("Erro no checkpoint: %v", err)
+		}
+		if ckpt != nil {
+			log.Printf("Retomando do checkpoint %s: %d pontos gravados", cfg.Checkpoint, ckpt.Processed)
+		}
+	}
+
+	return exportPoints(ctx, writeCtx, cfg, qc, es, ckpt)
+}
+
+// Laço da exportação inversa, a partir do offset de ckpt (nil começa do
+// início). Com -checkpoint, cada página gravada sem falhas registra o offset
+// da próxima; como o scroll percorre os pontos em ordem de ID, a retomada não
+// repete nem pula pontos, exceto os criados durante a exportação com IDs
+// menores que o offset. Depois de uma página com falhas o checkpoint não
+// avança, e a próxima execução a grava de novo (o _bulk é idempotente).
+func exportPoints(ctx, writeCtx context.Context, cfg *Config, qc PointScroller, es PointIndexer, ckpt *Checkpoint) bool {
+	inicio := time.Now()
+	index := cfg.indexName()
+	salvar := cfg.Checkpoint != "" && !cfg.DryRun
+
+	errLog := newErrorLog(cfg.ErrorLogLimit)
+	var offset *qdrant.PointId
+	lidos, gravados, falhas, lote := 0, 0, 0, 0
+	if ckpt != nil {
+		// Validado em loadCheckpoint
+		offset, _ = ckpt.scrollOffset()
+		gravados = ckpt.Processed
+	}
+	interrompido := false
+
+	for {
+		if ctx.Err() != nil {
+			interrompido = true
+			break
+		}
+
+		inicioBusca := time.Now()
+		points, next, err := qc.scrollPoints(ctx, offset)
+		if err != nil {
+			if ctx.Err() != nil {
+				interrompido = true
+				break
+			}
+			// O offset não avança, então a falha da leitura encerra a
+			// exportação
+			errLog.record("scroll", fmt.Errorf("erro ao percorrer pontos da coleção: %w", err), "batch", lote+1)
+			falhas++
+			break
+		}
+		if len(points) == 0 {
+			break
+		}
+		offset = next
+		lote++
+		lidos += len(points)
+		documentsRead.Add(float64(len(points)))
+		logEvent("batch_fetched", fmt.Sprintf("Lote %d: %d pontos lidos do Qdrant", lote, len(points)),
+			"batch", lote, "hits", len(points), "duration_ms", durationMs(inicioBusca))
+
+		n, failed := es.bulkIndex(writeCtx, index, points, errLog)
+		gravados += n
+		if salvar && failed > 0 && falhas == 0 {
+			logWarnf("O lote %d teve falhas; o checkpoint não avançará nesta execução", lote)
+		}
+		falhas += failed
+		documentsProcessed.Add(float64(n))
+		documentsFailed.Add(float64(failed))
+		if n > 0 {
+			batchesFlushed.Inc()
+		}
+
+		logEvent("batch_queued", fmt.Sprintf("Lote %d gravado: %d pontos lidos. Total gravado: %d, falhas: %d",
+			lote, lidos, gravados, falhas),
+			"batch", lote, "read", lidos, "processed", gravados, "errors", falhas)
+
+		if offset == nil {
+			break
+		}
+		if salvar && falhas == 0 {
+			saveReverseCheckpoint(cfg, offset, gravados)
+		}
+	}
+
+	errLog.logSummary()
+
+	if interrompido {
+		logSummaryEvent("interrupted", fmt.Sprintf("Exportação interrompida após %d pontos lidos: %d gravados, %d erros", lidos, gravados, falhas),
+			"read", lidos, "processed", gravados, "errors", falhas, "duration_ms", durationMs(inicio))
+		if salvar {
+			log.Printf("Progresso salvo em %s; execute novamente para retomar", cfg.Checkpoint)
+		}
+		return false
+	}
+	if salvar && falhas == 0 {
+		removeCheckpoint(cfg.Checkpoint)
+	}
+
+	if cfg.DryRun {
+		log.Printf("Dry-run: %d documentos seriam gravados no índice '%s'", gravados, index)
+	}
+	logSummaryEvent("finished", fmt.Sprintf("Exportação finalizada: %d documentos gravados, %d erros", gravados, falhas),
+		"processed", gravados, "errors", falhas, "duration_ms", durationMs(inicio))
+	return falhas == 0
+}
+
+// Página do scroll da coleção, com as novas tentativas de qc.do
+func (qc *QdrantClient) scrollPoints(ctx context.Context, offset *qdrant.PointId) ([]*qdrant.RetrievedPoint, *qdrant.PointId, error) {
+	var points []*qdrant.RetrievedPoint
+	var next *qdrant.PointId
+	err := qc.do(ctx, func(ctx context.Context) error {
+		var err error
+		points, next, err = qc.client.ScrollAndOffset(ctx, &qdrant.ScrollPoints{
+			CollectionName: qc.cfg.CollectionName,
+			Offset:         offset,
+			Limit:          qdrant.PtrOf(uint32(qc.cfg.PageSize)),
+			WithPayload:    qdrant.NewWithPayload(true),
+			WithVectors:    qdrant.NewWithVectors(qc.cfg.ExportVectors),
+		})
+		return err
+	})
+	return points, next, err
+}
+
+// Verifica o Elasticsearch e a existência da coleção de origem, na exportação
+// inversa, no -verify e no -diff. O índice de destino da exportação inversa
+// não precisa existir: o _bulk o cria com o mapeamento dinâmico.
+func reversePreflight(ctx context.Context, cfg *Config, es *ElasticsearchClient, qc *QdrantClient) error {
+	log.Println("Verificando Elasticsearch e Qdrant...")
+
+	if err := es.ping(ctx); err != nil {
+		return err
+	}
+	qdrantCtx, cancel := context.WithTimeout(ctx, cfg.QdrantTimeout)
+	defer cancel()
+	exists, err := qc.client.CollectionExists(qdrantCtx, cfg.CollectionName)
+	if err != nil {
+		return fmt.Errorf("Qdrant inacessível em %s:%d (verifique QDRANT_URL, QDRANT_HOST, QDRANT_PORT, QDRANT_TLS e QDRANT_API_KEY): %v",
+			cfg.QdrantHost, cfg.QdrantPort, err)
+	}
+	if !exists {
+		return fmt.Errorf("coleção '%s' não encontrada no Qdrant; verifique COLLECTION_NAME", cfg.CollectionName)
+	}
+
+	log.Println("Verificação concluída")
+	return nil
+}
+
+// Grava os pontos no índice com uma requisição _bulk, com novas tentativas
+// para erros transitórios da requisição. Itens recusados individualmente são
+// registrados em errLog. Retorna os documentos gravados e os com falha.
+func (ec *ElasticsearchClient) bulkIndex(ctx context.Context, index string, points []*qdrant.RetrievedPoint, errLog *errorLog) (int, int) {
+	if ec.cfg.DryRun {
+		for i, point := range points {
+			if i >= dryRunSamples {
+				break
+			}
+			doc, _ := json.Marshal(ec.pointDocument(point))
+			log.Printf("Dry-run: documento %s, _source %s", pointKey(point.GetId()), doc)
+		}
+		return len(points), 0
+	}
+
+	var body bytes.Buffer
+	skipped := 0
+	for _, point := range points {
+		action, _ := json.Marshal(map[string]interface{}{
+			"index": map[string]string{"_index": index, "_id": pointKey(point.GetId())},
+		})
+		doc, err := json.Marshal(ec.pointDocument(point))
+		if err != nil {
+			errLog.record("bulk", fmt.Errorf("erro ao converter ponto %s: %w", pointKey(point.GetId()), err))
+			skipped++
+			continue
+		}
+		body.Write(action)
+		body.WriteByte('\n')
+		body.Write(doc)
+		body.WriteByte('\n')
+	}
+
+	if body.Len() == 0 {
+		return 0, skipped
+	}
+
+	url := ec.cfg.esBaseURL() + "/" + escapeIndex(index) + "/_bulk"
+	var result *bulkResponse
+	err := withRetry(ctx, ec.cfg.MaxRetries, func() error {
+		if err := ec.limiter.Wait(ctx); err != nil {
+			return err
+		}
+		var err error
+		result, err = ec.bulkOnce(ctx, url, body.Bytes())
+		return err
+	})
+	if err != nil {
+		errLog.record("bulk", fmt.Errorf("erro ao gravar documentos: %w", err), "documents", len(points)-skipped)
+		return 0, len(points)
+	}
+
+	written, failed := 0, skipped
+	for _, item := range result.Items {
+		res := item["index"]
+		if res.Status >= 200 && res.Status < 300 {
+			written++
+			continue
+		}
+		failed++
+		errLog.record("bulk", fmt.Errorf("documento %s: %w", res.ID,
+			&HTTPError{StatusCode: res.Status, Body: string(res.Error)}))
+	}
+	return written, failed
+}
+
+func (ec *ElasticsearchClient) bulkOnce(ctx context.Context, url string, body []byte) (*bulkResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("erro ao criar requisição: %v", err)
+	}
+
+	ec.setAuth(req)
+	req.Header.Set("Content-Type", "application/x-ndjson")
+
+	resp, err := ec.send(req)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao executar requisição: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, &HTTPError{
+			StatusCode: resp.StatusCode,
+			Body:       string(respBody),
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
+	}
+
+	var result bulkResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("erro ao decodificar resposta: %v", err)
+	}
+	return &result, nil
+}
+
+// _source do documento: o payload do ponto e, com ExportVectors, o vetor denso
+func (ec *ElasticsearchClient) pointDocument(point *qdrant.RetrievedPoint) map[string]interface{} {
+	doc := make(map[string]interface{}, len(point.GetPayload())+1)
+	for k, v := range point.GetPayload() {
+		doc[k] = fromQdrantValue(v)
+	}
+	if ec.cfg.ExportVectors {
+		if vector := denseVector(point.GetVectors()); len(vector) > 0 {
+			doc[ec.cfg.ESVectorField] = vector
+		}
+	}
+	return doc
+}
+
+// Converte um valor do payload do Qdrant no valor JSON equivalente
+func fromQdrantValue(v *qdrant.Value) interface{} {
+	switch kind := v.GetKind().(type) {
+	case *qdrant.Value_StringValue:
+		return kind.StringValue
+	case *qdrant.Value_IntegerValue:
+		return kind.IntegerValue
+	case *qdrant.Value_DoubleValue:
+		return kind.DoubleValue
+	case *qdrant.Value_BoolValue:
+		return kind.BoolValue
+	case *qdrant.Value_StructValue:
+		out := make(map[string]interface{}, len(kind.StructValue.GetFields()))
+		for k, item := range kind.StructValue.GetFields() {
+			out[k] = fromQdrantValue(item)
+		}
+		return out
+	case *qdrant.Value_ListValue:
+		out := make([]interface{}, 0, len(kind.ListValue.GetValues()))
+		for _, item := range kind.ListValue.GetValues() {
+			out = append(out, fromQdrantValue(item))
+		}
+		return out
+	default:
+		return nil
+	}
+}
+
+// Vetor denso do ponto: o vetor sem nome, também quando a coleção tem um
+// vetor esparso e os vetores vêm nomeados
+func denseVector(vectors *qdrant.VectorsOutput) []float32 {
+	vector := vectors.GetVector()
+	if vector == nil {
+		vector = vectors.GetVectors().GetVectors()[""]
+	}
+	if dense := vector.GetDense(); dense != nil {
+		return dense.GetData()
+	}
+	return vector.GetData()
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// Pontos com os IDs informados, na ordem do scroll
+func testPoints(ids ...*qdrant.PointId) []*qdrant.RetrievedPoint {
+	points := make([]*qdrant.RetrievedPoint, len(ids))
+	for i, id := range ids {
+		points[i] = &qdrant.RetrievedPoint{Id: id}
+	}
+	return points
+}
+
+func TestExportPointsResume(t *testing.T) {
+	numeric := make([]*qdrant.PointId, 25)
+	for i := range numeric {
+		numeric[i] = qdrant.NewIDNum(uint64(i))
+	}
+	uuids := make([]*qdrant.PointId, 7)
+	for i := range uuids {
+		uuids[i] = qdrant.NewID(uuidV5("ponto-" + strconv.Itoa(i)))
+	}
+	slices.SortFunc(uuids, func(a, b *qdrant.PointId) int { return strings.Compare(pointKey(a), pointKey(b)) })
+
+	tests := []struct {
+		name        string
+		ids         []*qdrant.PointId
+		pageSize    int
+		interruptAt int // lote após o qual a primeira execução é interrompida
+		wantOffset  string
+	}{
+		{name: "IDs numéricos", ids: numeric, pageSize: 10, interruptAt: 2, wantOffset: "20"},
+		{name: "interrompida na primeira página", ids: numeric, pageSize: 10, interruptAt: 1, wantOffset: "10"},
+		{name: "UUIDs", ids: uuids, pageSize: 3, interruptAt: 1, wantOffset: pointKey(uuids[3])},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+			cfg.Direction = "qdrant-to-es"
+			cfg.ESURL = "https://es:9200/backup/_search"
+			cfg.CollectionName = "documentos"
+			cfg.Checkpoint = filepath.Join(t.TempDir(), "reverso.json")
+			scroller := &fakeScroller{points: testPoints(tt.ids...), pageSize: tt.pageSize}
+
+			// Primeira execução, interrompida após interruptAt lotes gravados
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			first := &fakeIndexer{onBatch: func(batch int) {
+				if batch == tt.interruptAt {
+					cancel()
+				}
+			}}
+			if exportPoints(ctx, context.Background(), cfg, scroller, first, nil) {
+				t.Fatal("exportação interrompida retornou sucesso")
+			}
+
+			ckpt, err := loadCheckpoint(cfg.Checkpoint, cfg)
+			if err != nil || ckpt == nil {
+				t.Fatalf("checkpoint após a interrupção: %v, %v", ckpt, err)
+			}
+			offset, err := ckpt.scrollOffset()
+			if err != nil || offset == nil || pointKey(offset) != tt.wantOffset {
+				t.Fatalf("scroll_offset = %v (%v), esperado %v", ckpt.ScrollOffset, err, tt.wantOffset)
+			}
+			if ckpt.Processed != len(first.ids) {
+				t.Errorf("processed = %d, esperado %d", ckpt.Processed, len(first.ids))
+			}
+
+			// Retomada a partir do checkpoint
+			second := &fakeIndexer{}
+			resumedAt := len(scroller.offsets)
+			if !exportPoints(context.Background(), context.Background(), cfg, scroller, second, ckpt) {
+				t.Fatal("retomada falhou")
+			}
+			if got := scroller.offsets[resumedAt]; got != tt.wantOffset {
+				t.Errorf("retomada começou no offset %q, esperado %q", got, tt.wantOffset)
+			}
+			if _, err := os.Stat(cfg.Checkpoint); !os.IsNotExist(err) {
+				t.Errorf("checkpoint não removido ao fim da exportação: %v", err)
+			}
+
+			// Cada ponto gravado exatamente uma vez, nas duas execuções
+			got := append(append([]string(nil), first.ids...), second.ids...)
+			want := make([]string, len(tt.ids))
+			for i, id := range tt.ids {
+				want[i] = pointKey(id)
+			}
+			if !slices.Equal(got, want) {
+				t.Errorf("pontos gravados = %v, esperado %v", got, want)
+			}
+		})
+	}
+}
+
+func TestLoadCheckpointDirection(t *testing.T) {
+	cfg := testConfig()
+	cfg.Direction = "es-to-qdrant"
+	cfg.ESURL = "https://es:9200/backup/_search"
+	cfg.CollectionName = "documentos"
+	cfg.Checkpoint = filepath.Join(t.TempDir(), "reverso.json")
+
+	reverse := *cfg
+	reverse.Direction = "qdrant-to-es"
+	saveReverseCheckpoint(&reverse, qdrant.NewIDNum(0), 10)
+
+	if _, err := loadCheckpoint(cfg.Checkpoint, cfg); err == nil {
+		t.Error("checkpoint da exportação inversa aceito na exportação para o Qdrant")
+	}
+	ckpt, err := loadCheckpoint(cfg.Checkpoint, &reverse)
+	if err != nil {
+		t.Fatalf("loadCheckpoint: %v", err)
+	}
+	// O ponto de ID 0 é um offset válido, e não o início da coleção
+	if offset, err := ckpt.scrollOffset(); err != nil || offset == nil || offset.GetNum() != 0 {
+		t.Errorf("scroll_offset = %v (%v), esperado o ponto 0", offset, err)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Caracteres aceitos nos nomes de coleção derivados dos documentos; os demais
+// são trocados por "_"
+var collectionNameUnsafe = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+// Coleção de destino pelo CollectionTemplate, substituindo cada campo pelo
+// seu valor no _source, como em "docs_{tenant_id}". Se algum campo estiver
+// ausente ou vazio, retorna "" e os campos faltantes, e o documento vai para
+// CollectionName.
+func routeCollection(template string, source map[string]interface{}) (string, []string) {
+	var missing []string
+	name := templatePlaceholder.ReplaceAllStringFunc(template, func(match string) string {
+		field := strings.TrimSpace(match[1 : len(match)-1])
+		v, _ := lookupField(source, field)
+		value := textValue(normalizeJSON(v))
+		if value == "" {
+			missing = append(missing, field)
+		}
+		return collectionNameUnsafe.ReplaceAllString(value, "_")
+	})
+	if len(missing) > 0 {
+		return "", missing
+	}
+	return name, nil
+}
+
+// Coleção de destino do documento
+func (d DocumentData) collection(cfg *Config) string {
+	if d.Collection != "" {
+		return d.Collection
+	}
+	return cfg.CollectionName
+}
+
+// Documentos de uma mesma coleção de destino e chave de shard
+type collectionGroup struct {
+	collection string
+	shardKey   string
+	docs       []DocumentData
+}
+
+// Agrupa os documentos por coleção de destino e chave de shard, na ordem em
+// que aparecem e mantendo a ordem dos documentos em cada grupo
+func groupByCollection(docs []DocumentData, cfg *Config) []collectionGroup {
+	var groups []collectionGroup
+	index := make(map[string]int)
+	for _, doc := range docs {
+		name := doc.collection(cfg)
+		key := name + "\x00" + doc.ShardKey
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, collectionGroup{collection: name, shardKey: doc.ShardKey})
+		}
+		groups[i].docs = append(groups[i].docs, doc)
+	}
+	return groups
+}
+
+// Cria a coleção e os índices de payload na primeira vez que um documento é
+// roteado para ela nesta execução. As coleções verificadas ficam em cache;
+// com falha, a criação é tentada de novo no próximo lote.
+func (qc *QdrantClient) ensureCollection(ctx context.Context, name string) error {
+	qc.mu.Lock()
+	ready := qc.ready[name]
+	qc.mu.Unlock()
+	if ready {
+		return nil
+	}
+
+	if err := qc.createCollection(ctx, name); err != nil {
+		return err
+	}
+	if err := qc.createPayloadIndexes(ctx, name); err != nil {
+		return fmt.Errorf("erro ao criar índices de payload: %w", err)
+	}
+
+	qc.mu.Lock()
+	qc.ready[name] = true
+	qc.mu.Unlock()
+	return nil
+}
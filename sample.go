@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+)
+
+// Amostra dos payloads (-sample-payloads N): busca os N primeiros documentos
+// da consulta configurada, extrai cada um como na exportação e exibe em
+// stdout o ID do ponto e o payload que seriam gravados, sem gerar embeddings
+// nem conectar ao Qdrant. Serve para conferir o mapeamento dos campos antes
+// de uma migração completa: um campo ausente em todos os documentos da
+// amostra costuma ser um nome digitado errado.
+
+// Ponto exibido na amostra
+type samplePoint struct {
+	ID         string                 `json:"id"`
+	Collection string                 `json:"collection,omitempty"`
+	ShardKey   string                 `json:"shard_key,omitempty"`
+	InvalidID  string                 `json:"invalid_id,omitempty"`
+	Missing    []string               `json:"missing,omitempty"`
+	Payload    map[string]interface{} `json:"payload"`
+}
+
+// Exibe a amostra; retorna false se a busca falhou
+func runSamplePayloads(ctx context.Context, cfg *Config, es *ElasticsearchClient) bool {
+	body := es.searchBody()
+	body["size"] = cfg.SamplePayloads
+	query, err := json.Marshal(body)
+	if err != nil {
+		logErrorf("Erro ao montar requisição de busca: %v", err)
+		return false
+	}
+
+	log.Printf("Buscando %d documentos de '%s' para a amostra...", cfg.SamplePayloads, cfg.indexName())
+	result, err := es.doSearch(ctx, "POST", cfg.ESURL, string(query))
+	if err != nil {
+		logErrorf("Erro ao buscar documentos: %v", err)
+		return false
+	}
+
+	hits := result.Hits.Hits
+	missing := make(map[string]int)
+	for _, hit := range hits {
+		doc := extractDocumentData(hit, cfg)
+		for _, field := range doc.Missing {
+			missing[field]++
+		}
+		// Com LONG_TEXT=chunk, cada trecho vira um ponto
+		for _, doc := range limitTextLength([]DocumentData{doc}, cfg) {
+			point := samplePoint{
+				ID:         doc.idString(),
+				Collection: doc.Collection,
+				ShardKey:   doc.ShardKey,
+				Missing:    doc.Missing,
+				Payload:    newPointPayload(doc, cfg),
+			}
+			if doc.InvalidID != nil {
+				point.InvalidID = doc.InvalidID.Error()
+			}
+			out, err := json.MarshalIndent(point, "", "  ")
+			if err != nil {
+				logErrorf("Erro ao serializar o documento %s: %v", hit.ID, err)
+				continue
+			}
+			fmt.Fprintln(os.Stdout, string(out))
+		}
+	}
+
+	// Campos ausentes em toda a amostra, provavelmente com o nome errado
+	fields := make([]string, 0, len(missing))
+	for field, n := range missing {
+		if n == len(hits) {
+			fields = append(fields, field)
+		}
+	}
+	slices.Sort(fields)
+	for _, field := range fields {
+		logWarnf("Campo '%s' ausente em todos os %d documentos da amostra; confira o nome na configuração", field, len(hits))
+	}
+
+	logSummaryEvent("sampled", fmt.Sprintf("Amostra concluída: %d documentos exibidos, %d campos ausentes em todos", len(hits), len(fields)),
+		"sampled", len(hits), "missing_fields", len(fields))
+	return true
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// Sharding por chave (-shard-key-field): a coleção é criada com o sharding
+// custom do Qdrant e cada ponto é gravado nos shards da chave lida do campo
+// SHARD_KEY_FIELD do documento, como o tenant, isolando os dados de cada
+// chave em shards próprios. Os lotes são agrupados por chave, uma requisição
+// por chave, e as chaves são criadas na primeira vez que aparecem, com
+// SHARD_NUMBER shards e REPLICATION_FACTOR réplicas cada. As leituras sem
+// chave consultam todos os shards.
+
+// Chave de shard do documento pelo ShardKeyField; ausente ou vazia, retorna
+// ShardKeyDefault e o campo faltante
+func shardKeyValue(source map[string]interface{}, cfg *Config) (string, []string) {
+	v, _ := lookupField(source, cfg.ShardKeyField)
+	if key := textValue(normalizeJSON(v)); key != "" {
+		return key, nil
+	}
+	return cfg.ShardKeyDefault, []string{cfg.ShardKeyField}
+}
+
+// Seletor das requisições de escrita; nil sem ShardKeyField
+func shardKeySelector(key string) *qdrant.ShardKeySelector {
+	if key == "" {
+		return nil
+	}
+	return &qdrant.ShardKeySelector{ShardKeys: []*qdrant.ShardKey{qdrant.NewShardKey(key)}}
+}
+
+// Método de sharding da coleção criada; nil mantém o automático
+func (c *Config) shardingMethod() *qdrant.ShardingMethod {
+	if c.ShardKeyField == "" {
+		return nil
+	}
+	return qdrant.ShardingMethod_Custom.Enum()
+}
+
+// Cria a chave de shard na coleção na primeira vez que ela aparece nesta
+// execução. As chaves criadas ficam em cache; com falha, a criação é tentada
+// de novo no próximo lote.
+func (qc *QdrantClient) ensureShardKey(ctx context.Context, collection, key string) error {
+	cacheKey := collection + "\x00" + key
+	qc.mu.Lock()
+	ready := qc.shardKeys[cacheKey]
+	qc.mu.Unlock()
+	if ready {
+		return nil
+	}
+
+	if err := qc.createShardKey(ctx, collection, key); err != nil {
+		return err
+	}
+
+	qc.mu.Lock()
+	qc.shardKeys[cacheKey] = true
+	qc.mu.Unlock()
+	return nil
+}
+
+// Cria a chave de shard; uma chave já existente, criada por uma execução
+// anterior, não é erro
+func (qc *QdrantClient) createShardKey(ctx context.Context, collection, key string) error {
+	err := qc.do(ctx, func(ctx context.Context) error {
+		return qc.client.CreateShardKey(ctx, collection, &qdrant.CreateShardKey{
+			ShardKey:          qdrant.NewShardKey(key),
+			ShardsNumber:      optionalUint32(qc.cfg.ShardNumber),
+			ReplicationFactor: optionalUint32(qc.cfg.ReplicationFactor),
+		})
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), "already exists") {
+			return nil
+		}
+		return fmt.Errorf("erro ao criar chave de shard '%s': %v", key, err)
+	}
+
+	logEvent("shard_key_created", fmt.Sprintf("Chave de shard '%s' criada na coleção '%s'", key, collection),
+		"collection", collection, "shard_key", key)
+	return nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Destino dos pontos preparados pelo pipeline: o Qdrant ou, com -output, um
+// arquivo JSON lines
+type Sink interface {
+	VectorStore
+	skippedDimensions() int64
+	Close() error
+}
+
+// Ponto gravado no arquivo do -output, no formato aceito pela API REST de
+// upsert do Qdrant (PUT /collections/{nome}/points), o que permite reenviar
+// o arquivo depois em lotes
+type pointRecord struct {
+	ID      interface{}            `json:"id"`
+	Vector  interface{}            `json:"vector"`
+	Payload map[string]interface{} `json:"payload"`
+}
+
+type sparseRecord struct {
+	Indices []uint32  `json:"indices"`
+	Values  []float32 `json:"values"`
+}
+
+// Sink que grava cada ponto como uma linha JSON em cfg.Output, no lugar do
+// upsert no Qdrant. O arquivo é recriado a cada execução, exceto com
+// checkpoint, em que a retomada continua o arquivo existente.
+type fileSink struct {
+	dimensionCheck
+	cfg *Config
+
+	mu   sync.Mutex
+	file *os.File
+	w    *bufio.Writer
+}
+
+func newFileSink(cfg *Config) (*fileSink, error) {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if cfg.Checkpoint != "" {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+	file, err := os.OpenFile(cfg.Output, flags, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao abrir arquivo de saída: %v", err)
+	}
+
+	return &fileSink{
+		dimensionCheck: dimensionCheck{cfg: cfg},
+		cfg:            cfg,
+		file:           file,
+		w:              bufio.NewWriter(file),
+	}, nil
+}
+
+// Grava os documentos, um por linha, e descarrega o buffer ao final do lote,
+// para que o checkpoint só avance sobre linhas já escritas no arquivo
+func (s *fileSink) upsertDocuments(ctx context.Context, docs []DocumentData) (int, error) {
+	docs, err := s.checkDimensions(docs)
+	if err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, doc := range docs {
+		line, err := json.Marshal(s.record(doc))
+		if err != nil {
+			return i, fmt.Errorf("erro ao converter documento %s: %v", doc.idString(), err)
+		}
+		s.w.Write(line)
+		if err := s.w.WriteByte('\n'); err != nil {
+			return i, fmt.Errorf("erro ao gravar arquivo de saída: %v", err)
+		}
+	}
+
+	if err := s.w.Flush(); err != nil {
+		return 0, fmt.Errorf("erro ao gravar arquivo de saída: %v", err)
+	}
+	return len(docs), nil
+}
+
+// Ponto do documento, com o mesmo ID, vetores e payload do upsert no Qdrant
+func (s *fileSink) record(doc DocumentData) pointRecord {
+	rec := pointRecord{ID: doc.ID, Vector: doc.Vector, Payload: newPointPayload(doc, s.cfg)}
+	if doc.UUID != "" {
+		rec.ID = doc.UUID
+	}
+
+	if len(s.cfg.NamedVectors) == 0 && !s.cfg.SparseVectors {
+		return rec
+	}
+
+	vectors := map[string]interface{}{"": doc.Vector}
+	if len(s.cfg.NamedVectors) > 0 {
+		vectors = make(map[string]interface{}, len(s.cfg.NamedVectors)+1)
+		for name, vector := range doc.denseVectors(s.cfg) {
+			vectors[name] = vector
+		}
+	}
+	if s.cfg.SparseVectors {
+		if indices, values := sparseVector(doc.Texto, s.cfg); len(indices) > 0 {
+			vectors[s.cfg.SparseVectorName] = sparseRecord{Indices: indices, Values: values}
+		}
+	}
+	rec.Vector = vectors
+	return rec
+}
+
+// Descarrega o buffer e fecha o arquivo
+func (s *fileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.w.Flush(); err != nil {
+		s.file.Close()
+		return fmt.Errorf("erro ao gravar arquivo de saída: %v", err)
+	}
+	return s.file.Close()
+}
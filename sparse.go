@@ -0,0 +1,68 @@
+package main
+
+import (
+	"hash/fnv"
+	"math"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Vetor esparso de frequência de termos do texto, para buscas híbridas
+// (densa + esparsa) no estilo BM25. Cada termo é mapeado para um índice pelo
+// hash FNV-1a, dispensando um vocabulário; com SparseIDF o Qdrant aplica o
+// IDF no momento da busca.
+func sparseVector(text string, cfg *Config) (indices []uint32, values []float32) {
+	counts := make(map[uint32]float32)
+	for _, term := range tokenize(text, cfg.SparseMinTermLen) {
+		counts[termIndex(term)]++
+	}
+
+	indices = make([]uint32, 0, len(counts))
+	for idx := range counts {
+		indices = append(indices, idx)
+	}
+	slices.Sort(indices)
+
+	values = make([]float32, len(indices))
+	for i, idx := range indices {
+		values[i] = termWeight(counts[idx], cfg.SparseWeighting)
+	}
+
+	return indices, values
+}
+
+// Divide o texto em termos minúsculos formados por letras e dígitos,
+// descartando os menores que minLen caracteres
+func tokenize(text string, minLen int) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	terms := fields[:0]
+	for _, f := range fields {
+		if len([]rune(f)) >= minLen {
+			terms = append(terms, f)
+		}
+	}
+	return terms
+}
+
+func termIndex(term string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(term))
+	return h.Sum32()
+}
+
+// Peso do termo a partir da frequência no documento: tf (contagem), log
+// (1 + ln tf, que atenua termos repetidos) ou binary (presença)
+func termWeight(tf float32, weighting string) float32 {
+	switch weighting {
+	case "log":
+		return 1 + float32(math.Log(float64(tf)))
+	case "binary":
+		return 1
+	default:
+		return tf
+	}
+}
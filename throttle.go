@@ -0,0 +1,60 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// Peso da última medição na média móvel da latência dos upserts
+	throttleSmoothing = 0.2
+	// Razão entre a latência média e a de referência em que a pausa chega ao
+	// máximo
+	throttleMaxRatio = 4.0
+)
+
+// Pausa adaptativa entre as páginas lidas, calculada a partir da latência
+// dos upserts. A latência de referência é a menor média móvel observada, a
+// do cluster saudável; conforme a média sobe em relação a ela (sinal de
+// sobrecarga), a pausa cresce linearmente de min até max, alcançado com
+// throttleMaxRatio vezes a referência, e volta a diminuir quando a latência cai.
+type adaptiveThrottle struct {
+	min, max time.Duration
+
+	mu       sync.Mutex
+	average  float64 // média móvel exponencial, em segundos
+	baseline float64
+}
+
+func newAdaptiveThrottle(min, max time.Duration) *adaptiveThrottle {
+	return &adaptiveThrottle{min: min, max: max}
+}
+
+// Registra a latência de um upsert
+func (t *adaptiveThrottle) observe(latency time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s := latency.Seconds()
+	if t.average == 0 {
+		t.average = s
+	} else {
+		t.average += throttleSmoothing * (s - t.average)
+	}
+	if t.baseline == 0 || t.average < t.baseline {
+		t.baseline = t.average
+	}
+}
+
+// Pausa a aplicar antes da próxima página; min enquanto não há medições
+func (t *adaptiveThrottle) delay() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.baseline == 0 {
+		return t.min
+	}
+	load := (t.average/t.baseline - 1) / (throttleMaxRatio - 1)
+	load = max(0, min(load, 1))
+	return t.min + time.Duration(load*float64(t.max-t.min))
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Tempo acumulado de uma etapa da migração (busca, embeddings ou gravação),
+// para o resumo que indica o gargalo. O valor zero está pronto para uso.
+type stageTiming struct {
+	mu      sync.Mutex
+	batches int
+	total   time.Duration
+	max     time.Duration
+}
+
+// Registra a duração de um lote
+func (t *stageTiming) observe(d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.batches++
+	t.total += d
+	t.max = max(t.max, d)
+}
+
+// Lotes, tempo total e maior duração
+func (t *stageTiming) stats() (batches int, total, longest time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.batches, t.total, t.max
+}
+
+// "12.3s (média 120ms, máx. 900ms em 100 lotes)", para o resumo
+func (t *stageTiming) describe() string {
+	batches, total, longest := t.stats()
+	if batches == 0 {
+		return "sem lotes"
+	}
+	mean := total / time.Duration(batches)
+	return fmt.Sprintf("%s (média %s, máx. %s em %d lotes)",
+		total.Round(time.Millisecond), mean.Round(time.Millisecond), longest.Round(time.Millisecond), batches)
+}
+
+// Registra o resumo do tempo gasto em cada etapa. Os embeddings e as
+// gravações rodam em paralelo com a leitura, e os embeddings em WORKERS
+// goroutines, então os tempos são somados por lote e podem passar da duração
+// da migração; a etapa com o maior tempo por lote tende a ser o gargalo.
+func logStageTimings(search, embedding, upsert *stageTiming) {
+	searches, searchTotal, _ := search.stats()
+	embeds, embedTotal, _ := embedding.stats()
+	upserts, upsertTotal, _ := upsert.stats()
+	logEvent("stage_timing", fmt.Sprintf("Tempo por etapa: buscas no Elasticsearch %s; embeddings %s; gravação %s",
+		search.describe(), embedding.describe(), upsert.describe()),
+		"search_ms", searchTotal.Milliseconds(), "search_batches", searches,
+		"embedding_ms", embedTotal.Milliseconds(), "embedding_batches", embeds,
+		"upsert_ms", upsertTotal.Milliseconds(), "upsert_batches", upserts)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/qdrant/go-client/qdrant"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Campo do payload com o hash do conteúdo gravado, comparado pelo
+// -skip-unchanged nas execuções seguintes
+const contentHashField = "content_hash"
+
+// Consulta os hashes de conteúdo dos pontos já gravados, pela chave de docKey.
+// Pontos inexistentes ou gravados sem content_hash ficam de fora.
+type HashLookup interface {
+	contentHashes(ctx context.Context, docs []DocumentData) (map[string]string, error)
+}
+
+// Hash de tudo o que determina o ponto gravado: o modelo de embedding, a
+// entrada do embedding e o payload, e o vetor quando já vem do documento
+// (EmbeddingField). json.Marshal ordena as chaves do map, então a ordem dos
+// campos no _source não altera o hash.
+func contentHash(doc DocumentData, model string) string {
+	payload, _ := json.Marshal(doc.Payload)
+	content := model + "\x00" + doc.Texto + "\x00" + string(payload)
+	if len(doc.FieldTexts) > 0 {
+		texts, _ := json.Marshal(doc.FieldTexts)
+		content += "\x00" + string(texts)
+	}
+	if len(doc.Vector) > 0 {
+		vector, _ := json.Marshal(doc.Vector)
+		content += "\x00" + string(vector)
+	}
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// Hash SHA-256 da entrada do embedding, gravado em text_hash: com o
+// embedding_model, permite encontrar depois os pontos que precisam de um novo
+// embedding quando o texto ou o modelo mudam
+func textHash(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
+
+// Consulta cada coleção de destino separadamente. Uma coleção do
+// CollectionTemplate que ainda não existe não tem pontos gravados.
+func (qc *QdrantClient) contentHashes(ctx context.Context, docs []DocumentData) (map[string]string, error) {
+	hashes := make(map[string]string, len(docs))
+
+	for _, group := range groupByCollection(docs, qc.cfg) {
+		ids := make([]*qdrant.PointId, len(group.docs))
+		for i, doc := range group.docs {
+			ids[i] = doc.pointID()
+		}
+
+		points, err := qc.getPoints(ctx, group.collection, ids, contentHashField)
+		if status.Code(err) == codes.NotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for key, point := range points {
+			if h := point.GetPayload()[contentHashField].GetStringValue(); h != "" {
+				hashes[key] = h
+			}
+		}
+	}
+	return hashes, nil
+}
+
+// Calcula o content_hash dos documentos do lote e remove os que já estão
+// gravados com o mesmo hash; esses são finalizados sem embedding nem upsert.
+// Se a consulta ao Qdrant falhar, o lote segue inteiro.
+func (p *pipeline) skipUnchanged(item workItem) workItem {
+	model := p.cfg.embeddingModel()
+	for i := range item.docs {
+		item.docs[i].ContentHash = contentHash(item.docs[i], model)
+	}
+
+	stored, err := p.hashes.contentHashes(p.ctx, item.docs)
+	if err != nil {
+		p.errLog.record("comparação", err, "documents", len(item.docs))
+		return item
+	}
+
+	changed := make([]DocumentData, 0, len(item.docs))
+	var skipped []int
+	for _, doc := range item.docs {
+		if stored[docKey(doc)] == doc.ContentHash {
+			skipped = append(skipped, item.page)
+			continue
+		}
+		changed = append(changed, doc)
+	}
+	if len(skipped) == 0 {
+		return item
+	}
+	documentsUnchanged.Add(float64(len(skipped)))
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.unchanged += len(skipped)
+	p.complete(skipped, false)
+	return workItem{page: item.page, docs: changed}
+}
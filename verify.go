@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// Maior amostra aceita em -verify: o tamanho máximo de uma busca sem
+// paginação no Elasticsearch (index.max_result_window)
+const maxVerifySample = 10000
+
+// Verificação (-verify N): sorteia N documentos da consulta configurada,
+// busca os pontos correspondentes no Qdrant e compara o campo texto do
+// payload byte a byte com o texto extraído do documento. Pontos ausentes e
+// textos divergentes indicam perda de dados no processamento em lotes ou no
+// mapeamento de IDs. Retorna false se houve divergências ou falhas.
+func runVerify(ctx context.Context, cfg *Config, es *ElasticsearchClient, qc *QdrantClient) bool {
+	inicio := time.Now()
+
+	if !cfg.SkipPreflight {
+		if err := reversePreflight(ctx, cfg, es, qc); err != nil {
+			fatalf("Falha na verificação inicial: %v", err)
+		}
+	}
+
+	log.Printf("Sorteando %d documentos de '%s' para conferir em '%s'...", cfg.Verify, cfg.indexName(), cfg.CollectionName)
+	result, err := es.sampleDocuments(ctx, cfg.Verify)
+	if err != nil {
+		logErrorf("Erro ao sortear documentos: %v", err)
+		return false
+	}
+
+	// Documentos da amostra pelo ID do ponto, como gravados na migração
+	docs := make(map[string]DocumentData, len(result.Hits.Hits))
+	ids := make([]*qdrant.PointId, 0, len(result.Hits.Hits))
+	for _, hit := range result.Hits.Hits {
+		// O texto gravado foi truncado em MAX_CHARS
+		doc := limitTextLength([]DocumentData{extractDocumentData(hit, cfg)}, cfg)[0]
+		// Documentos sem ID válido só são gravados com ON_MISSING_ID=hash
+		if doc.InvalidID != nil && cfg.OnMissingID != "hash" {
+			continue
+		}
+		key := docKey(doc)
+		if _, ok := docs[key]; ok {
+			continue
+		}
+		docs[key] = doc
+		ids = append(ids, doc.pointID())
+	}
+
+	points, err := qc.getPoints(ctx, cfg.CollectionName, ids, cfg.payloadKey(textPayloadKey))
+	if err != nil {
+		logErrorf("Erro ao buscar pontos no Qdrant: %v", err)
+		return false
+	}
+
+	ausentes, divergentes := 0, 0
+	for _, id := range ids {
+		key := pointKey(id)
+		doc := docs[key]
+		point, ok := points[key]
+		if !ok {
+			ausentes++
+			if ausentes <= cfg.ErrorLogLimit {
+				logErrorEvent("verify_missing", fmt.Sprintf("Ponto %s não encontrado na coleção", key), "id", key)
+			}
+			continue
+		}
+		payload := make(map[string]interface{}, len(point.GetPayload()))
+		for k, v := range point.GetPayload() {
+			payload[k] = fromQdrantValue(v)
+		}
+		v, _ := lookupField(payload, cfg.payloadKey(textPayloadKey))
+		texto, _ := v.(string)
+		if items, ok := v.([]interface{}); ok {
+			texto = joinTextArray(items, cfg)
+		}
+		if texto != doc.Texto {
+			divergentes++
+			if divergentes <= cfg.ErrorLogLimit {
+				esperado, _ := json.Marshal(doc.Texto)
+				gravado, _ := json.Marshal(texto)
+				logErrorEvent("verify_mismatch", fmt.Sprintf("Ponto %s com texto divergente: esperado %s, gravado %s", key, esperado, gravado),
+					"id", key, "expected_len", len(doc.Texto), "actual_len", len(texto))
+			}
+		}
+	}
+	if ausentes > cfg.ErrorLogLimit || divergentes > cfg.ErrorLogLimit {
+		log.Printf("Apenas as primeiras %d ocorrências de cada tipo foram exibidas", cfg.ErrorLogLimit)
+	}
+
+	conferidos := len(ids)
+	logSummaryEvent("verified", fmt.Sprintf("Verificação concluída: %d documentos conferidos, %d pontos ausentes, %d textos divergentes",
+		conferidos, ausentes, divergentes),
+		"checked", conferidos, "missing", ausentes, "mismatched", divergentes, "duration_ms", durationMs(inicio))
+	return ausentes == 0 && divergentes == 0
+}
+
+// Sorteia até n documentos da consulta configurada com random_score
+func (ec *ElasticsearchClient) sampleDocuments(ctx context.Context, n int) (*SearchResponse, error) {
+	query := ec.searchBody()
+	query["size"] = n
+	query["query"] = map[string]interface{}{
+		"function_score": map[string]interface{}{
+			"query":        query["query"],
+			"random_score": map[string]interface{}{},
+			"boost_mode":   "replace",
+		},
+	}
+
+	body, err := json.Marshal(query)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao montar requisição de busca: %v", err)
+	}
+
+	return ec.doSearch(ctx, "POST", ec.cfg.ESURL, string(body))
+}
+
+// Busca os pontos da coleção com os IDs informados, apenas com os campos fields do
+// payload, em requisições de até UpsertBatchSize IDs. Retorna os pontos
+// encontrados pela chave de pointKey.
+func (qc *QdrantClient) getPoints(ctx context.Context, collection string, ids []*qdrant.PointId, fields ...string) (map[string]*qdrant.RetrievedPoint, error) {
+	found := make(map[string]*qdrant.RetrievedPoint, len(ids))
+
+	for start := 0; start < len(ids); start += qc.cfg.UpsertBatchSize {
+		end := min(start+qc.cfg.UpsertBatchSize, len(ids))
+
+		var points []*qdrant.RetrievedPoint
+		err := qc.do(ctx, func(ctx context.Context) error {
+			var err error
+			points, err = qc.client.Get(ctx, &qdrant.GetPoints{
+				CollectionName: collection,
+				Ids:            ids[start:end],
+				WithPayload:    qdrant.NewWithPayloadInclude(fields...),
+				WithVectors:    qdrant.NewWithVectors(false),
+			})
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, point := range points {
+			found[pointKey(point.GetId())] = point
+		}
+	}
+
+	return found, nil
+}